@@ -0,0 +1,290 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["ERROR"] = errorFunc
+	BuiltIn["ERROR-KIND"] = errorKind
+	BuiltIn["ERROR-MESSAGE"] = errorMessage
+	BuiltIn["ERROR-PAYLOAD"] = errorPayload
+	BuiltIn["BACKTRACE"] = backtrace
+	BuiltIn["CATCH"] = catch
+	BuiltIn["THROW"] = throw
+	BuiltIn["UNWIND-PROTECT"] = unwindProtect
+	registerTail("HANDLER-CASE", handlerCase)
+}
+
+// Errors from builtins are Go errors. When HANDLER-CASE catches one, it's turned into a types.Error
+// with a kind of ERROR, so Lisp code can catch anything that goes wrong, not just errors signaled by ERROR.
+
+// anyError is the kind in a HANDLER-CASE clause that catches every error
+const anyError types.Atom = "ERROR"
+
+// thrown is the error used by THROW to get back to the matching CATCH.
+// HANDLER-CASE doesn't catch it, since it's not really an error.
+type thrown struct {
+	tag   types.Expr
+	value types.Expr
+}
+
+func (t *thrown) Error() string {
+	return fmt.Sprintf("THROW to %s without a matching CATCH", t.tag)
+}
+
+// toLispError returns the types.Error for err, and false if err is a THROW.
+func toLispError(err error) (*types.Error, bool) {
+	var th *thrown
+	if errors.As(err, &th) {
+		return nil, false
+	}
+	var ee *EvalError
+	hasTrace := errors.As(err, &ee)
+	var le *types.Error
+	if !errors.As(err, &le) {
+		//the position isn't part of the message; it's where the error was found, not what went wrong
+		msg := err
+		if hasTrace {
+			msg = ee.Err
+		}
+		le = &types.Error{Kind: anyError, Message: msg.Error(), Payload: types.EMPTY}
+	}
+	//an error that is signaled again keeps the backtrace from where it started
+	if hasTrace && le.Backtrace == nil {
+		le.Backtrace = make([]string, len(ee.Trace))
+		for i, f := range ee.Trace {
+			le.Backtrace[i] = f.String()
+		}
+	}
+	return le, true
+}
+
+// errorFunc signals an error. The kind defaults to ERROR and the payload defaults to NIL.
+// Passing an error that was caught by HANDLER-CASE signals it again.
+// (ERROR [KIND] MESSAGE [PAYLOAD]) or (ERROR CAUGHT-ERROR)
+func errorFunc(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("ERROR", t, env, 1, 3)
+	if err != nil {
+		return nil, err
+	}
+	if le, ok := vals[0].(*types.Error); ok && len(vals) == 1 {
+		return nil, le
+	}
+	le := &types.Error{Kind: anyError, Payload: types.EMPTY}
+	if kind, ok := vals[0].(types.Atom); ok {
+		if len(vals) == 1 {
+			return nil, errors.New("missing message for ERROR")
+		}
+		le.Kind = kind
+		vals = vals[1:]
+	}
+	msg, ok := vals[0].(types.String)
+	if !ok {
+		return nil, errors.New("ERROR message must be a string")
+	}
+	le.Message = string(msg)
+	switch len(vals) {
+	case 1:
+	case 2:
+		le.Payload = vals[1]
+	default:
+		return nil, errors.New("too many parameters for ERROR")
+	}
+	return nil, le
+}
+
+// errorParam evaluates the single parameter of the named builtin, which must be an error
+func (in *Interpreter) errorParam(name string, t *types.SExpr, env types.Env) (*types.Error, error) {
+	vals, err := in.checkedParams(name, t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	le, ok := vals[0].(*types.Error)
+	if !ok {
+		return nil, fmt.Errorf("%s parameter must be an error", name)
+	}
+	return le, nil
+}
+
+// (ERROR-KIND ERR)
+func errorKind(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	le, err := in.errorParam("ERROR-KIND", t, env)
+	if err != nil {
+		return nil, err
+	}
+	return le.Kind, nil
+}
+
+// (ERROR-MESSAGE ERR)
+func errorMessage(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	le, err := in.errorParam("ERROR-MESSAGE", t, env)
+	if err != nil {
+		return nil, err
+	}
+	return types.String(le.Message), nil
+}
+
+// (ERROR-PAYLOAD ERR)
+func errorPayload(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	le, err := in.errorParam("ERROR-PAYLOAD", t, env)
+	if err != nil {
+		return nil, err
+	}
+	return le.Payload, nil
+}
+
+// backtrace returns the backtrace of an error caught by HANDLER-CASE, as a list of strings.
+// Each one describes a call of a Lisp function that was running when the error was signaled, innermost first.
+// Calls in tail position replace the function that made them, so they aren't in the backtrace.
+// (BACKTRACE ERR)
+func backtrace(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	le, err := in.errorParam("BACKTRACE", t, env)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]types.Expr, len(le.Backtrace))
+	for i, f := range le.Backtrace {
+		out[i] = types.String(f)
+	}
+	return sliceToList(out), nil
+}
+
+// handlerCase evaluates EXPR. If that signals an error, the first clause whose KIND matches the kind of the error
+// is chosen, and its body is evaluated with VAR bound to the error. A KIND of ERROR matches every error.
+// If no clause matches, the error keeps going. The body of the chosen clause is in tail position.
+// (HANDLER-CASE EXPR (KIND ([VAR]) BODY...)...)
+func handlerCase(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, types.Env, error) {
+	a2, ok := t.Right.(*types.SExpr)
+	if !ok {
+		return nil, nil, errors.New("missing expression for HANDLER-CASE")
+	}
+	result, err := in.evalArg(a2, env)
+	if err == nil {
+		return result, nil, nil
+	}
+	le, ok := toLispError(err)
+	if !ok {
+		return nil, nil, err
+	}
+	for cur := a2.Right; cur != types.NIL; {
+		c, ok := cur.(*types.SExpr)
+		if !ok {
+			return nil, nil, errors.New("HANDLER-CASE clauses must be a list")
+		}
+		clause, ok := c.Left.(*types.SExpr)
+		if !ok {
+			return nil, nil, errors.New("HANDLER-CASE clause must be a list")
+		}
+		kind, ok := clause.Left.(types.Atom)
+		if !ok {
+			return nil, nil, errors.New("HANDLER-CASE clause must start with an error kind")
+		}
+		if kind == anyError || kind == le.Kind {
+			return in.handlerClause(clause, le, env)
+		}
+		cur = c.Right
+	}
+	return nil, nil, err
+}
+
+// handlerClause binds the variable of a HANDLER-CASE clause to le, and returns the clause's body to evaluate
+func (in *Interpreter) handlerClause(clause *types.SExpr, le *types.Error, env types.Env) (types.Expr, types.Env, error) {
+	rest, ok := clause.Right.(*types.SExpr)
+	if !ok {
+		return nil, nil, errors.New("HANDLER-CASE clause must have a variable list")
+	}
+	innerEnv := types.NewLocalEnv(env)
+	switch v := rest.Left.(type) {
+	case types.Nil:
+	case *types.SExpr:
+		if isEmpty(v) {
+			break
+		}
+		name, ok := v.Left.(types.Atom)
+		if !ok || v.Right != types.NIL {
+			return nil, nil, errors.New("HANDLER-CASE clause variable list must have a single symbol")
+		}
+		innerEnv.Define(name, le)
+	default:
+		return nil, nil, errors.New("HANDLER-CASE clause variable list must be a list")
+	}
+	body := rest.Right
+	if body == types.NIL {
+		return types.EMPTY, nil, nil
+	}
+	return bodyExpr(body), innerEnv, nil
+}
+
+// catch evaluates TAG and then the BODY expressions, and returns the value of the last one.
+// If a THROW to a tag that is EQ to TAG happens while the body is being evaluated, CATCH returns the thrown value.
+// (CATCH TAG BODY...)
+func catch(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	a2, ok := t.Right.(*types.SExpr)
+	if !ok {
+		return nil, errors.New("missing tag for CATCH")
+	}
+	tag, err := in.evalArg(a2, env)
+	if err != nil {
+		return nil, err
+	}
+	var result types.Expr = types.EMPTY
+	for cur := a2.Right; cur != types.NIL; {
+		c, ok := cur.(*types.SExpr)
+		if !ok {
+			return nil, errors.New("can't have a dotted pair here")
+		}
+		result, err = in.evalArg(c, env)
+		if err != nil {
+			var th *thrown
+			if errors.As(err, &th) && isEqual(th.tag, tag) {
+				return th.value, nil
+			}
+			return nil, err
+		}
+		cur = c.Right
+	}
+	return result, nil
+}
+
+// throw returns VALUE from the innermost CATCH for TAG. VALUE defaults to NIL.
+// (THROW TAG [VALUE])
+func throw(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("THROW", t, env, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	th := &thrown{tag: vals[0], value: types.EMPTY}
+	if len(vals) == 2 {
+		th.value = vals[1]
+	}
+	return nil, th
+}
+
+// unwindProtect evaluates EXPR, then the CLEANUP expressions, even if EXPR signaled an error or did a THROW.
+// It returns the value of EXPR, or keeps its error going. An error in the cleanup replaces the one from EXPR.
+// (UNWIND-PROTECT EXPR CLEANUP...)
+func unwindProtect(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	a2, ok := t.Right.(*types.SExpr)
+	if !ok {
+		return nil, errors.New("missing expression for UNWIND-PROTECT")
+	}
+	result, err := in.evalArg(a2, env)
+	for cur := a2.Right; cur != types.NIL; {
+		c, ok := cur.(*types.SExpr)
+		if !ok {
+			return nil, errors.New("can't have a dotted pair here")
+		}
+		if _, cleanupErr := in.evalArg(c, env); cleanupErr != nil {
+			return nil, cleanupErr
+		}
+		cur = c.Right
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,275 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["GO"] = goFunc
+	BuiltIn["MAKE-CHAN"] = makeChan
+	BuiltIn["SEND"] = send
+	BuiltIn["RECV"] = recv
+	BuiltIn["CLOSE-CHAN"] = closeChan
+	registerTail("SELECT", selectFunc)
+}
+
+// goFunc evaluates its parameter in a new goroutine, using the current environment.
+// It returns T right away. Since nothing is waiting for the result, errors and panics are written to the
+// interpreter's logger.
+// (GO EXPR)
+func goFunc(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	if t.Right == types.NIL {
+		return nil, errors.New("missing parameter for GO")
+	}
+	a2, ok := t.Right.(*types.SExpr)
+	if !ok {
+		return nil, errors.New("GO parameter must be a list")
+	}
+	//should only have a single parameter for GO
+	if a2.Right != types.NIL {
+		return nil, errors.New("shouldn't have more than one parameter for GO")
+	}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				in.logger.Println("panic in goroutine:", r)
+			}
+		}()
+		_, err := in.evalArg(a2, env)
+		if err != nil {
+			in.logger.Println("error in goroutine:", err)
+		}
+	}()
+	return types.T, nil
+}
+
+// makeChan creates a new channel. If a size is supplied, the channel is buffered.
+// (MAKE-CHAN [SIZE])
+func makeChan(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MAKE-CHAN", t, env, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	size := 0
+	if len(vals) == 1 {
+		size, err = asInt("MAKE-CHAN", vals[0])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errors.New("MAKE-CHAN size must not be negative")
+		}
+	}
+	return types.Channel{Ch: make(chan types.Expr, size)}, nil
+}
+
+func asChannel(name string, e types.Expr) (types.Channel, error) {
+	c, ok := e.(types.Channel)
+	if !ok {
+		return types.Channel{}, fmt.Errorf("%s parameter must be a channel", name)
+	}
+	return c, nil
+}
+
+// send writes a value to a channel, waiting until there is room for it. It returns the value.
+// (SEND CHANNEL VALUE)
+func send(in *Interpreter, t *types.SExpr, env types.Env) (out types.Expr, err error) {
+	vals, err := in.checkedParams("SEND", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	c, err := asChannel("SEND", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	defer closedChannelError("SEND", &err)
+	c.Ch <- vals[1]
+	return vals[1], nil
+}
+
+// recv reads a value from a channel, waiting until there is one.
+// If the channel is closed, it returns NIL.
+// (RECV CHANNEL)
+func recv(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("RECV", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	c, err := asChannel("RECV", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	v, ok := <-c.Ch
+	if !ok {
+		return types.EMPTY, nil
+	}
+	return v, nil
+}
+
+// (CLOSE-CHAN CHANNEL)
+func closeChan(in *Interpreter, t *types.SExpr, env types.Env) (out types.Expr, err error) {
+	vals, err := in.checkedParams("CLOSE-CHAN", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	c, err := asChannel("CLOSE-CHAN", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	defer closedChannelError("CLOSE-CHAN", &err)
+	close(c.Ch)
+	return types.T, nil
+}
+
+// closedChannelError turns the panic from sending on or closing a closed channel into an error
+func closedChannelError(name string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%s on closed channel", name)
+	}
+}
+
+// selectFunc waits until one of its clauses can proceed, like Go's select statement.
+// Each clause is a list whose first element says what to wait for, followed by an optional body:
+//
+//	((SEND CHANNEL VALUE) BODY)
+//	((RECV CHANNEL [VAR]) BODY)
+//	(DEFAULT BODY)
+//
+// The channel and value expressions are all evaluated before waiting.
+// In a RECV clause, VAR is bound to the received value (NIL if the channel is closed) while BODY is evaluated.
+// The value of SELECT is the value of the chosen clause's BODY.
+// If there is no BODY, the value is the received value for RECV, T for SEND and NIL for DEFAULT.
+func selectFunc(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, types.Env, error) {
+	var cases []reflect.SelectCase
+	var bodies []types.Expr
+	var vars []types.Atom
+	hasDefault := false
+	for pos := 1; ; pos++ {
+		c, err := nth(pos, t)
+		if err != nil {
+			return nil, nil, err
+		}
+		if c == types.NIL {
+			break
+		}
+		clause, ok := c.(*types.SExpr)
+		if !ok {
+			return nil, nil, errors.New("SELECT clause must be a list")
+		}
+		body, err := nth(1, clause)
+		if err != nil {
+			return nil, nil, err
+		}
+		extra, err := nth(2, clause)
+		if err != nil {
+			return nil, nil, err
+		}
+		if extra != types.NIL {
+			return nil, nil, errors.New("SELECT clause can only have a single body expression")
+		}
+		if clause.Left == types.Atom("DEFAULT") {
+			if hasDefault {
+				return nil, nil, errors.New("SELECT can only have one DEFAULT clause")
+			}
+			hasDefault = true
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+			bodies = append(bodies, body)
+			vars = append(vars, "")
+			continue
+		}
+		op, ok := clause.Left.(*types.SExpr)
+		if !ok {
+			return nil, nil, errors.New("SELECT clause must start with (SEND ...), (RECV ...) or DEFAULT")
+		}
+		switch op.Left {
+		case types.Atom("SEND"):
+			vals, err := in.checkedParams("SEND", op, env, 2, 2)
+			if err != nil {
+				return nil, nil, err
+			}
+			ch, err := asChannel("SEND", vals[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch.Ch), Send: reflect.ValueOf(&vals[1]).Elem()})
+			vars = append(vars, "")
+		case types.Atom("RECV"):
+			chExpr, err := nth(1, op)
+			if err != nil {
+				return nil, nil, err
+			}
+			if chExpr == types.NIL {
+				return nil, nil, errors.New("missing parameters for RECV")
+			}
+			v, err := nth(2, op)
+			if err != nil {
+				return nil, nil, err
+			}
+			varName := types.Atom("")
+			if v != types.NIL {
+				varName, ok = v.(types.Atom)
+				if !ok {
+					return nil, nil, errors.New("RECV variable name must be an Atom")
+				}
+			}
+			cv, err := in.evalInner(chExpr, env)
+			if err != nil {
+				return nil, nil, err
+			}
+			ch, err := asChannel("RECV", cv)
+			if err != nil {
+				return nil, nil, err
+			}
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.Ch)})
+			vars = append(vars, varName)
+		default:
+			return nil, nil, errors.New("SELECT clause must start with (SEND ...), (RECV ...) or DEFAULT")
+		}
+		bodies = append(bodies, body)
+	}
+	if len(cases) == 0 {
+		return nil, nil, errors.New("missing clauses for SELECT")
+	}
+
+	chosen, recvVal, recvOK, err := doSelect(cases)
+	if err != nil {
+		return nil, nil, err
+	}
+	in.log("SELECT chose clause ", chosen+1)
+	body := bodies[chosen]
+	switch cases[chosen].Dir {
+	case reflect.SelectRecv:
+		var v types.Expr = types.EMPTY
+		if recvOK {
+			v = recvVal.Interface().(types.Expr)
+		}
+		if body == types.NIL {
+			return v, nil, nil
+		}
+		if vars[chosen] == "" {
+			return body, env, nil
+		}
+		le := types.NewLocalEnv(env)
+		le.Define(vars[chosen], v)
+		return body, le, nil
+	case reflect.SelectSend:
+		if body == types.NIL {
+			return types.T, nil, nil
+		}
+	default:
+		if body == types.NIL {
+			return types.EMPTY, nil, nil
+		}
+	}
+	return body, env, nil
+}
+
+func doSelect(cases []reflect.SelectCase) (chosen int, recvVal reflect.Value, recvOK bool, err error) {
+	defer closedChannelError("SEND", &err)
+	chosen, recvVal, recvOK = reflect.Select(cases)
+	return chosen, recvVal, recvOK, nil
+}
@@ -0,0 +1,143 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	registerTail("DESTRUCTURING-BIND", destructuringBind)
+}
+
+// Patterns pull lists apart. They are used in LET bindings, in the required parameters of a LAMBDA or
+// DEFMACRO, and in DESTRUCTURING-BIND. A symbol matches any value. A list matches a list with the same
+// number of elements, where each element matches the pattern in the same place. A dotted tail, like
+// the C in (A B . C), matches the rest of the list, and so does a pattern after &REST, like the C in
+// (A B &REST C). () matches the empty list. The other lambda list keywords can't be used in a pattern.
+
+// destructure binds the symbols in pattern to the matching parts of value in env.
+func destructure(pattern, value types.Expr, env *types.LocalEnv) error {
+	switch p := pattern.(type) {
+	case types.Atom:
+		env.Define(p, value)
+		return nil
+	case *types.SExpr:
+		var pCur types.Expr = p
+		vCur := value
+		for {
+			pCell, ok := pCur.(*types.SExpr)
+			if !ok || isEmpty(pCell) {
+				if a, ok := pCur.(types.Atom); ok {
+					env.Define(a, listTail(vCur))
+					return nil
+				}
+				if !isEmpty(vCur) {
+					return fmt.Errorf("%s doesn't match the pattern %s: too many values", value, pattern)
+				}
+				return nil
+			}
+			rest, isRest, err := restPattern(pCell)
+			if err != nil {
+				return err
+			}
+			if isRest {
+				return destructure(rest, listTail(vCur), env)
+			}
+			vCell, ok := vCur.(*types.SExpr)
+			if !ok || isEmpty(vCell) {
+				if isEmpty(vCur) {
+					return fmt.Errorf("%s doesn't match the pattern %s: not enough values", value, pattern)
+				}
+				return fmt.Errorf("%s doesn't match the pattern %s: not a list", value, pattern)
+			}
+			if err := destructure(pCell.Left, vCell.Left, env); err != nil {
+				return err
+			}
+			pCur, vCur = pCell.Right, vCell.Right
+		}
+	}
+	return fmt.Errorf("%s can't be used in a pattern", pattern)
+}
+
+// restPattern returns the pattern that follows &REST, if p starts with &REST
+func restPattern(p *types.SExpr) (types.Expr, bool, error) {
+	if p.Left != types.Atom("&REST") {
+		return nil, false, nil
+	}
+	next, ok := p.Right.(*types.SExpr)
+	if !ok || isEmpty(next) || next.Right != types.NIL {
+		return nil, false, errors.New("&REST must be followed by a single pattern")
+	}
+	return next.Left, true, nil
+}
+
+// patternNames calls add with each of the symbols in pattern, and checks that pattern is made of lists and symbols.
+func patternNames(pattern types.Expr, add func(types.Atom) error) error {
+	for {
+		switch p := pattern.(type) {
+		case types.Atom:
+			if _, ok := lambdaListMarkers[p]; ok {
+				return fmt.Errorf("%s is in the wrong place in a pattern", p)
+			}
+			return add(p)
+		case types.Nil:
+			return nil
+		case *types.SExpr:
+			if isEmpty(p) {
+				return nil
+			}
+			rest, isRest, err := restPattern(p)
+			if err != nil {
+				return err
+			}
+			if isRest {
+				return patternNames(rest, add)
+			}
+			if err := patternNames(p.Left, add); err != nil {
+				return err
+			}
+			pattern = p.Right
+		default:
+			return fmt.Errorf("%s can't be used in a pattern", pattern)
+		}
+	}
+}
+
+// destructuringBind evaluates EXPR, binds the symbols in PATTERN to the matching parts of the value,
+// and evaluates the BODY expressions. The last one is in tail position.
+// (DESTRUCTURING-BIND PATTERN EXPR BODY...)
+func destructuringBind(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, types.Env, error) {
+	a1, ok := t.Right.(*types.SExpr)
+	if !ok {
+		return nil, nil, errors.New("missing pattern for DESTRUCTURING-BIND")
+	}
+	a2, ok := a1.Right.(*types.SExpr)
+	if !ok {
+		return nil, nil, errors.New("missing expression for DESTRUCTURING-BIND")
+	}
+	seen := map[types.Atom]bool{}
+	err := patternNames(a1.Left, func(name types.Atom) error {
+		if seen[name] {
+			return fmt.Errorf("%s appears more than once in the pattern for DESTRUCTURING-BIND", name)
+		}
+		seen[name] = true
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := in.evalArg(a2, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	innerEnv := types.NewLocalEnv(env)
+	if err := destructure(a1.Left, value, innerEnv); err != nil {
+		return nil, nil, err
+	}
+	if a2.Right == types.NIL {
+		return types.EMPTY, nil, nil
+	}
+	return bodyExpr(a2.Right), innerEnv, nil
+}
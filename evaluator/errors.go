@@ -0,0 +1,99 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+// EvalError is an error that happened while evaluating code.
+// Pos is the position of the innermost expression that was being evaluated, if it came from the parser.
+// Trace is the backtrace: the calls of the Lisp functions that were running when the error happened,
+// innermost call first.
+type EvalError struct {
+	Pos   types.Pos
+	Err   error
+	Trace []Frame
+}
+
+func (e *EvalError) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Err.Error()
+	}
+	return e.Pos.String() + ": " + e.Err.Error()
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// Position returns where the error happened
+func (e *EvalError) Position() types.Pos {
+	return e.Pos
+}
+
+// Frame is a call of a Lisp function in a backtrace. Name is the name the function was called by,
+// or LAMBDA if it doesn't have one. Call is the expression that called it, and Pos is where that expression is.
+type Frame struct {
+	Name types.Atom
+	Call types.Expr
+	Pos  types.Pos
+}
+
+func (f Frame) String() string {
+	if !f.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", f.Name, f.Call)
+	}
+	return fmt.Sprintf("%s: %s at %s", f.Name, f.Call, f.Pos)
+}
+
+// positioned is an error that knows where it happened, like an EvalError or a parser.ParseError
+type positioned interface {
+	error
+	Position() types.Pos
+}
+
+// withPos adds the start of span to err, unless err is nil, already has a position, or span isn't known
+func withPos(err error, span *types.Span) error {
+	if err == nil || span == nil || !span.Start.IsValid() {
+		return err
+	}
+	if hasPos(err) {
+		return err
+	}
+	var ee *EvalError
+	if errors.As(err, &ee) {
+		ee.Pos = span.Start
+		return err
+	}
+	return &EvalError{Pos: span.Start, Err: err}
+}
+
+// hasPos reports whether err, or an error that it wraps, knows where it happened
+func hasPos(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if p, ok := err.(positioned); ok && p.Position().IsValid() {
+			return true
+		}
+	}
+	return false
+}
+
+// withFrame adds the call of the function name to the backtrace of err. If call is nil, err isn't changed.
+func withFrame(err error, name types.Atom, call *types.SExpr) error {
+	if call == nil {
+		return err
+	}
+	f := Frame{Name: name, Call: call}
+	if call.Span != nil {
+		f.Pos = call.Span.Start
+	}
+	var ee *EvalError
+	if !errors.As(err, &ee) {
+		ee = &EvalError{Err: err}
+		err = ee
+	}
+	ee.Trace = append(ee.Trace, f)
+	return err
+}
@@ -1,16 +1,13 @@
 package evaluator
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io"
-	"math/big"
 	"os"
+	"reflect"
 
-	"github.com/jonbodner/my_lisp/global"
-	"github.com/jonbodner/my_lisp/parser"
-	"github.com/jonbodner/my_lisp/scanner"
+	"github.com/jonbodner/my_lisp/reader"
 	"github.com/jonbodner/my_lisp/types"
 )
 
@@ -78,116 +75,228 @@ evaluated, f is replaced by (LABEL f (LAMBDA (v1 ... vn) e)).
 Lists beginning with LABEL define functions recursively.
 */
 
-type Evaluator func(*types.SExpr, types.Env) (types.Expr, error)
+// Evaluator is the implementation of a built-in function or special form.
+// It is passed the entire expression, including the name, with the parameters unevaluated.
+type Evaluator func(*Interpreter, *types.SExpr, types.Env) (types.Expr, error)
 
-var TopLevel = make(types.GlobalEnv)
+// tailEvaluator is used for special forms whose value is the value of one of their parameters.
+// Rather than evaluating that parameter, it returns the parameter and the environment to evaluate it in,
+// so that evalInner can evaluate it without growing the stack.
+// If the returned environment is nil, the returned expression is the final value.
+type tailEvaluator func(*Interpreter, *types.SExpr, types.Env) (types.Expr, types.Env, error)
 
-var BuiltIn map[types.Atom]Evaluator
+// BuiltIn and tailBuiltIn hold the built-in functions and special forms that every new Interpreter starts with.
+// They are created empty so that the init functions in every file
+// can add to them, no matter which order they run in
+var BuiltIn = map[types.Atom]Evaluator{}
+
+var tailBuiltIn = map[types.Atom]tailEvaluator{}
 
 func init() {
-	TopLevel[types.T] = types.T
-	TopLevel[types.Atom("NIL")] = types.EMPTY
-
-	BuiltIn = map[types.Atom]Evaluator{
-		"QUOTE":     quote,
-		"CAR":       car,
-		"CDR":       cdr,
-		"CONS":      cons,
-		"ATOM":      atom,
-		"EQ":        equal,
-		"COND":      cond,
-		"LABEL":     label,
-		"SETQ":      setq,
-		"LAMBDA":    lambda,
-		"PROGN":     progn,
-		"LET":       let,
-		"**DEBUG**": debug,
-		"LOAD":      load,
-		"STORE":     store,
-		"DELETE":    deleteFunc,
+	BuiltIn["QUOTE"] = quote
+	BuiltIn["CAR"] = car
+	BuiltIn["CDR"] = cdr
+	BuiltIn["CONS"] = cons
+	BuiltIn["ATOM"] = atom
+	BuiltIn["EQ"] = equal
+	BuiltIn["LABEL"] = label
+	BuiltIn["SETQ"] = setq
+	BuiltIn["LAMBDA"] = lambda
+	BuiltIn["**DEBUG**"] = debug
+	BuiltIn["LOAD"] = load
+	BuiltIn["STORE"] = store
+	BuiltIn["DELETE"] = deleteFunc
+
+	registerTail("COND", cond)
+	registerTail("PROGN", progn)
+	registerTail("LET", let)
+}
+
+// registerTail adds a special form that evaluates its result in tail position
+func registerTail(name types.Atom, te tailEvaluator) {
+	tailBuiltIn[name] = te
+	BuiltIn[name] = completeTail(te)
+}
+
+// completeTail turns a tailEvaluator into an Evaluator that returns the final value
+func completeTail(te tailEvaluator) Evaluator {
+	return func(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+		next, nextEnv, err := te(in, t, env)
+		if err != nil {
+			return nil, err
+		}
+		if nextEnv == nil {
+			return next, nil
+		}
+		return in.evalInner(next, nextEnv)
 	}
 }
 
+// Eval evaluates an expression using the default Interpreter
 func Eval(e types.Expr) (types.Expr, error) {
-	return evalInner(e, TopLevel)
+	return Default().Eval(e)
+}
+
+func (in *Interpreter) evalInner(e types.Expr, env types.Env) (types.Expr, error) {
+	var st loopState
+	result, err := in.evalLoop(e, env, &st)
+	if err != nil {
+		return nil, withFrame(withPos(err, st.span), st.name, st.call)
+	}
+	return result, nil
 }
 
-var depth = 0
+// loopState is what evalLoop records so that errors can say where they happened
+type loopState struct {
+	//span is where the innermost list being evaluated came from
+	span *types.Span
+	//call is the call of the function whose body is being evaluated, and name is the function's name.
+	//A call in tail position replaces the function that made it, so it won't show up in a backtrace.
+	call *types.SExpr
+	name types.Atom
+}
 
-func evalInner(e types.Expr, env types.Env) (types.Expr, error) {
-	depth++
-	defer func() {
-		global.Log("done depth ", depth)
-		depth--
-	}()
-	global.Log("at depth ", depth)
-	global.Log("Evaluating ", e)
-	switch t := e.(type) {
-	case types.Atom:
-		global.Log("\tGot an types.Atom")
-		//check if number, and if so return self
-		r := &big.Rat{}
-		_, ok := r.SetString(string(t))
-		if ok {
-			return t, nil
-		}
-		//look up variable value in context and return that
-		expr, ok := env.Get(t)
-		if ok {
-			return expr, nil
-		}
-		return nil, fmt.Errorf("unknown symbol %s ", t)
-	case *types.SExpr:
-		global.Log("\tGot an types.SExpr")
-		switch a := t.Left.(type) {
+func (in *Interpreter) evalLoop(e types.Expr, env types.Env, st *loopState) (types.Expr, error) {
+	//callSite and callName are the call whose function is being looked up, before it's replaced by the function
+	var callSite *types.SExpr
+	var callName types.Atom
+	//expressions in tail position are evaluated by going around the loop again
+	//rather than by recursing, so that the stack doesn't grow
+	for {
+		in.log("Evaluating ", e)
+		switch t := e.(type) {
 		case types.Atom:
-			global.Log("\t\tLeft is an types.Atom")
-			evaluator, ok := BuiltIn[a]
+			in.log("\tGot an types.Atom")
+			//look up variable value in context and return that
+			expr, ok := env.Get(t)
 			if ok {
-				return evaluator(t, env)
+				return expr, nil
 			}
-			global.Log("not a builtin")
-			//look up variable value in context and process that
-			global.Log("looking up ", a)
-			expr, ok := env.Get(a)
-			if !ok {
-				return nil, fmt.Errorf("unknown symbol %s ", a)
-			}
-			//replace the atom with the value of the expression
-			result, err := evalInner(expr, env)
-			global.Log("done evaluating")
-			if err != nil {
-				return nil, err
+			if t.IsKeyword() {
+				return t, nil
 			}
-			newT := &types.SExpr{Left: result, Right: t.Right}
-			return evalInner(newT, env)
+			return nil, fmt.Errorf("unknown symbol %s ", t)
 		case *types.SExpr:
-			global.Log("\t\tLeft is an types.SExpr")
-			//evaluate the left, then replace left with the evaluated value, and recurse
-			lResult, err := evalInner(t.Left, env)
-			if err != nil {
-				return nil, err
+			in.log("\tGot an types.SExpr")
+			if t.Span != nil {
+				st.span = t.Span
 			}
-			t.Left = lResult
-			return evalInner(t, env)
-		case types.Nil:
-			global.Log("Got a nil left")
+			switch a := t.Left.(type) {
+			case types.Atom:
+				in.log("\t\tLeft is an types.Atom")
+				if tailEvaluator, ok := in.tailBuiltIn[a]; ok {
+					next, nextEnv, err := tailEvaluator(in, t, env)
+					if err != nil {
+						return nil, err
+					}
+					if nextEnv == nil {
+						return next, nil
+					}
+					e, env = next, nextEnv
+					continue
+				}
+				evaluator, ok := in.builtIn[a]
+				if ok {
+					return evaluator(in, t, env)
+				}
+				in.log("not a builtin")
+				//look up variable value in context and process that
+				in.log("looking up ", a)
+				expr, ok := env.Get(a)
+				if !ok {
+					return nil, fmt.Errorf("unknown symbol %s ", a)
+				}
+				//replace the atom with the value of the expression
+				result, err := in.evalInner(expr, env)
+				in.log("done evaluating")
+				if err != nil {
+					return nil, err
+				}
+				if result == a {
+					return nil, fmt.Errorf("%s is not a function", a)
+				}
+				callSite, callName = t, a
+				e = &types.SExpr{Left: result, Right: t.Right}
+			case *types.SExpr:
+				in.log("\t\tLeft is an types.SExpr")
+				//evaluate the left, then replace left with the evaluated value, and go again
+				lResult, err := in.evalInner(t.Left, env)
+				if err != nil {
+					return nil, err
+				}
+				callSite, callName = t, "LAMBDA"
+				e = &types.SExpr{Left: lResult, Right: t.Right}
+			case types.Nil:
+				in.log("Got a nil left")
+				return t, nil
+			case types.Lambda:
+				in.log("\t\tLeft is a types.Lambda")
+				if callSite == nil {
+					callSite, callName = t, "LAMBDA"
+				}
+				le, err := in.lambdaEnv(callName, a, t, env)
+				if err != nil {
+					return nil, err
+				}
+				st.call, st.name = callSite, callName
+				callSite = nil
+				//call body with new environment
+				e, env = a.Body, le
+			case types.Macro:
+				in.log("\t\tLeft is a types.Macro")
+				name := types.Atom("MACRO")
+				if callSite != nil && callName != "LAMBDA" {
+					name = callName
+				}
+				callSite = nil
+				expanded, err := in.expandMacro(name, a, t)
+				if err != nil {
+					return nil, err
+				}
+				in.log("expanded to ", expanded)
+				e = expanded
+			default:
+				return nil, fmt.Errorf("%s is not a function", a)
+			}
+		case types.Number, types.String, types.Channel, types.GoValue, *types.Error:
+			in.log("\tGot a self-evaluating value")
+			return t, nil
+		case *types.Map:
+			in.log("\tGot a map")
+			//a map literal makes a new map each time it's evaluated, so changing one doesn't change the code
+			out, _ := copyLiteral(t)
+			return out, nil
+		case *types.Vector:
+			in.log("\tGot a vector")
+			//like a map literal, a vector literal makes a new vector each time it's evaluated
+			out, _ := copyLiteral(t)
+			return out, nil
+		case *types.Set:
+			in.log("\tGot a set")
+			//and so does a set literal
+			return t.Copy(), nil
+		case *types.Struct:
+			in.log("\tGot a struct")
+			//and a struct literal
+			out, _ := copyLiteral(t)
+			return out, nil
+		case *types.TransientMap, *types.TransientVector:
+			in.log("\tGot a transient")
+			//there are no transient literals, so a transient is always the one that TRANSIENT made
 			return t, nil
 		case types.Lambda:
-			global.Log("\t\tLeft is a types.Lambda")
-			return processLambda(a, t, env)
+			in.log("\tGot a lambda")
+			return t, nil
+		case types.Macro:
+			in.log("\tGot a macro")
+			return t, nil
 		default:
-			return nil, errors.New("shouldn't get here")
+			return nil, errors.New("don't know how I got here")
 		}
-	case types.Lambda:
-		global.Log("\tGot a lambda")
-		return t, nil
 	}
-
-	return nil, errors.New("don't know how I got here")
 }
 
-func quote(t *types.SExpr, _ types.Env) (types.Expr, error) {
+func quote(in *Interpreter, t *types.SExpr, _ types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameter for QUOTE")
 	}
@@ -205,7 +314,7 @@ func quote(t *types.SExpr, _ types.Env) (types.Expr, error) {
 	}
 }
 
-func car(t *types.SExpr, env types.Env) (types.Expr, error) {
+func car(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameter for CAR")
 	}
@@ -217,23 +326,21 @@ func car(t *types.SExpr, env types.Env) (types.Expr, error) {
 		if a2.Right != types.NIL {
 			return nil, errors.New("shouldn't have more than one parameter for CAR")
 		}
-		e2, err := evalInner(a2.Left, env)
+		e2, err := in.evalArg(a2, env)
 		if err != nil {
 			return nil, err
 		}
-		switch a3 := e2.(type) {
-		case types.Atom:
+		a3, ok := e2.(*types.SExpr)
+		if !ok {
 			return nil, errors.New("CAR parameter must be a list")
-		case *types.SExpr:
-			return a3.Left, nil
 		}
+		return a3.Left, nil
 	default:
 		return nil, fmt.Errorf("unknown types.Expr type found: %types.T", a2)
 	}
-	return nil, errors.New("should never get here")
 }
 
-func cdr(t *types.SExpr, env types.Env) (types.Expr, error) {
+func cdr(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameter for CDR")
 	}
@@ -245,23 +352,21 @@ func cdr(t *types.SExpr, env types.Env) (types.Expr, error) {
 		if a2.Right != types.NIL {
 			return nil, errors.New("shouldn't have more than one parameter for CDR")
 		}
-		e2, err := evalInner(a2.Left, env)
+		e2, err := in.evalArg(a2, env)
 		if err != nil {
 			return nil, err
 		}
-		switch a3 := e2.(type) {
-		case types.Atom:
+		a3, ok := e2.(*types.SExpr)
+		if !ok {
 			return nil, errors.New("CDR parameter must be a list")
-		case *types.SExpr:
-			return a3.Right, nil
 		}
+		return a3.Right, nil
 	default:
 		return nil, fmt.Errorf("unknown types.Expr type found: %types.T", a2)
 	}
-	return nil, errors.New("should never get here")
 }
 
-func cons(t *types.SExpr, env types.Env) (types.Expr, error) {
+func cons(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	//must have two params
 	//going to construct a types.SExpr out of them
 	//first is going to be the left, second is going to be the right
@@ -272,7 +377,7 @@ func cons(t *types.SExpr, env types.Env) (types.Expr, error) {
 	case types.Atom:
 		return nil, errors.New("CONS parameter must be a list")
 	case *types.SExpr:
-		e2, err := evalInner(a2.Left, env)
+		e2, err := in.evalArg(a2, env)
 		if err != nil {
 			return nil, err
 		}
@@ -287,7 +392,7 @@ func cons(t *types.SExpr, env types.Env) (types.Expr, error) {
 			if a3.Right != types.NIL {
 				return nil, errors.New("must have two parameters for CONS")
 			}
-			e3, err := evalInner(a3.Left, env)
+			e3, err := in.evalArg(a3, env)
 			if err != nil {
 				return nil, err
 			}
@@ -302,7 +407,7 @@ func cons(t *types.SExpr, env types.Env) (types.Expr, error) {
 	return nil, errors.New("shouldn't get here")
 }
 
-func atom(t *types.SExpr, env types.Env) (types.Expr, error) {
+func atom(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameter for ATOM")
 	}
@@ -314,12 +419,13 @@ func atom(t *types.SExpr, env types.Env) (types.Expr, error) {
 		if a2.Right != types.NIL {
 			return nil, errors.New("shouldn't have more than one parameter for ATOM")
 		}
-		e2, err := evalInner(a2.Left, env)
+		e2, err := in.evalArg(a2, env)
 		if err != nil {
 			return nil, err
 		}
 		switch a3 := e2.(type) {
-		case types.Atom:
+		case types.Atom, types.Number, types.String, types.Channel, types.GoValue, *types.Map, *types.Vector, *types.Set, *types.Struct,
+			*types.TransientMap, *types.TransientVector, *types.Error, types.Lambda, types.Nil:
 			return types.T, nil
 		case *types.SExpr:
 			if a3.Left == types.NIL && a3.Right == types.NIL {
@@ -333,7 +439,7 @@ func atom(t *types.SExpr, env types.Env) (types.Expr, error) {
 	return nil, errors.New("shouldn't get here")
 }
 
-func equal(t *types.SExpr, env types.Env) (types.Expr, error) {
+func equal(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	//must have two params
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameters for EQUAL")
@@ -342,7 +448,7 @@ func equal(t *types.SExpr, env types.Env) (types.Expr, error) {
 	case types.Atom:
 		return nil, errors.New("EQUAL parameter must be a list")
 	case *types.SExpr:
-		e2, err := evalInner(a2.Left, env)
+		e2, err := in.evalArg(a2, env)
 		if err != nil {
 			return nil, err
 		}
@@ -357,7 +463,7 @@ func equal(t *types.SExpr, env types.Env) (types.Expr, error) {
 			if a3.Right != types.NIL {
 				return nil, errors.New("must have two parameters for EQUAL")
 			}
-			e3, err := evalInner(a3.Left, env)
+			e3, err := in.evalArg(a3, env)
 			if err != nil {
 				return nil, err
 			}
@@ -372,32 +478,33 @@ func equal(t *types.SExpr, env types.Env) (types.Expr, error) {
 	return nil, errors.New("shouldn't get here")
 }
 
-func cond(t *types.SExpr, env types.Env) (types.Expr, error) {
+func cond(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, types.Env, error) {
 	//find the first non-types.NIL result, and return it
 	pos := 1
 	for {
 		cur, err := nth(pos, t)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		switch cur := cur.(type) {
 		case types.Atom:
-			return nil, errors.New("cannot have an atom as a COND parameter")
+			return nil, nil, errors.New("cannot have an atom as a COND parameter")
 		case types.Nil:
-			return types.EMPTY, nil
+			return types.EMPTY, nil, nil
 		case *types.SExpr:
-			car, err := evalInner(cur.Left, env)
+			car, err := in.evalArg(cur, env)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			if !isEqual(car, types.EMPTY) {
 				switch result := cur.Right.(type) {
 				case types.Atom:
-					return nil, errors.New("cannot have a dotted pair here")
+					return nil, nil, errors.New("cannot have a dotted pair here")
 				case types.Nil:
-					return types.EMPTY, nil
+					return types.EMPTY, nil, nil
 				case *types.SExpr:
-					return evalInner(result.Left, env)
+					//the chosen branch is in tail position
+					return result.Left, env, nil
 				}
 			}
 		}
@@ -405,7 +512,7 @@ func cond(t *types.SExpr, env types.Env) (types.Expr, error) {
 	}
 }
 
-func label(t *types.SExpr, env types.Env) (types.Expr, error) {
+func label(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	//must have two params
 	//first must be an atom
 	//second can be any expression
@@ -439,7 +546,7 @@ func label(t *types.SExpr, env types.Env) (types.Expr, error) {
 	return nil, errors.New("shouldn't get here")
 }
 
-func setq(t *types.SExpr, env types.Env) (types.Expr, error) {
+func setq(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	//must have two params
 	//first must be an atom
 	//second can be any expression
@@ -466,7 +573,7 @@ func setq(t *types.SExpr, env types.Env) (types.Expr, error) {
 			return nil, errors.New("must have two parameters for SETQ")
 		}
 		//a2.Right.Left can be anything
-		lval, err := evalInner(a3.Left, env)
+		lval, err := in.evalArg(a3, env)
 		if err != nil {
 			return nil, err
 		}
@@ -476,7 +583,7 @@ func setq(t *types.SExpr, env types.Env) (types.Expr, error) {
 	return nil, errors.New("shouldn't get here")
 }
 
-func lambda(t *types.SExpr, env types.Env) (types.Expr, error) {
+func lambda(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	//must have 2 params
 	//param 1 is a list of parameters
 	params, err := nth(1, t)
@@ -491,8 +598,7 @@ func lambda(t *types.SExpr, env types.Env) (types.Expr, error) {
 		return nil, errors.New("LAMBDA parameter list must be a List")
 	}
 
-	//copy into slice of Atoms
-	aList, err := listToSlice(l)
+	lambda, err := parseLambdaList("LAMBDA", l)
 	if err != nil {
 		return nil, err
 	}
@@ -507,12 +613,12 @@ func lambda(t *types.SExpr, env types.Env) (types.Expr, error) {
 	}
 	//a2.Right.Left can be anything
 	//returns a new types.Expr type, a types.Lambda, which has its own env
-	lambda := types.Lambda{ParentEnv: env, Body: body, Params: aList}
+	lambda.ParentEnv, lambda.Body = env, body
 	return lambda, nil
 }
 
 // load the environment from the named file. Existing symbols will be overwritten.
-func load(t *types.SExpr, env types.Env) (types.Expr, error) {
+func load(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameter for LOAD")
 	}
@@ -522,24 +628,22 @@ func load(t *types.SExpr, env types.Env) (types.Expr, error) {
 		if a2.Right != types.NIL {
 			return nil, errors.New("shouldn't have more than one parameter for LOAD")
 		}
-		e2, err := evalInner(a2.Left, env)
+		e2, err := in.evalArg(a2, env)
 		if err != nil {
 			return nil, err
 		}
 		switch a3 := e2.(type) {
-		case types.Atom:
-			f, err := os.Open(string(a3))
+		case types.Atom, types.String:
+			f, err := os.Open(fileName(a3))
 			if err != nil {
 				return nil, err
 			}
 			defer f.Close()
-			newEnv, err := internalRepl(f)
+			newEnv, err := in.internalRepl(fileName(a3), f)
 			if err != nil {
 				return nil, err
 			}
-			for k, v := range newEnv {
-				TopLevel[k] = v
-			}
+			in.topLevel.Merge(newEnv)
 			return types.T, nil
 		default:
 			return nil, errors.New("LOAD parameter must evaluate to a single value")
@@ -548,53 +652,33 @@ func load(t *types.SExpr, env types.Env) (types.Expr, error) {
 	return nil, errors.New("shouldn't get here")
 }
 
-func internalRepl(r io.Reader) (types.GlobalEnv, error) {
-	newEnv := types.GlobalEnv{}
-	newEnv[types.T] = types.T
-	newEnv[types.Atom("NIL")] = types.EMPTY
+// internalRepl evaluates the expressions read from r in a new environment, and returns the environment.
+// name is the file name used in the positions of errors.
+func (in *Interpreter) internalRepl(name string, r io.Reader) (*types.GlobalEnv, error) {
+	newEnv := types.NewGlobalEnv()
+	newEnv.Put(types.T, types.T)
+	newEnv.Put(types.Atom("NIL"), types.EMPTY)
 
-	bio := bufio.NewReader(r)
-	done := false
-	depth := 0
-	var tokens []types.Token
-	for !done {
-		line, err := bio.ReadString('\n')
+	rd := reader.New(name, r)
+	for {
+		expr, err := rd.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			if err != io.EOF {
-				return nil, err
-			}
-			done = true
-			continue
-		}
-		newTokens, newDepth := scanner.Scan(line)
-		depth = depth + newDepth
-		if depth < 0 {
-			return nil, errors.New("invalid -- Too many closing parens")
-		}
-		tokens = append(tokens, newTokens...)
-		if depth == 0 {
-			//global.Log(tokens)
-			expr, _, err := parser.Parse(tokens)
-			//global.Log(expr)
-			//global.Log(pos)
-			//global.Log(err)
-			if err != nil {
-				global.Log(err)
-			} else {
-				result, err := evalInner(expr, newEnv)
-				if err != nil {
-					return nil, err
-				}
-				fmt.Println(result)
-			}
-			tokens = []types.Token{}
+			return nil, err
+		}
+		result, err := in.evalInner(expr, newEnv)
+		if err != nil {
+			return nil, err
 		}
+		fmt.Fprintln(in.out, result)
 	}
 	return newEnv, nil
 }
 
 // take the current environment and write it out to the named file (second parameter)
-func store(t *types.SExpr, env types.Env) (types.Expr, error) {
+func store(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameter for STORE")
 	}
@@ -604,18 +688,18 @@ func store(t *types.SExpr, env types.Env) (types.Expr, error) {
 		if a2.Right != types.NIL {
 			return nil, errors.New("shouldn't have more than one parameter for STORE")
 		}
-		e2, err := evalInner(a2.Left, env)
+		e2, err := in.evalArg(a2, env)
 		if err != nil {
 			return nil, err
 		}
 		switch a3 := e2.(type) {
-		case types.Atom:
-			f, err := os.Create(string(a3))
+		case types.Atom, types.String:
+			f, err := os.Create(fileName(a3))
 			if err != nil {
 				return nil, err
 			}
 			defer f.Close()
-			_, err = f.WriteString(TopLevel.String())
+			_, err = f.WriteString(in.topLevel.String())
 			if err != nil {
 				return nil, err
 			}
@@ -627,7 +711,15 @@ func store(t *types.SExpr, env types.Env) (types.Expr, error) {
 	return nil, errors.New("shouldn't get here")
 }
 
-func deleteFunc(t *types.SExpr, env types.Env) (types.Expr, error) {
+// fileName returns the name of the file for LOAD or STORE, which can be given as an atom or a string
+func fileName(e types.Expr) string {
+	if s, ok := e.(types.String); ok {
+		return string(s)
+	}
+	return e.String()
+}
+
+func deleteFunc(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameter for DELETE")
 	}
@@ -637,7 +729,7 @@ func deleteFunc(t *types.SExpr, env types.Env) (types.Expr, error) {
 		if a2.Right != types.NIL {
 			return nil, errors.New("shouldn't have more than one parameter for DELETE")
 		}
-		e2, err := evalInner(a2.Left, env)
+		e2, err := in.evalArg(a2, env)
 		if err != nil {
 			return nil, err
 		}
@@ -652,28 +744,28 @@ func deleteFunc(t *types.SExpr, env types.Env) (types.Expr, error) {
 	return nil, errors.New("shouldn't get here")
 }
 
-func debug(t *types.SExpr, env types.Env) (types.Expr, error) {
+func debug(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	param, err := nth(1, t)
 	if err != nil {
 		return nil, err
 	}
-	v, err := evalInner(param, env)
+	v, err := in.evalInner(param, env)
 	if err != nil {
 		return nil, err
 	}
-	global.Log("param is ", param)
-	global.Log("v is ", v)
+	in.log("param is ", param)
+	in.log("v is ", v)
 	if v == types.T {
-		global.Debug = true
+		in.debug.Store(true)
 	} else if v == types.EMPTY {
-		global.Debug = false
+		in.debug.Store(false)
 	} else {
 		return nil, errors.New("unknown debug value. Valid values are types.T and types.NIL")
 	}
 	return types.T, nil
 }
 
-func let(t *types.SExpr, env types.Env) (types.Expr, error) {
+func let(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, types.Env, error) {
 	//has two params,
 	//a list of two-element lists with the scoped variables
 	//the command to run with those variables
@@ -684,30 +776,30 @@ func let(t *types.SExpr, env types.Env) (types.Expr, error) {
 	//outer scope will modify that outer scope.
 	variables, err := nth(1, t)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if variables == types.NIL {
-		return nil, errors.New("missing variables for LET")
+		return nil, nil, errors.New("missing variables for LET")
 	}
 	l, ok := variables.(*types.SExpr)
 	if !ok {
-		return nil, errors.New("LET variable list must be a List")
+		return nil, nil, errors.New("LET variable list must be a List")
 	}
-	innerEnv, err := buildInnerEnv(l, env)
+	innerEnv, err := in.buildInnerEnv(l, env)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	body, err := nth(2, t)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return evalInner(body, innerEnv)
+	//the body is in tail position
+	return body, innerEnv, nil
 }
 
-func buildInnerEnv(l *types.SExpr, env types.Env) (types.Env, error) {
-	global.Log("var list == ", l)
-	vals := map[types.Atom]types.Expr{}
-	innerEnv := types.LocalEnv{Vals: vals, Parent: env}
+func (in *Interpreter) buildInnerEnv(l *types.SExpr, env types.Env) (types.Env, error) {
+	in.log("var list == ", l)
+	innerEnv := types.NewLocalEnv(env)
 	i := 0
 	for {
 		cv, err := nth(i, l)
@@ -725,83 +817,217 @@ func buildInnerEnv(l *types.SExpr, env types.Env) (types.Env, error) {
 		if err != nil {
 			return nil, err
 		}
-		varName, ok := vn.(types.Atom)
-		if !ok {
-			return nil, errors.New("LET variable names must be Atoms")
+		//the name can also be a pattern that pulls apart the value
+		if err := patternNames(vn, func(types.Atom) error { return nil }); err != nil || vn == types.NIL {
+			return nil, errors.New("LET variable names must be Atoms or patterns")
 		}
 		varVal, err := nth(1, curVar)
 		if err != nil {
 			return nil, err
 		}
-		varExpr, err := evalInner(varVal, innerEnv)
+		varExpr, err := in.evalInner(varVal, innerEnv)
 		if err != nil {
 			return nil, err
 		}
-		innerEnv.Vals[varName] = varExpr
+		if err := destructure(vn, varExpr, innerEnv); err != nil {
+			return nil, err
+		}
 		i++
 	}
 	return innerEnv, nil
 }
 
+// bodyExpr returns an expression that evaluates each of the expressions in body and returns the last value,
+// for special forms that have a body of more than one expression
+func bodyExpr(body types.Expr) types.Expr {
+	return &types.SExpr{Left: types.Atom("PROGN"), Right: body}
+}
+
 // has multiple values, each evaluated one at a time
 // returns the last value
-func progn(t *types.SExpr, env types.Env) (types.Expr, error) {
-	var retval types.Expr = types.NIL
-	i := 1
+func progn(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, types.Env, error) {
+	curParam, err := nth(1, t)
+	if err != nil {
+		return nil, nil, err
+	}
+	if curParam == types.NIL {
+		return types.NIL, nil, nil
+	}
+	i := 2
 	for {
-		curParam, err := nth(i, t)
+		nextParam, err := nth(i, t)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		if curParam == types.NIL {
-			break
+		if nextParam == types.NIL {
+			//the last value is in tail position
+			return curParam, env, nil
 		}
-		retval, err = evalInner(curParam, env)
+		_, err = in.evalInner(curParam, env)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		curParam = nextParam
 		i++
 	}
-	return retval, nil
 }
 
-func listToSlice(l *types.SExpr) ([]types.Atom, error) {
-	var out []types.Atom
-
-	pos := 0
+// evalParams evaluates each of the parameters of t, in order
+func (in *Interpreter) evalParams(t *types.SExpr, env types.Env) ([]types.Expr, error) {
+	var out []types.Expr
+	cur := t.Right
 	for {
-		cur, err := nth(pos, l)
-		if err != nil {
-			return nil, err
+		switch c := cur.(type) {
+		case types.Nil:
+			return out, nil
+		case *types.SExpr:
+			v, err := in.evalArg(c, env)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			cur = c.Right
+		default:
+			return nil, errors.New("can't have a dotted pair here")
 		}
+	}
+}
 
-		if cur == types.NIL {
-			break
+// evalArg evaluates the Left of c. Errors get the position of the Left, if they don't have a position already.
+func (in *Interpreter) evalArg(c *types.SExpr, env types.Env) (types.Expr, error) {
+	v, err := in.evalInner(c.Left, env)
+	return v, withPos(err, c.LeftSpan)
+}
+
+// callFunction calls the function fn with already evaluated parameters.
+// The parameters are quoted, so that they aren't evaluated again.
+func (in *Interpreter) callFunction(fn types.Expr, vals []types.Expr, env types.Env) (types.Expr, error) {
+	args := make([]types.Expr, len(vals))
+	for i, v := range vals {
+		args[i] = quoted(v)
+	}
+	return in.evalInner(&types.SExpr{Left: fn, Right: paramList(args)}, env)
+}
+
+// copyLiteral returns a copy of e, with the maps, vectors and structs inside it copied too, so that changing a value that came from
+// a literal can't change the literal. It returns false if e has nothing that needs to be copied.
+func copyLiteral(e types.Expr) (types.Expr, bool) {
+	switch e := e.(type) {
+	case *types.Map:
+		out := e.Copy()
+		for _, entry := range e.Entries() {
+			//keys can't be maps, vectors or structs, so only the values need to be copied
+			if v, ok := copyLiteral(entry.Value); ok {
+				out.Put(entry.Key, v)
+			}
 		}
+		return out, true
+	case *types.Vector:
+		out := e.Copy()
+		for i, elem := range e.Elems() {
+			if v, ok := copyLiteral(elem); ok {
+				out.Set(i, v)
+			}
+		}
+		return out, true
+	case *types.Struct:
+		out := e.Copy()
+		for _, f := range e.Fields() {
+			v, _ := e.Get(f)
+			if v, ok := copyLiteral(v); ok {
+				out.Set(f, v)
+			}
+		}
+		return out, true
+	case *types.SExpr:
+		left, leftOK := copyLiteral(e.Left)
+		right, rightOK := copyLiteral(e.Right)
+		if !leftOK && !rightOK {
+			return e, false
+		}
+		return &types.SExpr{Left: left, Right: right}, true
+	}
+	return e, false
+}
+
+// quoted returns (QUOTE e)
+func quoted(e types.Expr) types.Expr {
+	return &types.SExpr{Left: types.Atom("QUOTE"), Right: &types.SExpr{Left: e, Right: types.NIL}}
+}
+
+// paramList builds the parameters of a call out of vals. Unlike sliceToList, there are no parameters if vals is empty.
+func paramList(vals []types.Expr) types.Expr {
+	if len(vals) == 0 {
+		return types.NIL
+	}
+	return sliceToList(vals)
+}
+
+// checkedParams evaluates the parameters for the named builtin and makes sure that there are
+// between minCount and maxCount of them. A maxCount of -1 means there is no maximum.
+func (in *Interpreter) checkedParams(name string, t *types.SExpr, env types.Env, minCount, maxCount int) ([]types.Expr, error) {
+	if t.Right == types.NIL && minCount > 0 {
+		return nil, fmt.Errorf("missing parameters for %s", name)
+	}
+	vals, err := in.evalParams(t, env)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) < minCount {
+		return nil, fmt.Errorf("%s requires at least %d parameters", name, minCount)
+	}
+	if maxCount >= 0 && len(vals) > maxCount {
+		return nil, fmt.Errorf("too many parameters for %s", name)
+	}
+	return vals, nil
+}
+
+// sliceToList builds a list out of the values in vals
+func sliceToList(vals []types.Expr) types.Expr {
+	if len(vals) == 0 {
+		return types.EMPTY
+	}
+	var out types.Expr = types.NIL
+	for i := len(vals) - 1; i >= 0; i-- {
+		out = &types.SExpr{Left: vals[i], Right: out}
+	}
+	return out
+}
 
-		c, ok := cur.(types.Atom)
+// listToExprs returns the values in the list e as a slice
+func listToExprs(e types.Expr) ([]types.Expr, error) {
+	var out []types.Expr
+	for !isEmpty(e) {
+		s, ok := e.(*types.SExpr)
 		if !ok {
-			return nil, errors.New("only Atoms can be parameter names")
+			return nil, errors.New("can't have a dotted pair here")
 		}
-
-		out = append(out, c)
-		pos++
+		out = append(out, s.Left)
+		e = s.Right
 	}
 	return out, nil
 }
 
-func processLambda(l types.Lambda, t *types.SExpr, env types.Env) (types.Expr, error) {
-	le := types.LocalEnv{Vals: make(map[types.Atom]types.Expr), Parent: l.ParentEnv}
-	//assign parameter values to parameter names
-	count := 0
+// listLength returns the number of cells in the list l
+func listLength(l types.Expr) int {
+	n := 0
+	for c, ok := l.(*types.SExpr); ok && !isEmpty(c); c, ok = c.Right.(*types.SExpr) {
+		n++
+	}
+	return n
+}
+
+// lambdaEnv evaluates the parameter values of a call to a LAMBDA and
+// builds the environment that its body runs in. name is what the LAMBDA was called, for errors.
+func (in *Interpreter) lambdaEnv(name types.Atom, l types.Lambda, t *types.SExpr, env types.Env) (types.Env, error) {
+	max := maxParams(l)
+	//evaluate the parameter values in the calling environment
+	var vals []types.Expr
 	switch paramVals := t.Right.(type) {
-	case types.Atom:
-		return nil, errors.New("can't have a dotted pair here")
 	case types.Nil:
 		//do nothing
 	case *types.SExpr:
-		for count < len(l.Params) {
-			var err error
+		for count := 0; ; count++ {
 			param, err := nth(count, paramVals)
 			if err != nil {
 				return nil, err
@@ -809,26 +1035,24 @@ func processLambda(l types.Lambda, t *types.SExpr, env types.Env) (types.Expr, e
 			if param == types.NIL {
 				break
 			}
-			val, err := evalInner(param, env)
+			if len(vals) == max {
+				//don't evaluate the parameters that can't be used
+				return nil, fmt.Errorf("too many parameters for %s. Expected %s, got %d", name, arity(l), listLength(paramVals))
+			}
+			val, err := in.evalInner(param, env)
 			if err != nil {
 				return nil, err
 			}
-			le.Vals[l.Params[count]] = val
-			count++
-		}
-		leftOver, err := nth(len(l.Params), paramVals)
-		if err != nil {
-			return nil, err
-		}
-		if leftOver != types.NIL {
-			return nil, fmt.Errorf("too many parameters for LAMBDA. Expected %d", len(l.Params))
+			vals = append(vals, val)
 		}
+	default:
+		return nil, errors.New("can't have a dotted pair here")
 	}
-	if count != len(l.Params) {
-		return nil, fmt.Errorf("too few parameters for LAMBDA. Expected %d, got %d", len(l.Params), count)
+	le, err := in.bindParams(string(name), l, vals)
+	if err != nil {
+		return nil, err
 	}
-	//call body with new environment
-	return evalInner(l.Body, le)
+	return le, nil
 }
 
 // get the nth parameter of the types.SExpr.
@@ -839,7 +1063,7 @@ func nth(pos int, e *types.SExpr) (types.Expr, error) {
 	for i := 0; i < pos; i++ {
 		next := e.Right
 		switch next := next.(type) {
-		case types.Atom:
+		default:
 			return nil, errors.New("can't have a dotted pair here")
 		case *types.SExpr:
 			e = next
@@ -850,24 +1074,116 @@ func nth(pos int, e *types.SExpr) (types.Expr, error) {
 	return e.Left, nil
 }
 
+// isEmpty checks if e is the end of a list, either NIL or the empty list
+func isEmpty(e types.Expr) bool {
+	switch e := e.(type) {
+	case types.Nil:
+		return true
+	case *types.SExpr:
+		return e.Left == types.NIL && e.Right == types.NIL
+	}
+	return false
+}
+
 func isEqual(e, e2 types.Expr) bool {
 	switch e := e.(type) {
 	case types.Atom:
-		if e2, ok := e2.(types.Atom); ok {
-			if e == e2 {
-				return true
+		e2, ok := e2.(types.Atom)
+		return ok && e == e2
+	case types.Number:
+		//exact and inexact numbers are never equal, like EQV? in Scheme
+		e2, ok := e2.(types.Number)
+		if !ok || isFloat(e) != isFloat(e2) {
+			return false
+		}
+		c, ok := compareNumbers(e, e2)
+		return ok && c == 0
+	case types.String:
+		e2, ok := e2.(types.String)
+		return ok && e == e2
+	case types.Channel:
+		e2, ok := e2.(types.Channel)
+		return ok && e == e2
+	case types.GoValue:
+		e2, ok := e2.(types.GoValue)
+		if !ok || reflect.TypeOf(e.Value) != reflect.TypeOf(e2.Value) {
+			return false
+		}
+		if e.Value == nil {
+			return true
+		}
+		//values like slices and maps can't be compared with ==
+		return reflect.TypeOf(e.Value).Comparable() && e.Value == e2.Value
+	case *types.Error:
+		e2, ok := e2.(*types.Error)
+		return ok && e == e2
+	case *types.TransientMap:
+		//transients change, so they are only equal to themselves
+		e2, ok := e2.(*types.TransientMap)
+		return ok && e == e2
+	case *types.TransientVector:
+		e2, ok := e2.(*types.TransientVector)
+		return ok && e == e2
+	case *types.Map:
+		e2, ok := e2.(*types.Map)
+		if !ok {
+			return false
+		}
+		if e == e2 {
+			return true
+		}
+		if e.Len() != e2.Len() {
+			return false
+		}
+		for _, entry := range e.Entries() {
+			v, ok := e2.Get(entry.Key)
+			if !ok || !isEqual(entry.Value, v) {
+				return false
 			}
-			//might be numbers, have to do numeric comparison
-			r := &big.Rat{}
-			r, ok := r.SetString(string(e))
-			r2 := &big.Rat{}
-			r2, ok2 := r2.SetString(string(e2))
-			if ok && ok2 {
-				return r.Cmp(r2) == 0
+		}
+		return true
+	case *types.Vector:
+		e2, ok := e2.(*types.Vector)
+		if !ok {
+			return false
+		}
+		if e == e2 {
+			return true
+		}
+		elems, elems2 := e.Elems(), e2.Elems()
+		if len(elems) != len(elems2) {
+			return false
+		}
+		for i := range elems {
+			if !isEqual(elems[i], elems2[i]) {
+				return false
 			}
-
 		}
-		return false
+		return true
+	case *types.Set:
+		e2, ok := e2.(*types.Set)
+		return ok && (e == e2 || e.Len() == e2.Len() && isSubset(e, e2))
+	case *types.Struct:
+		e2, ok := e2.(*types.Struct)
+		if !ok {
+			return false
+		}
+		if e == e2 {
+			return true
+		}
+		fields, fields2 := e.Fields(), e2.Fields()
+		if e.Name != e2.Name || len(fields) != len(fields2) {
+			return false
+		}
+		//the fields of a struct literal can be in any order
+		for _, f := range fields {
+			v, _ := e.Get(f)
+			v2, ok := e2.Get(f)
+			if !ok || !isEqual(v, v2) {
+				return false
+			}
+		}
+		return true
 	case types.Nil:
 		_, ok := e2.(types.Nil)
 		return ok
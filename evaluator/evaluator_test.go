@@ -1,9 +1,22 @@
 package evaluator
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	runtimedebug "runtime/debug"
+	"strings"
+	"testing"
+
 	"github.com/jonbodner/my_lisp/parser"
 	"github.com/jonbodner/my_lisp/scanner"
-	"testing"
+	"github.com/jonbodner/my_lisp/types"
 )
 
 // additional
@@ -86,6 +99,511 @@ func TestDivide(t *testing.T) {
 	}
 }
 
+func TestNumbers(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"ratio", "(+ 1/2 1/3)", "5/6"},
+		{"ratio to integer", "(+ 1/2 1/2)", "1"},
+		{"ratio normalized", "2/4", "1/2"},
+		{"negative ratio", "(- 1/2)", "-1/2"},
+		{"float", "(+ 1.5 1)", "2.5"},
+		{"float stays float", "(* 2.5 2)", "5.0"},
+		{"float and ratio", "(+ 0.5 1/4)", "0.75"},
+		{"float divide", "(/ 1.0 4)", "0.25"},
+		{"exponent", "1e3", "1000.0"},
+		{"add overflow", "(+ 9223372036854775807 1)", "9223372036854775808"},
+		{"subtract overflow", "(- -9223372036854775808 1)", "-9223372036854775809"},
+		{"negate overflow", "(- -9223372036854775808)", "9223372036854775808"},
+		{"multiply overflow", "(* 4294967296 4294967296)", "18446744073709551616"},
+		{"multiply min", "(* -1 -9223372036854775808)", "9223372036854775808"},
+		{"divide min", "(/ -9223372036854775808 -1)", "9223372036854775808"},
+		{"back to small", "(- 9223372036854775808 1)", "9223372036854775807"},
+		{"big ratio", "(/ 1 18446744073709551616)", "1/18446744073709551616"},
+		{"divide by zero", "(/ 1 0)", "division by zero"},
+		{"invert zero", "(/ 0)", "division by zero"},
+		{"not a number", "(+ 1 'A)", "A is not a valid number"},
+		{"string not a number", `(+ 1 "1")`, `"1" is not a valid number`},
+		{"eq numbers", "(EQ (+ 1 1) 2)", "T"},
+		{"eq big numbers", "(EQ (+ 9223372036854775807 1) 9223372036854775808)", "T"},
+		{"eq ratios", "(EQ 1/2 2/4)", "T"},
+		{"eq exactness", "(EQ 1 1.0)", "()"},
+		{"eq floats", "(EQ 0.5 (/ 1.0 2))", "T"},
+		{"number is an atom", "(ATOM 1/2)", "T"},
+		{"not a function", "(1 2)", "1 is not a function"},
+		{"dotted number", "(CDR '(A . 5))", "5"},
+		{"end of list is an atom", "(ATOM (CDR '(A)))", "T"},
+		{"number->string", "(NUMBER->STRING 1.5)", `"1.5"`},
+		{"factorial", `(PROGN
+			(SETQ FACT (LAMBDA (N) (COND ((EQ N 0) 1) (T (* N (FACT (- N 1)))))))
+			(FACT 25))`, "15511210043330985984000000"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestMathLibrary(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"contagion plus", "(+ 1 2 0.5)", "3.5"},
+		{"contagion minus", "(- 1/2 0.5)", "0.0"},
+		{"contagion times", "(* 1/3 3.0)", "1.0"},
+		{"contagion divide", "(/ 3 2.0)", "1.5"},
+		{"exact stays exact", "(/ 3 2)", "3/2"},
+		{"exact->inexact", "(EXACT->INEXACT 1/4)", "0.25"},
+		{"exact->inexact integer", "(EXACT->INEXACT 3)", "3.0"},
+		{"exact->inexact float", "(EXACT->INEXACT 2.5)", "2.5"},
+		{"inexact->exact", "(INEXACT->EXACT 0.25)", "1/4"},
+		{"inexact->exact integer", "(INEXACT->EXACT 3.0)", "3"},
+		{"inexact->exact tenth", "(INEXACT->EXACT 0.1)", "3602879701896397/36028797018963968"},
+		{"inexact->exact exact", "(INEXACT->EXACT 1/3)", "1/3"},
+		{"inexact->exact infinity", "(INEXACT->EXACT +inf.0)", "+inf.0 has no exact value"},
+		{"inexact->exact not a number", "(INEXACT->EXACT 'A)", "A is not a valid number"},
+		{"sqrt exact", "(SQRT 16)", "4"},
+		{"sqrt ratio", "(SQRT 9/4)", "3/2"},
+		{"sqrt big", "(SQRT 100000000000000000000000000000000000000000000000000)", "10000000000000000000000000"},
+		{"sqrt inexact", "(SQRT 2)", "1.4142135623730951"},
+		{"sqrt float", "(SQRT 16.0)", "4.0"},
+		{"sqrt negative", "(SQRT -4)", "SQRT parameter must not be negative"},
+		{"expt", "(EXPT 2 10)", "1024"},
+		{"expt big", "(EXPT 2 100)", "1267650600228229401496703205376"},
+		{"expt ratio", "(EXPT 2/3 3)", "8/27"},
+		{"expt negative", "(EXPT 2 -2)", "1/4"},
+		{"expt negative ratio", "(EXPT -2/3 -3)", "-27/8"},
+		{"expt zero", "(EXPT 5 0)", "1"},
+		{"expt zero base negative", "(EXPT 0 -1)", "division by zero"},
+		{"expt float", "(EXPT 2.0 3)", "8.0"},
+		{"expt ratio power", "(EXPT 4 1/2)", "2.0"},
+		{"expt missing", "(EXPT 2)", "EXPT requires at least 2 parameters"},
+		{"exp", "(EXP 0)", "1.0"},
+		{"log", "(LOG 1)", "0.0"},
+		{"log base", "(LOG 8 2)", "3.0"},
+		{"log exp", "(LOG (EXP 2))", "2.0"},
+		{"log zero", "(LOG 0)", "LOG parameters must be positive"},
+		{"sin", "(SIN 0)", "0.0"},
+		{"cos", "(COS 0)", "1.0"},
+		{"atan", "(ATAN 1)", "0.7853981633974483"},
+		{"atan2", "(ATAN 1 -1)", "2.356194490192345"},
+		{"floor", "(FLOOR 7/2)", "3"},
+		{"floor negative", "(FLOOR -7/2)", "-4"},
+		{"floor float", "(FLOOR 2.5)", "2.0"},
+		{"floor integer", "(FLOOR 5)", "5"},
+		{"ceiling", "(CEILING 7/2)", "4"},
+		{"ceiling negative", "(CEILING -7/2)", "-3"},
+		{"ceiling float", "(CEILING -2.5)", "-2.0"},
+		{"truncate", "(TRUNCATE 7/2)", "3"},
+		{"truncate negative", "(TRUNCATE -7/2)", "-3"},
+		{"truncate float", "(TRUNCATE -2.7)", "-2.0"},
+		{"round down", "(ROUND 7/3)", "2"},
+		{"round up", "(ROUND 8/3)", "3"},
+		{"round half even", "(ROUND 5/2)", "2"},
+		{"round half odd", "(ROUND 7/2)", "4"},
+		{"round negative half", "(ROUND -5/2)", "-2"},
+		{"round float", "(ROUND 2.5)", "2.0"},
+		{"round float odd", "(ROUND 3.5)", "4.0"},
+		{"round not a number", `(ROUND "a")`, `"a" is not a valid number`},
+		{"round too many", "(ROUND 1 2)", "too many parameters for ROUND"},
+		{"infinity", "(/ 1.0 0.0)", "+inf.0"},
+		{"negative infinity", "(- +inf.0)", "-inf.0"},
+		{"nan", "(- +inf.0 +inf.0)", "+nan.0"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestComparison(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"less", "(< 1 2)", "T"},
+		{"less false", "(< 2 1)", "()"},
+		{"less chain", "(< 1 2 3 4)", "T"},
+		{"less chain false", "(< 1 3 2 4)", "()"},
+		{"less equal values", "(< 1 1)", "()"},
+		{"single", "(< 1)", "T"},
+		{"greater", "(> 3 2 1)", "T"},
+		{"greater false", "(> 3 3 1)", "()"},
+		{"less or equal", "(<= 1 1 2)", "T"},
+		{"less or equal false", "(<= 1 2 1)", "()"},
+		{"greater or equal", "(>= 2 2 1)", "T"},
+		{"greater or equal false", "(>= 1 2)", "()"},
+		{"equal", "(= 2 2 2)", "T"},
+		{"equal false", "(= 2 2 3)", "()"},
+		{"equal exactness", "(= 1 1.0 2/2)", "T"},
+		{"not equal", "(/= 1 2 3)", "T"},
+		{"not equal false", "(/= 1 2 1)", "()"},
+		{"ratios", "(< 1/3 1/2 0.6)", "T"},
+		{"big", "(< 9223372036854775807 9223372036854775808)", "T"},
+		{"negative big", "(> -9223372036854775809 -9223372036854775808)", "()"},
+		{"nan", "(= +nan.0 +nan.0)", "()"},
+		{"infinity", "(< 1 +inf.0)", "T"},
+		{"missing", "(<)", "missing parameters for <"},
+		{"not a number", "(< 1 'A)", "A is not a valid number"},
+		{"loop", `(PROGN
+			(SETQ COUNT-UP (LAMBDA (I N ACC) (COND ((>= I N) ACC) (T (COUNT-UP (+ I 1) N (CONS I ACC))))))
+			(COUNT-UP 0 5 NIL))`, "(4 3 2 1 0)"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestIntegerOps(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"mod", "(MOD 7 3)", "1"},
+		{"mod negative dividend", "(MOD -7 3)", "2"},
+		{"mod negative divisor", "(MOD 7 -3)", "-2"},
+		{"mod both negative", "(MOD -7 -3)", "-1"},
+		{"mod big", "(MOD 100000000000000000000 -7)", "-5"},
+		{"mod big negative", "(MOD -100000000000000000000 7)", "5"},
+		{"rem", "(REM 7 3)", "1"},
+		{"rem negative dividend", "(REM -7 3)", "-1"},
+		{"rem negative divisor", "(REM 7 -3)", "1"},
+		{"rem big", "(REM -100000000000000000000 7)", "-2"},
+		{"quotient", "(QUOTIENT 7 2)", "3"},
+		{"quotient negative", "(QUOTIENT -7 2)", "-3"},
+		{"quotient min", "(QUOTIENT -9223372036854775808 -1)", "9223372036854775808"},
+		{"mod min", "(MOD -9223372036854775808 -1)", "0"},
+		{"quotient by zero", "(QUOTIENT 1 0)", "division by zero"},
+		{"mod by zero", "(MOD 1 0)", "division by zero"},
+		{"rem by zero", "(REM 1 0)", "division by zero"},
+		{"mod not integer", "(MOD 1/2 3)", "1/2 is not an integer"},
+		{"mod float", "(MOD 1.0 3)", "1.0 is not an integer"},
+		{"mod missing", "(MOD 1)", "MOD requires at least 2 parameters"},
+		{"gcd", "(GCD 12 18)", "6"},
+		{"gcd many", "(GCD 12 18 -8)", "2"},
+		{"gcd none", "(GCD)", "0"},
+		{"gcd one", "(GCD -5)", "5"},
+		{"lcm", "(LCM 4 6)", "12"},
+		{"lcm many", "(LCM 2 3 -4)", "12"},
+		{"lcm none", "(LCM)", "1"},
+		{"lcm zero", "(LCM 3 0)", "0"},
+		{"abs", "(ABS -5)", "5"},
+		{"abs positive", "(ABS 5)", "5"},
+		{"abs ratio", "(ABS -1/2)", "1/2"},
+		{"abs float", "(ABS -2.5)", "2.5"},
+		{"abs min", "(ABS -9223372036854775808)", "9223372036854775808"},
+		{"min", "(MIN 3 1 2)", "1"},
+		{"max", "(MAX 3 1 2)", "3"},
+		{"max ratio", "(MAX 1/2 1/3)", "1/2"},
+		{"min inexact", "(MIN 1 2.0)", "1.0"},
+		{"max single", "(MAX 4)", "4"},
+		{"max missing", "(MAX)", "missing parameters for MAX"},
+		{"numerator", "(NUMERATOR 6/4)", "3"},
+		{"denominator", "(DENOMINATOR 6/4)", "2"},
+		{"numerator integer", "(NUMERATOR 5)", "5"},
+		{"denominator integer", "(DENOMINATOR 5)", "1"},
+		{"denominator negative", "(DENOMINATOR -1/3)", "3"},
+		{"numerator float", "(NUMERATOR 0.5)", "NUMERATOR parameter must be an exact number"},
+		{"zerop", "(ZEROP 0)", "T"},
+		{"zerop float", "(ZEROP 0.0)", "T"},
+		{"zerop false", "(ZEROP 1/2)", "()"},
+		{"zerop nan", "(ZEROP +nan.0)", "()"},
+		{"evenp", "(EVENP 4)", "T"},
+		{"evenp false", "(EVENP -3)", "()"},
+		{"oddp", "(ODDP -3)", "T"},
+		{"oddp big", "(ODDP 100000000000000000001)", "T"},
+		{"oddp false", "(ODDP 0)", "()"},
+		{"evenp ratio", "(EVENP 1/2)", "1/2 is not an integer"},
+		{"divide by zero", "(/ 5 0)", "division by zero"},
+		{"divide by zero later", "(/ 5 1 0)", "division by zero"},
+		{"divide ratio by zero", "(/ 1/2 0)", "division by zero"},
+		{"divide float by exact zero", "(/ 1.5 0)", "division by zero"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func benchmarkEval(b *testing.B, setup, expr, expected string) {
+	in := New()
+	if _, err := in.EvalString(setup); err != nil {
+		b.Fatal(err)
+	}
+	tokens, _ := scanner.Scan(expr)
+	e, _, err := parser.Parse(tokens)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, err := in.Eval(e)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if result.String() != expected {
+			b.Fatalf("Expected %s, got %s", expected, result)
+		}
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkEval(b,
+		"(SETQ FIB (LAMBDA (N) (COND ((EQ N 0) 0) ((EQ N 1) 1) (T (+ (FIB (- N 1)) (FIB (- N 2)))))))",
+		"(FIB 20)", "6765")
+}
+
+func BenchmarkFactorial(b *testing.B) {
+	benchmarkEval(b,
+		"(SETQ FACT (LAMBDA (N) (COND ((EQ N 0) 1) (T (* N (FACT (- N 1)))))))",
+		"(FACT 100)", "93326215443944152681699238856266700490715968264381621468592963895217599993229915608941463976156518286253697920827223758251185210916864000000000000000000000000")
+}
+
+func TestQuasiquote(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"atom", "`A", "A"},
+		{"no unquote", "`(A B C)", "(A B C)"},
+		{"unquote", "`(A ,(+ 1 2) C)", "(A 3 C)"},
+		{"splice", "`(A ,@(CDR '(X Y Z)) B)", "(A Y Z B)"},
+		{"splice empty", "`(A ,@NIL B)", "(A B)"},
+		{"dotted", "`(A . ,(+ 1 1))", "(A . 2)"},
+		{"nested", "`(A `(B ,(C ,(+ 1 2))))", "(A (QUASIQUOTE (B (UNQUOTE (C 3)))))"},
+		{"splice outside list", "`,@(A)", "UNQUOTE-SPLICING must be inside of a list"},
+		{"unquote outside quasiquote", ",A", "UNQUOTE is only valid inside of QUASIQUOTE"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestMacro(t *testing.T) {
+	myIf := "(DEFMACRO MY-IF (C A B) `(COND (,C ,A) (T ,B)))"
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"define", myIf, "MY-IF"},
+		{"call true", "(PROGN " + myIf + " (MY-IF T 1 2))", "1"},
+		{"call false", "(PROGN " + myIf + " (MY-IF NIL 1 (+ 1 1)))", "2"},
+		{"unevaluated params", "(PROGN (DEFMACRO MY-QUOTE (X) `(QUOTE ,X)) (MY-QUOTE (A B)))", "(A B)"},
+		{"expand once", "(PROGN " + myIf + " (MACROEXPAND-1 '(MY-IF X 1 2)))", "(COND (X 1) (T 2))"},
+		{"expand nested", "(PROGN " + myIf + " (DEFMACRO MY-WHEN (C A) `(MY-IF ,C ,A NIL)) (MACROEXPAND '(MY-WHEN X 1)))", "(COND (X 1) (T NIL))"},
+		{"expand once nested", "(PROGN " + myIf + " (DEFMACRO MY-WHEN (C A) `(MY-IF ,C ,A NIL)) (MACROEXPAND-1 '(MY-WHEN X 1)))", "(MY-IF X 1 NIL)"},
+		{"expand not a macro", "(MACROEXPAND '(CAR X))", "(CAR X)"},
+		{"too few", "(PROGN " + myIf + " (MY-IF T 1))", "too few parameters for MY-IF. Expected 3, got 2"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestStrings(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"literal", `"hello world"`, `"hello world"`},
+		{"escapes", `"a\n\"b\""`, `"a\n\"b\""`},
+		{"atom", `(ATOM "hello")`, "T"},
+		{"eq", `(EQ "abc" "abc")`, "T"},
+		{"not eq", `(EQ "abc" "ABC")`, "()"},
+		{"not eq symbol", `(EQ "abc" 'abc)`, "()"},
+		{"append", `(STRING-APPEND "foo" " " "bar")`, `"foo bar"`},
+		{"append none", `(STRING-APPEND)`, `""`},
+		{"append not string", `(STRING-APPEND "foo" 'BAR)`, "STRING-APPEND parameter must be a string"},
+		{"substring", `(SUBSTRING "hello world" 6)`, `"world"`},
+		{"substring end", `(SUBSTRING "hello world" 0 5)`, `"hello"`},
+		{"substring unicode", `(SUBSTRING "h\u{e9}llo" 1 2)`, `"é"`},
+		{"substring bounds", `(SUBSTRING "hello" 2 10)`, "SUBSTRING range 2 to 10 is out of bounds for a string of length 5"},
+		{"substring missing", `(SUBSTRING)`, "missing parameters for SUBSTRING"},
+		{"substring too few", `(SUBSTRING "hello")`, "SUBSTRING requires at least 2 parameters"},
+		{"length", `(STRING-LENGTH "h\u{e9}llo")`, "5"},
+		{"length too many", `(STRING-LENGTH "a" "b")`, "too many parameters for STRING-LENGTH"},
+		{"split", `(STRING-SPLIT "a,b,,c" ",")`, `("a" "b" "" "c")`},
+		{"split whitespace", `(STRING-SPLIT "  a b   c ")`, `("a" "b" "c")`},
+		{"split empty", `(STRING-SPLIT "")`, "()"},
+		{"join", `(STRING-JOIN (STRING-SPLIT "a b c") "-")`, `"a-b-c"`},
+		{"join no separator", `(STRING-JOIN (CONS "a" (CONS "b" NIL)))`, `"ab"`},
+		{"join not list", `(STRING-JOIN "abc")`, "STRING-JOIN parameter must be a list"},
+		{"string to symbol", `(STRING->SYMBOL "FOO")`, "FOO"},
+		{"symbol to string", `(SYMBOL->STRING 'FOO)`, `"FOO"`},
+		{"symbol to string not symbol", `(SYMBOL->STRING "FOO")`, "SYMBOL->STRING parameter must be a symbol"},
+		{"number to string", `(NUMBER->STRING (/ 2 4))`, `"1/2"`},
+		{"number to string not number", `(NUMBER->STRING 'FOO)`, "FOO is not a valid number"},
+		{"upcase", `(STRING-UPCASE "Hello")`, `"HELLO"`},
+		{"downcase", `(STRING-DOWNCASE "Hello")`, `"hello"`},
+		{"parens in string", `(STRING-LENGTH "(()")`, "3"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestChannels(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"unbuffered", "(PROGN (SETQ C (MAKE-CHAN)) (GO (SEND C 42)) (RECV C))", "42"},
+		{"buffered", "(PROGN (SETQ C (MAKE-CHAN 2)) (SEND C 1) (SEND C 2) (+ (RECV C) (RECV C)))", "3"},
+		{"closed", "(PROGN (SETQ C (MAKE-CHAN 1)) (CLOSE-CHAN C) (RECV C))", "()"},
+		{"closed twice", "(PROGN (SETQ C (MAKE-CHAN)) (CLOSE-CHAN C) (CLOSE-CHAN C))", "CLOSE-CHAN on closed channel"},
+		{"send on closed", "(PROGN (SETQ C (MAKE-CHAN 1)) (CLOSE-CHAN C) (SEND C 1))", "SEND on closed channel"},
+		{"not a channel", "(RECV 1)", "RECV parameter must be a channel"},
+		{"negative size", "(MAKE-CHAN -1)", "MAKE-CHAN size must not be negative"},
+		{"eq", "(PROGN (SETQ C (MAKE-CHAN)) (EQ C C))", "T"},
+		{"lexical environment", "(LET ((C (MAKE-CHAN)) (X 5)) (PROGN (GO (SEND C (* X 2))) (RECV C)))", "10"},
+		{"fan in", `(PROGN
+			(SETQ C (MAKE-CHAN))
+			(SETQ SPAWN (LAMBDA (N) (COND ((EQ N 0) T) (T (PROGN (GO (SEND C (* N N))) (SPAWN (- N 1)))))))
+			(SETQ COLLECT (LAMBDA (N ACC) (COND ((EQ N 0) ACC) (T (COLLECT (- N 1) (+ ACC (RECV C)))))))
+			(SPAWN 20)
+			(COLLECT 20 0))`, "2870"},
+		{"shared variable", `(PROGN
+			(SETQ C (MAKE-CHAN))
+			(SETQ COUNTER 0)
+			(SETQ WORKER (LAMBDA (N) (COND ((EQ N 0) (SEND C (QUOTE DONE))) (T (PROGN (SETQ COUNTER N) (WORKER (- N 1)))))))
+			(GO (WORKER 100))
+			(GO (WORKER 100))
+			(RECV C)
+			(RECV C)
+			COUNTER)`, "1"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+// lineWriter sends each line that is written to it on a channel
+type lineWriter chan string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestGoErrors(t *testing.T) {
+	out := make(lineWriter, 1)
+	in := New(WithLogger(log.New(out, "", 0)))
+	in.builtIn["BOOM"] = func(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+		panic("boom")
+	}
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"error", "(GO (CAR 1))", "error in goroutine: 1:5: CAR parameter must be a list\n"},
+		{"panic", "(GO (BOOM))", "panic in goroutine: boom\n"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, in, d.input, "T")
+			if got := <-out; got != d.expected {
+				t.Errorf("Expected %q, got %q", d.expected, got)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"default", "(SELECT ((RECV (MAKE-CHAN) X) X) (DEFAULT (QUOTE NOTHING)))", "NOTHING"},
+		{"default no body", "(SELECT ((RECV (MAKE-CHAN))) (DEFAULT))", "()"},
+		{"recv", "(PROGN (SETQ C (MAKE-CHAN 1)) (SEND C 5) (SELECT ((RECV C X) (+ X 1)) (DEFAULT 0)))", "6"},
+		{"recv no var", "(PROGN (SETQ C (MAKE-CHAN 1)) (SEND C 5) (SELECT ((RECV C) (QUOTE GOT))))", "GOT"},
+		{"recv no body", "(PROGN (SETQ C (MAKE-CHAN 1)) (SEND C 5) (SELECT ((RECV C))))", "5"},
+		{"recv closed", "(PROGN (SETQ C (MAKE-CHAN)) (CLOSE-CHAN C) (SELECT ((RECV C X) X)))", "()"},
+		{"send", "(PROGN (SETQ C (MAKE-CHAN 1)) (SELECT ((SEND C 7) (RECV C)) (DEFAULT 0)))", "7"},
+		{"send full", "(PROGN (SETQ C (MAKE-CHAN)) (SELECT ((SEND C 7) 1) (DEFAULT 0)))", "0"},
+		{"wait", `(PROGN
+			(SETQ A (MAKE-CHAN))
+			(SETQ B (MAKE-CHAN))
+			(GO (SEND B (QUOTE FROM-B)))
+			(SELECT ((RECV A X) X) ((RECV B Y) Y)))`, "FROM-B"},
+		{"two defaults", "(SELECT (DEFAULT 1) (DEFAULT 2))", "SELECT can only have one DEFAULT clause"},
+		{"bad clause", "(SELECT ((FOO C) 1))", "SELECT clause must start with (SEND ...), (RECV ...) or DEFAULT"},
+		{"empty", "(SELECT)", "missing clauses for SELECT"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	//a million calls in tail position must not grow the stack;
+	//lower the stack limit so a regression fails quickly instead of eating memory
+	defer runtimedebug.SetMaxStack(runtimedebug.SetMaxStack(64 << 20))
+	in := New()
+
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"cond", `(PROGN
+			(SETQ COUNTDOWN (LAMBDA (N) (COND ((EQ N 0) (QUOTE DONE)) (T (COUNTDOWN (- N 1))))))
+			(COUNTDOWN 1000000))`, "DONE"},
+		{"progn", `(PROGN
+			(SETQ COUNTDOWN-PROGN (LAMBDA (N) (COND ((EQ N 0) (QUOTE DONE)) (T (PROGN N (COUNTDOWN-PROGN (- N 1)))))))
+			(COUNTDOWN-PROGN 1000000))`, "DONE"},
+		{"let", `(PROGN
+			(SETQ COUNTDOWN-LET (LAMBDA (N) (LET ((M (- N 1))) (COND ((EQ N 0) (QUOTE DONE)) (T (COUNTDOWN-LET M))))))
+			(COUNTDOWN-LET 1000000))`, "DONE"},
+		{"accumulator", `(PROGN
+			(SETQ SUM (LAMBDA (N ACC) (COND ((EQ N 0) ACC) (T (SUM (- N 1) (+ ACC N))))))
+			(SUM 1000000 0))`, "500000500000"},
+		{"mutual", `(PROGN
+			(SETQ MY-EVEN (LAMBDA (N) (COND ((EQ N 0) T) (T (MY-ODD (- N 1))))))
+			(SETQ MY-ODD (LAMBDA (N) (COND ((EQ N 0) NIL) (T (MY-EVEN (- N 1))))))
+			(MY-EVEN 1000000))`, "T"},
+		{"macro", `(PROGN
+			(DEFMACRO MY-IF (C A B) (QUASIQUOTE (COND ((UNQUOTE C) (UNQUOTE A)) (T (UNQUOTE B)))))
+			(SETQ COUNTDOWN-MACRO (LAMBDA (N) (MY-IF (EQ N 0) (QUOTE DONE) (COUNTDOWN-MACRO (- N 1)))))
+			(COUNTDOWN-MACRO 1000000))`, "DONE"},
+		{"handler-case", `(PROGN
+			(SETQ COUNTDOWN-HANDLER (LAMBDA (N) (COND ((EQ N 0) (QUOTE DONE)) (T (HANDLER-CASE (ERROR "again") (ERROR () (COUNTDOWN-HANDLER (- N 1))))))))
+			(COUNTDOWN-HANDLER 1000000))`, "DONE"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, in, d.input, d.expected)
+		})
+	}
+}
+
 // core
 func TestQuote(t *testing.T) {
 
@@ -120,7 +638,55 @@ func TestEnv(t *testing.T) {
 }
 
 func TestLambda(t *testing.T) {
-
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"simple", "((LAMBDA (X Y) (+ X Y)) 1 2)", "3"},
+		{"no params", "((LAMBDA () 5))", "5"},
+		{"too few", "(PROGN (SETQ F (LAMBDA (X Y) X)) (F 1))", "too few parameters for F. Expected 2, got 1"},
+		{"too many", "(PROGN (SETQ F (LAMBDA (X Y) X)) (F 1 2 3))", "too many parameters for F. Expected 2, got 3"},
+		{"too many anonymous", "((LAMBDA (X) X) 1 2)", "too many parameters for LAMBDA. Expected 1, got 2"},
+		{"extra not evaluated", "(PROGN (SETQ F (LAMBDA (X) X)) (F 1 (CAR 1)))", "too many parameters for F. Expected 1, got 2"},
+		{"rest", "((LAMBDA (X &REST R) (CONS X R)) 1 2 3)", "(1 2 3)"},
+		{"rest empty", "((LAMBDA (X &REST R) R) 1)", "()"},
+		{"dotted rest", "((LAMBDA (X . R) R) 1 2 3)", "(2 3)"},
+		{"symbol instead of list", "(LAMBDA R R)", "LAMBDA parameter list must be a List"},
+		{"rest too few", "(PROGN (SETQ F (LAMBDA (X Y &REST R) R)) (F 1))", "too few parameters for F. Expected at least 2, got 1"},
+		{"optional", "((LAMBDA (X &OPTIONAL Y) (CONS X Y)) 1)", "(1)"},
+		{"optional passed", "((LAMBDA (X &OPTIONAL Y) (CONS X Y)) 1 2)", "(1 . 2)"},
+		{"optional default", "((LAMBDA (X &OPTIONAL (Y 10)) (+ X Y)) 1)", "11"},
+		{"optional default uses earlier", "((LAMBDA (X &OPTIONAL (Y (* X 2)) (Z (+ Y 1))) (CONS Y Z)) 5)", "(10 . 11)"},
+		{"optional too many", "(PROGN (SETQ F (LAMBDA (X &OPTIONAL Y) X)) (F 1 2 3))", "too many parameters for F. Expected 1 to 2, got 3"},
+		{"optional and rest", "((LAMBDA (&OPTIONAL (A 1) &REST R) (CONS A R)) 7 8 9)", "(7 8 9)"},
+		{"key", "((LAMBDA (X &KEY Y (Z 3)) (CONS X (CONS Y Z))) 1 :Y 2)", "(1 2 . 3)"},
+		{"key order", "((LAMBDA (&KEY A B) (CONS A B)) :B 2 :A 1)", "(1 . 2)"},
+		{"key none", "((LAMBDA (&KEY A (B 'DEFAULT)) (CONS A B)))", "(() . DEFAULT)"},
+		{"key first wins", "((LAMBDA (&KEY A) A) :A 1 :A 2)", "1"},
+		{"key unknown", "(PROGN (SETQ F (LAMBDA (&KEY A) A)) (F :B 1))", "F has no keyword parameter :B"},
+		{"key odd", "(PROGN (SETQ F (LAMBDA (&KEY A) A)) (F :A))", "keyword parameters for F must come in pairs"},
+		{"key not keyword", "(PROGN (SETQ F (LAMBDA (&KEY A) A)) (F 'A 1))", "A is not a keyword in the parameters for F"},
+		{"rest and key", "((LAMBDA (&REST R &KEY A) (CONS A R)) :A 1)", "(1 :A 1)"},
+		{"keywords evaluate to themselves", ":FOO", ":FOO"},
+		{"print", "(LAMBDA (A &OPTIONAL (B 2) C &REST D &KEY E (F 'X)) A)", "(LAMBDA (A &OPTIONAL (B 2) C &REST D &KEY E (F (QUOTE X))) A )"},
+		{"print dotted", "(LAMBDA (A . B) A)", "(LAMBDA (A &REST B) A )"},
+		{"atom", "(ATOM (LAMBDA (X) X))", "T"},
+		{"bad order", "(LAMBDA (&REST R &OPTIONAL A) A)", "&OPTIONAL is in the wrong place in the parameter list for LAMBDA"},
+		{"rest missing name", "(LAMBDA (&REST) 1)", "&REST must be followed by a parameter name in the parameter list for LAMBDA"},
+		{"rest two names", "(LAMBDA (&REST A B) 1)", "only one parameter can follow &REST in the parameter list for LAMBDA"},
+		{"duplicate", "(LAMBDA (A &OPTIONAL A) 1)", "A appears more than once in the parameter list for LAMBDA"},
+		{"bad default", "(LAMBDA (&OPTIONAL (A 1 2)) 1)", "parameter A in the parameter list for LAMBDA can only have one default value"},
+		{"number in pattern", "(LAMBDA ((A 1)) 1)", "1 can't be used in a pattern"},
+		{"macro rest", `(PROGN
+			(DEFMACRO MY-WHEN (C &REST BODY) (QUASIQUOTE (COND ((UNQUOTE C) (PROGN (UNQUOTE-SPLICING BODY))))))
+			(MY-WHEN T 1 2 3))`, "3"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, New(), d.input, d.expected)
+		})
+	}
 }
 
 func TestLabel(t *testing.T) {
@@ -131,17 +697,1378 @@ func TestSetq(t *testing.T) {
 
 }
 
-func internalEvaluator(t *testing.T, input string, expected string) {
-	tokens, _ := scanner.Scan(input)
-	expr, _, _ := parser.Parse(tokens)
-	out, err := Eval(expr)
+func TestInterpreter(t *testing.T) {
+	in1 := New()
+	in2 := New()
+	interpreterEvaluator(t, in1, "(SETQ X 1)", "1")
+	interpreterEvaluator(t, in2, "(SETQ X 2)", "2")
+	interpreterEvaluator(t, in1, "X", "1")
+	interpreterEvaluator(t, in2, "X", "2")
+	interpreterEvaluator(t, in1, "(DELETE 'X)", "T")
+	interpreterEvaluator(t, in1, "X", "unknown symbol X ")
+	interpreterEvaluator(t, in2, "X", "2")
+}
+
+func TestInterpreterParallel(t *testing.T) {
+	//evaluate in many interpreters at once; each one sees only its own definitions
+	done := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			in := New()
+			if _, err := in.EvalString(fmt.Sprintf("(SETQ N %d)", i)); err != nil {
+				done <- err
+				return
+			}
+			out, err := in.EvalString(`(SETQ LOOP (LAMBDA (K ACC) (COND ((EQ K 0) ACC) (T (LOOP (- K 1) (+ ACC N))))))
+				(LOOP 1000 0)`)
+			if err == nil && out.String() != fmt.Sprint(i*1000) {
+				err = fmt.Errorf("expected %d, got %s", i*1000, out)
+			}
+			done <- err
+		}(i)
+	}
+	for i := 0; i < 10; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestEvalString(t *testing.T) {
+	in := New()
+	out, err := in.EvalString("(SETQ A 1) (SETQ B 2) (+ A B)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "3" {
+		t.Errorf("Expected 3, got %s", out)
+	}
+	out, err = in.EvalString("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "()" {
+		t.Errorf("Expected (), got %s", out)
+	}
+	_, err = in.EvalString("(CAR")
+	if err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestEvalReader(t *testing.T) {
+	var debugOut bytes.Buffer
+	in := New(WithDebug(true), WithLogger(log.New(&debugOut, "", 0)))
+	out, err := in.EvalReader(strings.NewReader("(SETQ SQUARE (LAMBDA (X) (* X X)))\n(SQUARE\n 12)\n"))
 	if err != nil {
-		if err.Error() != expected {
-			t.Errorf("Unexpected error. Expected %s, got %v", expected, err.Error())
+		t.Fatal(err)
+	}
+	if out.String() != "144" {
+		t.Errorf("Expected 144, got %s", out)
+	}
+	if debugOut.Len() == 0 {
+		t.Error("Expected debugging output")
+	}
+	interpreterEvaluator(t, in, "(**DEBUG** NIL)", "T")
+	debugOut.Reset()
+	interpreterEvaluator(t, in, "(SQUARE 3)", "9")
+	if debugOut.Len() != 0 {
+		t.Errorf("Expected no debugging output, got %s", debugOut.String())
+	}
+}
+
+func TestRegister(t *testing.T) {
+	in := New()
+	register := func(name string, fn interface{}) {
+		if err := in.Register(name, fn); err != nil {
+			t.Fatal(err)
 		}
-	} else {
-		if out.String() != expected {
-			t.Errorf("Expected %s, got %v ", expected, out)
+	}
+	register("RAT-ADD", func(a, b *big.Rat) (*big.Rat, error) {
+		return new(big.Rat).Add(a, b), nil
+	})
+	register("SHOUT", func(s string) string { return strings.ToUpper(s) + "!" })
+	register("SUM-INTS", func(vals ...int) int {
+		total := 0
+		for _, v := range vals {
+			total += v
+		}
+		return total
+	})
+	register("PREFIX-ALL", func(prefix string, vals ...string) []string {
+		out := make([]string, len(vals))
+		for i, v := range vals {
+			out[i] = prefix + v
+		}
+		return out
+	})
+	register("CHECK-POSITIVE", func(i int64) error {
+		if i <= 0 {
+			return errors.New("not positive")
+		}
+		return nil
+	})
+	register("HALF", func(f float64) float64 { return f / 2 })
+	register("NOT-GO", func(b bool) bool { return !b })
+	register("BIG-SQUARE", func(i *big.Int) *big.Int { return new(big.Int).Mul(i, i) })
+	register("LEN", func(l []types.Expr) uint8 { return uint8(len(l)) })
+	register("FIRST-OR-NIL", func(e types.Expr) types.Expr {
+		if s, ok := e.(*types.SExpr); ok {
+			return s.Left
+		}
+		return nil
+	})
+	register("NOTHING", func() {})
+	register("BOOM", func() int { panic("boom") })
+	register("SMALL", func(i int8) int8 { return i })
+
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"rat", "(RAT-ADD 1/2 1/3)", "5/6"},
+		{"evaluated params", "(RAT-ADD (+ 1 1) (* 2 2))", "6"},
+		{"string", `(SHOUT "hello")`, `"HELLO!"`},
+		{"variadic", "(SUM-INTS 1 2 3 4)", "10"},
+		{"variadic empty", "(SUM-INTS)", "0"},
+		{"variadic with fixed", `(PREFIX-ALL "x-" "a" "b")`, `("x-a" "x-b")`},
+		{"variadic too few", `(PREFIX-ALL)`, "too few parameters for PREFIX-ALL. Expected at least 1, got 0"},
+		{"error nil", "(CHECK-POSITIVE 5)", "()"},
+		{"error", "(CHECK-POSITIVE -5)", "not positive"},
+		{"float", "(HALF 3)", "1.5"},
+		{"bool", "(NOT-GO NIL)", "T"},
+		{"bool false", "(NOT-GO 'A)", "()"},
+		{"big int", "(BIG-SQUARE 100000000000000000000)", "10000000000000000000000000000000000000000"},
+		{"slice", "(LEN '(A B C))", "3"},
+		{"expr", "(FIRST-OR-NIL '(A B C))", "A"},
+		{"nil expr", "(FIRST-OR-NIL 'A)", "()"},
+		{"no result", "(NOTHING)", "()"},
+		{"panic", "(BOOM)", "BOOM: boom"},
+		{"arity", `(SHOUT "a" "b")`, "wrong number of parameters for SHOUT. Expected 1, got 2"},
+		{"type", "(SHOUT 'A)", "SHOUT parameter 1: A is not a string"},
+		{"not integer", "(SUM-INTS 1 1/2)", "SUM-INTS parameter 2: 1/2 is not an integer that fits in an int"},
+		{"overflow", "(SMALL 1000)", "SMALL parameter 1: 1000 is not an integer that fits in an int8"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, in, d.input, d.expected)
+		})
+	}
+
+	//registering a function in one interpreter doesn't affect the others
+	interpreterEvaluator(t, New(), `(SHOUT "hello")`, "unknown symbol SHOUT ")
+
+	bad := []struct {
+		name     string
+		fn       interface{}
+		expected string
+	}{
+		{"not a function", 5, "can't register BAD: int is not a function"},
+		{"bad param", func(c complex128) {}, "can't register BAD: unsupported parameter type complex128"},
+		{"bad return", func() complex64 { return 0 }, "can't register BAD: unsupported return type complex64"},
+		{"second not error", func() (int, int) { return 0, 0 }, "can't register BAD: second return value must be an error"},
+		{"too many returns", func() (int, int, error) { return 0, 0, nil }, "can't register BAD: too many return values"},
+	}
+	for _, d := range bad {
+		t.Run(d.name, func(t *testing.T) {
+			err := in.Register("BAD", d.fn)
+			if err == nil || err.Error() != d.expected {
+				t.Errorf("Expected error %s, got %v", d.expected, err)
+			}
+		})
+	}
+}
+
+type testPoint struct {
+	X, Y   int
+	Name   string
+	Tags   []string
+	Inner  testInner
+	hidden int
+}
+
+type testInner struct {
+	Weight float64
+}
+
+func (p testPoint) Sum() int {
+	return p.X + p.Y
+}
+
+func (p *testPoint) Move(dx, dy int) {
+	p.X += dx
+	p.Y += dy
+}
+
+func (p *testPoint) Check() (int, error) {
+	if p.X < 0 {
+		return 0, errors.New("negative X")
+	}
+	return p.X, nil
+}
+
+func TestGoValues(t *testing.T) {
+	in := New()
+	p := &testPoint{X: 1, Y: 2, Name: "origin", Tags: []string{"a", "b"}, Inner: testInner{Weight: 1.5}}
+	counts := map[string]int{"apples": 3}
+	nums := []int{10, 20, 30}
+	define := func(name string, v interface{}) {
+		if err := in.Define(name, v); err != nil {
+			t.Fatal(err)
 		}
 	}
+	define("P", p)
+	define("V", *p)
+	define("COUNTS", counts)
+	define("NUMS", types.GoValue{Value: nums})
+	define("NUM-LIST", nums)
+	define("DIVMOD", func(a, b int) (int, int) { return a / b, a % b })
+	define("SPRINT", fmt.Sprint)
+	define("NEW-POINT", func(x, y int) *testPoint { return &testPoint{X: x, Y: y} })
+	define("NIL-POINT", (*testPoint)(nil))
+
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"field", "(GO-FIELD P 'X)", "1"},
+		{"field by string", `(GO-FIELD P "Name")`, `"origin"`},
+		{"slice field", "(GO-FIELD P 'Tags)", `("a" "b")`},
+		{"struct by value", "(GO-FIELD V 'Y)", "2"},
+		{"set field", "(PROGN (GO-SET-FIELD P 'X 5) (GO-FIELD P 'X))", "5"},
+		{"set slice field", `(PROGN (GO-SET-FIELD P 'Tags '("c")) (GO-FIELD P 'Tags))`, `("c")`},
+		{"set nested field", "(PROGN (GO-SET-FIELD (GO-FIELD P 'Inner) 'Weight 1/4) (GO-FIELD (GO-FIELD P 'Inner) 'Weight))", "0.25"},
+		{"set struct by value", "(GO-SET-FIELD V 'X 5)", "GO-SET-FIELD can't change X of evaluator.testPoint; use a pointer"},
+		{"set wrong type", "(GO-SET-FIELD P 'Name 5)", "GO-SET-FIELD value: 5 is not a string"},
+		{"unexported", "(GO-FIELD P 'hidden)", "evaluator.testPoint has no exported field hidden"},
+		{"missing field", "(GO-FIELD P 'Z)", "evaluator.testPoint has no exported field Z"},
+		{"nil pointer", "NIL-POINT", "()"},
+		{"map", `(GO-FIELD COUNTS "apples")`, "3"},
+		{"missing key", `(GO-FIELD COUNTS "pears")`, "()"},
+		{"set map", `(PROGN (GO-SET-FIELD COUNTS "pears" 7) (GO-FIELD COUNTS "pears"))`, "7"},
+		{"bad key", `(GO-FIELD COUNTS 'PEARS)`, "GO-FIELD key: PEARS is not a string"},
+		{"slice", "(GO-FIELD NUMS 1)", "20"},
+		{"set slice", "(PROGN (GO-SET-FIELD NUMS 2 33) (GO-FIELD NUMS 2))", "33"},
+		{"slice bounds", "(GO-FIELD NUMS 3)", "GO-FIELD index 3 is out of bounds for a length of 3"},
+		{"slice as list", "NUM-LIST", "(10 20 30)"},
+		{"method", "(GO-METHOD V 'Sum)", "3"},
+		{"pointer method", "(PROGN (GO-METHOD P 'Move 10 20) (GO-METHOD P 'Sum))", "37"},
+		{"pointer method on value", "(GO-METHOD V 'Move 1 1)", "evaluator.testPoint has no method Move"},
+		{"method error", "(PROGN (GO-SET-FIELD P 'X -1) (GO-METHOD P 'Check))", "negative X"},
+		{"method arity", "(GO-METHOD P 'Move 1)", "wrong number of parameters for Move. Expected 2, got 1"},
+		{"call", "(GO-CALL DIVMOD 17 5)", "(3 2)"},
+		{"call variadic interface", `(GO-CALL SPRINT "a" 1 2/3 '(T B) NIL)`, "\"a1 2/3 [true B] <nil>\""},
+		{"call returning struct", "(GO-FIELD (GO-CALL NEW-POINT 4 5) 'Y)", "5"},
+		{"pass go value", "(GO-CALL SPRINT (GO-CALL NEW-POINT 4 5))", "\"&{4 5  [] {0} 0}\""},
+		{"not a function", "(GO-CALL P)", "GO-CALL can't call #<GO *evaluator.testPoint &{-1 22 origin [c] {0.25} 0}>"},
+		{"not a go value", "(GO-FIELD '(A) 'X)", "GO-FIELD parameter must be a Go value"},
+		{"atom", "(ATOM P)", "T"},
+		{"eq", "(EQ P P)", "T"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, in, d.input, d.expected)
+		})
+	}
+	if p.X != -1 || p.Y != 22 || counts["pears"] != 7 || nums[2] != 33 {
+		t.Errorf("changes weren't made to the Go values: %v %v %v", p, counts, nums)
+	}
+}
+
+func TestConversions(t *testing.T) {
+	in := New()
+	data := []struct {
+		name     string
+		fn       interface{}
+		input    string
+		expected string
+	}{
+		{"array", func(a [2]int) int { return a[0] * a[1] }, "(F '(3 4))", "12"},
+		{"array length", func(a [2]int) int { return a[0] * a[1] }, "(F '(3 4 5))", "F parameter 1: (3 4 5) doesn't have 2 elements"},
+		{"array result", func() [2]string { return [2]string{"x", "y"} }, "(F)", `("x" "y")`},
+		{"uint8", func(u uint8) uint8 { return u + 1 }, "(F 254)", "255"},
+		{"negative uint", func(u uint) uint { return u }, "(F -1)", "F parameter 1: -1 is not an integer that fits in a uint"},
+		{"float32", func(f float32) float32 { return f }, "(F 1/4)", "0.25"},
+		{"infinity", func() float64 { return math.Inf(1) }, "(F)", "+inf.0"},
+		{"interface int", func(i interface{}) string { return fmt.Sprintf("%T", i) }, "(F 12)", `"int"`},
+		{"interface big int", func(i interface{}) string { return fmt.Sprintf("%T", i) }, "(F 100000000000000000000)", `"*big.Int"`},
+		{"interface ratio", func(i interface{}) string { return fmt.Sprintf("%T", i) }, "(F 1/3)", `"*big.Rat"`},
+		{"interface symbol", func(i interface{}) string { return fmt.Sprintf("%T", i) }, "(F 'A)", `"string"`},
+		{"interface list", func(i interface{}) string { return fmt.Sprintf("%T", i) }, "(F '(1))", `"[]interface {}"`},
+		{"interface nil", func(i interface{}) bool { return i == nil }, "(F NIL)", "T"},
+		{"interface result", func() interface{} { return 5 }, "(F)", "5"},
+		{"nil interface result", func() interface{} { return nil }, "(F)", "()"},
+		{"nil map param", func(m map[string]int) bool { return m == nil }, "(F NIL)", "T"},
+		{"map param", func(m map[string]int) bool { return m == nil }, "(F 1)", "F parameter 1: 1 is not a map[string]int"},
+		{"map result", func() map[string]int { return map[string]int{"a": 1} }, "(F)", "#<GO map[string]int map[a:1]>"},
+		{"nil map result", func() map[string]int { return nil }, "(F)", "()"},
+		{"struct param", func(p testPoint) int { return p.X }, "(F 1)", "F parameter 1: 1 is not a evaluator.testPoint"},
+		{"expr", func(e types.Expr) types.Expr { return e }, "(F '(A \"b\"))", `(A "b")`},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			if err := in.Register("F", d.fn); err != nil {
+				t.Fatal(err)
+			}
+			interpreterEvaluator(t, in, d.input, d.expected)
+		})
+	}
+}
+
+func internalEvaluator(t *testing.T, input string, expected string) {
+	interpreterEvaluator(t, Default(), input, expected)
+}
+
+func interpreterEvaluator(t *testing.T, in *Interpreter, input string, expected string) {
+	t.Helper()
+	tokens, _ := scanner.Scan(input)
+	expr, _, _ := parser.Parse(tokens)
+	out, err := in.Eval(expr)
+	if err != nil {
+		//positions are checked in TestErrorPositions
+		msg := err.Error()
+		var ee *EvalError
+		if errors.As(err, &ee) {
+			msg = ee.Err.Error()
+		}
+		if msg != expected {
+			t.Errorf("Unexpected error. Expected %s, got %v", expected, msg)
+		}
+	} else {
+		if out.String() != expected {
+			t.Errorf("Expected %s, got %v ", expected, out)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	data := []struct {
+		input    string
+		expected string
+	}{
+		{"(+ 1\n   (CAR 2))", "2:4: CAR parameter must be a list"},
+		{"(CONS 1\n    X)", "2:5: unknown symbol X "},
+		{"(SETQ F (LAMBDA (X)\n  (/ X 0)))\n(F 2)", "2:3: division by zero"},
+		{"(1 2)", "1:1: 1 is not a function"},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			_, err := New().EvalString(d.input)
+			if err == nil {
+				t.Fatalf("Expected error %s", d.expected)
+			}
+			if err.Error() != d.expected {
+				t.Errorf("Expected %s, got %v", d.expected, err)
+			}
+		})
+	}
+
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "bad.lisp")
+	err := os.WriteFile(fileName, []byte("(SETQ A 1)\n(SETQ B\n  (+ A \"x\"))\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New().EvalString(fmt.Sprintf("(LOAD %q)", fileName))
+	expected := fileName + `:3:3: "x" is not a valid number`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %s, got %v", expected, err)
+	}
+}
+
+func TestComments(t *testing.T) {
+	in := New()
+	out, err := in.EvalString("; set up\n(SETQ A #| one |# 1) #;(SETQ A 2)\n(CONS A #;A NIL)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "(1)" {
+		t.Errorf("Expected (1), got %s", out)
+	}
+
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "comments.lisp")
+	err = os.WriteFile(fileName, []byte(`; a file with comments
+#| a block comment
+   with (unbalanced parens
+   #| and a nested |#
+|#
+
+(SETQ B ; the value is
+  2)
+#;(SETQ B
+     3)
+(SETQ C "; not a comment")
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	in = New(WithOutput(&buf))
+	interpreterEvaluator(t, in, fmt.Sprintf("(LOAD %q)", fileName), "T")
+	interpreterEvaluator(t, in, "B", "2")
+	interpreterEvaluator(t, in, "C", `"; not a comment"`)
+}
+
+func TestLoadEveryForm(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "forms.lisp")
+	err := os.WriteFile(fileName, []byte("(SETQ A 1) (SETQ B 2)\n(SETQ C\n 3) (SETQ D 4)\n(SETQ E 5)"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	in := New(WithOutput(&buf))
+	interpreterEvaluator(t, in, fmt.Sprintf("(LOAD %q)", fileName), "T")
+	for _, d := range []struct{ input, expected string }{{"A", "1"}, {"B", "2"}, {"C", "3"}, {"D", "4"}, {"E", "5"}} {
+		interpreterEvaluator(t, in, d.input, d.expected)
+	}
+	if buf.String() != "1\n2\n3\n4\n5\n" {
+		t.Errorf("Expected every result to be written, got %q", buf.String())
+	}
+
+	err = os.WriteFile(fileName, []byte("(SETQ A 1) (SETQ B"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(WithOutput(&buf)).EvalString(fmt.Sprintf("(LOAD %q)", fileName))
+	expected := fileName + ":1:12: Left paren without matching right paren"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %s, got %v", expected, err)
+	}
+}
+
+func TestConditions(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"error", `(ERROR "bad thing")`, "bad thing"},
+		{"error kind", `(ERROR 'MY-ERROR "bad thing" 12)`, "MY-ERROR: bad thing"},
+		{"error no message", `(ERROR 'MY-ERROR)`, "missing message for ERROR"},
+		{"error bad message", `(ERROR 12)`, "ERROR message must be a string"},
+		{"error too many", `(ERROR 'A "b" 1 2)`, "too many parameters for ERROR"},
+		{"caught", `(HANDLER-CASE (ERROR "bad thing") (ERROR (E) (ERROR-MESSAGE E)))`, `"bad thing"`},
+		{"no error", `(HANDLER-CASE (+ 1 2) (ERROR (E) 0))`, "3"},
+		{"by kind", `(HANDLER-CASE (ERROR 'B "oops" 7)
+			(A (E) 'WRONG)
+			(B (E) (CONS (ERROR-KIND E) (ERROR-PAYLOAD E))))`, "(B . 7)"},
+		{"not matched", `(HANDLER-CASE (ERROR 'B "oops") (A (E) 'WRONG))`, "B: oops"},
+		{"no variable", `(HANDLER-CASE (ERROR 'B "oops") (B () 'CAUGHT))`, "CAUGHT"},
+		{"empty body", `(HANDLER-CASE (ERROR "oops") (ERROR (E)))`, "()"},
+		{"body", `(HANDLER-CASE (ERROR "oops") (ERROR (E) 1 2 3))`, "3"},
+		{"error value", `(HANDLER-CASE (ERROR 'B "oops" '(1 2)) (ERROR (E) E))`, `#<ERROR B "oops" (1 2)>`},
+		{"builtin error", `(HANDLER-CASE (CAR 1) (ERROR (E) (ERROR-MESSAGE E)))`, `"CAR parameter must be a list"`},
+		{"division by zero", `(HANDLER-CASE (/ 1 0) (ERROR (E) (CONS (ERROR-KIND E) (ERROR-MESSAGE E))))`, `(ERROR . "division by zero")`},
+		{"unknown symbol", `(HANDLER-CASE (+ 1 NOPE) (ERROR (E) (ERROR-MESSAGE E)))`, `"unknown symbol NOPE "`},
+		{"nested", `(HANDLER-CASE (HANDLER-CASE (ERROR 'B "inner") (A (E) 'WRONG)) (B (E) 'OUTER))`, "OUTER"},
+		{"resignal", `(HANDLER-CASE (HANDLER-CASE (ERROR 'B "inner") (ERROR (E) (ERROR E))) (B (E) (ERROR-MESSAGE E)))`, `"inner"`},
+		{"error in handler", `(HANDLER-CASE (ERROR "first") (ERROR (E) (ERROR "second")))`, "second"},
+		{"bad clause", `(HANDLER-CASE (ERROR "first") (1 (E) 2))`, "HANDLER-CASE clause must start with an error kind"},
+		{"error-kind not error", `(ERROR-KIND 1)`, "ERROR-KIND parameter must be an error"},
+		{"error atom", `(ATOM (HANDLER-CASE (CAR 1) (ERROR (E) E)))`, "T"},
+		{"catch", `(CATCH 'DONE 1 (THROW 'DONE 2) 3)`, "2"},
+		{"catch no throw", `(CATCH 'DONE 1 2 3)`, "3"},
+		{"catch empty", `(CATCH 'DONE)`, "()"},
+		{"throw no value", `(CATCH 'DONE (THROW 'DONE))`, "()"},
+		{"throw from function", `(PROGN
+			(SETQ FIND-NEG (LAMBDA (L) (COND ((EQ L NIL) NIL) ((< (CAR L) 0) (THROW 'FOUND (CAR L))) (T (FIND-NEG (CDR L))))))
+			(CATCH 'FOUND (FIND-NEG '(1 2 -3 4))))`, "-3"},
+		{"inner catch", `(CATCH 'A (CATCH 'B (THROW 'A 1)) 2)`, "1"},
+		{"no catch", `(THROW 'NOWHERE 1)`, "THROW to NOWHERE without a matching CATCH"},
+		{"handler-case ignores throw", `(CATCH 'A (HANDLER-CASE (THROW 'A 1) (ERROR (E) 2)))`, "1"},
+		{"unwind-protect", `(PROGN (SETQ CLEANED NIL) (CONS (UNWIND-PROTECT 1 (SETQ CLEANED T)) CLEANED))`, "(1 . T)"},
+		{"unwind-protect error", `(PROGN
+			(SETQ CLEANED NIL)
+			(HANDLER-CASE (UNWIND-PROTECT (ERROR "oops") (SETQ CLEANED T)) (ERROR (E) (CONS (ERROR-MESSAGE E) CLEANED))))`, `("oops" . T)`},
+		{"unwind-protect throw", `(PROGN
+			(SETQ CLEANED NIL)
+			(CONS (CATCH 'X (UNWIND-PROTECT (THROW 'X 1) (SETQ CLEANED T))) CLEANED))`, "(1 . T)"},
+		{"unwind-protect cleanup error", `(UNWIND-PROTECT (ERROR "first") (ERROR "second"))`, "second"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, New(), d.input, d.expected)
+		})
+	}
+
+	//errors that aren't caught are returned to Go
+	_, err := New().EvalString(`(CAR '(1))
+  (ERROR 'MY-ERROR "bad thing" 12)`)
+	var le *types.Error
+	if !errors.As(err, &le) {
+		t.Fatalf("Expected a *types.Error, got %v", err)
+	}
+	if le.Kind != "MY-ERROR" || le.Message != "bad thing" || le.Payload.String() != "12" {
+		t.Errorf("Unexpected error value %s", le)
+	}
+	if err.Error() != "2:3: MY-ERROR: bad thing" {
+		t.Errorf("Expected 2:3: MY-ERROR: bad thing, got %s", err)
+	}
+}
+
+func TestBacktrace(t *testing.T) {
+	in := New()
+	_, err := in.EvalString(`(SETQ G (LAMBDA (X) (CAR X)))
+(SETQ F (LAMBDA (X)
+  (+ 1 (G X))))
+(SETQ TAIL (LAMBDA (X) (F X)))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"nested", "(F 2)", []string{"G: (G X) at 3:8", "F: (F 2) at 1:1"}},
+		{"tail call", "(TAIL 2)", []string{"G: (G X) at 3:8", "F: (F X) at 4:24"}},
+		{"lambda tail call", "((LAMBDA (Y) (G Y)) 2)", []string{"G: (G Y) at 1:14"}},
+		{"lambda", "((LAMBDA (Y) (+ 1 (G Y))) 2)", []string{"G: (G Y) at 1:19", "LAMBDA: ((LAMBDA (Y) (+ 1 (G Y))) 2) at 1:1"}},
+		{"builtin", "(CAR 2)", nil},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			_, err := in.EvalString(d.input)
+			var ee *EvalError
+			if !errors.As(err, &ee) {
+				t.Fatalf("Expected an EvalError, got %v", err)
+			}
+			var trace []string
+			for _, f := range ee.Trace {
+				trace = append(trace, f.String())
+			}
+			if !reflect.DeepEqual(trace, d.expected) {
+				t.Errorf("Expected %q, got %q", d.expected, trace)
+			}
+		})
+	}
+
+	interpreterEvaluator(t, in, "(HANDLER-CASE (F 2) (ERROR (E) (BACKTRACE E)))", `("G: (G X) at 3:8" "F: (F 2) at 1:15")`)
+	interpreterEvaluator(t, in, "(HANDLER-CASE (G 2) (ERROR (E) (BACKTRACE E)))", `("G: (G 2) at 1:15")`)
+	interpreterEvaluator(t, in, "(HANDLER-CASE (CAR 2) (ERROR (E) (BACKTRACE E)))", "()")
+	interpreterEvaluator(t, in, `(HANDLER-CASE
+  (HANDLER-CASE (F 2) (ERROR (E) (ERROR E)))
+  (ERROR (E) (BACKTRACE E)))`, `("G: (G X) at 3:8" "F: (F 2) at 2:17")`)
+	interpreterEvaluator(t, in, "(BACKTRACE 1)", "BACKTRACE parameter must be an error")
+}
+
+func TestDestructuring(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"let", "(LET (((A B) '(1 2))) (+ A B))", "3"},
+		{"let nested", "(LET (((A (B C)) '(1 (2 3)))) (CONS A (CONS B C)))", "(1 2 . 3)"},
+		{"let dotted", "(LET (((A . B) '(1 2 3))) (CONS B A))", "((2 3) . 1)"},
+		{"let dotted pair", "(LET (((A . B) (CONS 1 2))) (+ A B))", "3"},
+		{"let dotted empty", "(LET (((A . B) '(1))) (CONS A B))", "(1)"},
+		{"let empty pattern", "(LET ((() '()) (A 1)) A)", "1"},
+		{"let sequential", "(LET (((A B) '(1 2)) (C (+ A B))) C)", "3"},
+		{"let too few", "(LET (((A B C) '(1 2))) A)", "(1 2) doesn't match the pattern (A B C): not enough values"},
+		{"let too many", "(LET (((A B) '(1 2 3))) A)", "(1 2 3) doesn't match the pattern (A B): too many values"},
+		{"let not a list", "(LET (((A B) 5)) A)", "5 doesn't match the pattern (A B): not a list"},
+		{"let nested mismatch", "(LET (((A (B C)) '(1 2))) A)", "2 doesn't match the pattern (B C): not a list"},
+		{"let bad pattern", "(LET (((A 1) '(1 2))) A)", "LET variable names must be Atoms or patterns"},
+		{"lambda", "((LAMBDA ((A B) C) (+ A (+ B C))) '(1 2) 3)", "6"},
+		{"lambda nested rest", "((LAMBDA ((A . REST) &OPTIONAL (N 0)) (CONS REST N)) '(1 2 3))", "((2 3) . 0)"},
+		{"lambda mismatch", "(PROGN (SETQ F (LAMBDA ((A B)) A)) (F '(1)))", "(1) doesn't match the pattern (A B): not enough values"},
+		{"lambda duplicate", "(LAMBDA ((A B) A) 1)", "A appears more than once in the parameter list for LAMBDA"},
+		{"lambda print", "(LAMBDA ((A . B) C) A)", "(LAMBDA ((A . B) C) A )"},
+		{"macro", `(PROGN
+			(DEFMACRO SWAP-LET ((A B) BODY) (QUASIQUOTE (LET (((UNQUOTE B) 1) ((UNQUOTE A) 2)) (UNQUOTE BODY))))
+			(SWAP-LET (X Y) (CONS X Y)))`, "(2 . 1)"},
+		{"bind", "(DESTRUCTURING-BIND (A (B . C)) '(1 (2 3 4)) (CONS A (CONS B C)))", "(1 2 3 4)"},
+		{"bind symbol", "(DESTRUCTURING-BIND ALL '(1 2) ALL)", "(1 2)"},
+		{"bind body", "(DESTRUCTURING-BIND (A B) '(1 2) (SETQ C A) (+ C B))", "3"},
+		{"bind empty body", "(DESTRUCTURING-BIND (A B) '(1 2))", "()"},
+		{"bind mismatch", "(DESTRUCTURING-BIND (A B) '(1 2 3) A)", "(1 2 3) doesn't match the pattern (A B): too many values"},
+		{"bind duplicate", "(DESTRUCTURING-BIND (A (A)) '(1 (2)) A)", "A appears more than once in the pattern for DESTRUCTURING-BIND"},
+		{"bind missing", "(DESTRUCTURING-BIND (A B))", "missing expression for DESTRUCTURING-BIND"},
+		{"bind bad pattern", `(DESTRUCTURING-BIND (A "b") '(1 2) A)`, `"b" can't be used in a pattern`},
+		{"bind rest", "(DESTRUCTURING-BIND (A &REST B) '(1 2) B)", "(2)"},
+		{"bind rest pattern", "(DESTRUCTURING-BIND (A &REST (B C)) '(1 2 3) (CONS A (CONS B C)))", "(1 2 . 3)"},
+		{"bind rest empty", "(DESTRUCTURING-BIND ((A &REST B) C) '((1) 2) (CONS B C))", "(() . 2)"},
+		{"bind rest two patterns", "(DESTRUCTURING-BIND (A &REST B C) '(1 2) B)", "&REST must be followed by a single pattern"},
+		{"bind optional", "(DESTRUCTURING-BIND (A &OPTIONAL B) '(1 2) B)", "&OPTIONAL is in the wrong place in a pattern"},
+		{"let rest", "(LET (((A &REST B) '(1 2))) B)", "(2)"},
+		{"lambda rest", "((LAMBDA ((A &REST B)) B) '(1 2))", "(2)"},
+		{"lambda key", "(LAMBDA ((A &KEY B)) B)", "&KEY is in the wrong place in a pattern"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, New(), d.input, d.expected)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"literal", "(MATCH 2 (1 'ONE) (2 'TWO))", "TWO"},
+		{"string", `(MATCH "b" ("a" 1) ("b" 2))`, "2"},
+		{"keyword", "(MATCH :B (:A 1) (:B 2))", "2"},
+		{"quoted", "(MATCH 'FOO ('BAR 1) ('FOO 2))", "2"},
+		{"quoted list", "(MATCH '(1 2) ('(1 2) 'YES) (_ 'NO))", "YES"},
+		{"nil", "(MATCH '() ((A . B) 'PAIR) (NIL 'EMPTY))", "EMPTY"},
+		{"empty", "(MATCH '() (() 'EMPTY))", "EMPTY"},
+		{"binder", "(MATCH 5 (X (+ X 1)))", "6"},
+		{"wildcard", "(MATCH 5 (_ 'ANY))", "ANY"},
+		{"wildcard doesn't bind", "(MATCH 5 (_ _))", "unknown symbol _ "},
+		{"list", "(MATCH '(1 2 3) ((A B) 'TWO) ((A B C) (+ A (+ B C))))", "6"},
+		{"nested", "(MATCH '(1 (2 3)) ((A (B C)) (CONS C (CONS B A))))", "(3 2 . 1)"},
+		{"dotted rest", "(MATCH '(1 2 3) ((A . REST) REST))", "(2 3)"},
+		{"&rest", "(MATCH '(1 2 3) ((A B &REST REST) (CONS A REST)))", "(1 3)"},
+		{"rest empty", "(MATCH '(1) ((A . REST) REST))", "()"},
+		{"repeated binder", "(MATCH '(1 2) ((A A) 'SAME) ((A B) 'DIFFERENT))", "DIFFERENT"},
+		{"repeated binder same", "(MATCH '(1 1) ((A A) 'SAME) ((A B) 'DIFFERENT))", "SAME"},
+		{"predicate", "(MATCH 'FOO ((? ATOM X) X))", "FOO"},
+		{"predicate error", "(MATCH 'FOO ((? ZEROP) 'ZERO))", "FOO is not a valid number"},
+		{"predicate lambda", "(MATCH 7 ((? (LAMBDA (N) (> N 5)) N) (* N 2)) (_ 0))", "14"},
+		{"predicate false", "(MATCH '(1) ((? ATOM) 'ATOM) (_ 'LIST))", "LIST"},
+		{"or", "(MATCH 3 ((OR 1 2) 'SMALL) ((OR 3 4) 'MEDIUM))", "MEDIUM"},
+		{"or binds", "(MATCH '(2 5) ((OR (1 X) (2 X)) X))", "5"},
+		{"or discards failed bindings", "(MATCH '(1 2) ((OR (A 3) (B A)) A))", "2"},
+		{"quoted or", "(MATCH '(OR 1) (('OR X) X))", "1"},
+		{"guard", "(MATCH 5 (N :WHEN (< N 3) 'SMALL) (N :WHEN (< N 10) 'MEDIUM) (_ 'LARGE))", "MEDIUM"},
+		{"body", "(MATCH 1 (X (SETQ Y X) (+ Y 1)))", "2"},
+		{"empty body", "(MATCH 1 (X))", "()"},
+		{"scope", "(PROGN (SETQ X 1) (MATCH 2 (X X)) X)", "1"},
+		{"no match", "(MATCH '(1 2) ((A) A))", "no MATCH clause matched (1 2)"},
+		{"no match caught", "(HANDLER-CASE (MATCH 1 (2 2)) (ERROR (E) (ERROR-MESSAGE E)))", `"no MATCH clause matched 1"`},
+		{"missing expression", "(MATCH)", "missing expression for MATCH"},
+		{"bad clause", "(MATCH 1 X)", "MATCH clause must be a list that starts with a pattern"},
+		{"bad rest", "(MATCH '(1) ((A &REST) A))", "&REST must be followed by a single pattern"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, New(), d.input, d.expected)
+		})
+	}
+}
+
+func TestMaps(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"literal", "{:B 2 :A 1}", "{:A 1 :B 2}"},
+		{"empty literal", "{}", "{}"},
+		{"literal is data", "{A (+ 1 2)}", "{A (+ 1 2)}"},
+		{"print order", `{B 1 "s" 2 (1 2) 3 10 4 2 5 1/2 6}`, `{1/2 6 2 5 10 4 "s" 2 B 1 (1 2) 3}`},
+		{"make", "(MAKE-MAP 'A (+ 1 2) 'B 4)", "{A 3 B 4}"},
+		{"make empty", "(MAKE-MAP)", "{}"},
+		{"make odd", "(MAKE-MAP 'A)", "MAKE-MAP requires a value for every key"},
+		{"make bad key", "(MAKE-MAP (LAMBDA (X) X) 1)", "(LAMBDA (X) X ) can't be used as a map key"},
+		{"get", "(MAP-GET {:A 1 :B 2} :B)", "2"},
+		{"get missing", "(MAP-GET {:A 1} :B)", "()"},
+		{"get default", "(MAP-GET {:A 1} :B 'NONE)", "NONE"},
+		{"get number by value", "(MAP-GET {1/2 A} (/ 2 4))", "A"},
+		{"get exact isn't float", "(MAP-GET {1 A} 1.0)", "()"},
+		{"get list key", "(MAP-GET {(1 (2 3)) A} (CONS 1 (CONS (CONS 2 (CONS 3 NIL)) NIL)))", "A"},
+		{"get string key", `(MAP-GET {"a" 1} "a")`, "1"},
+		{"get not a map", "(MAP-GET '(1 2) 1)", "MAP-GET parameter must be a map"},
+		{"get too few", "(MAP-GET {})", "MAP-GET requires at least 2 parameters"},
+		{"put", "(MAP-PUT {:A 1} :B 2)", "{:A 1 :B 2}"},
+		{"put replaces", "(MAP-PUT {:A 1} :A 2)", "{:A 2}"},
+		{"put changes map", "(PROGN (SETQ M (MAKE-MAP)) (MAP-PUT M 1 'ONE) (MAP-GET M 1))", "ONE"},
+		{"put bad key", "(MAP-PUT {} {} 1)", "{} can't be used as a map key"},
+		{"delete", "(MAP-DELETE {:A 1 :B 2} :A)", "{:B 2}"},
+		{"delete missing", "(MAP-DELETE {:A 1} :B)", "{:A 1}"},
+		{"has", "(MAP-HAS {:A NIL} :A)", "T"},
+		{"has not", "(MAP-HAS {:A 1} :B)", "()"},
+		{"keys", "(MAP-KEYS {:B 2 :A 1})", "(:A :B)"},
+		{"keys empty", "(MAP-KEYS {})", "()"},
+		{"values", "(MAP-VALUES {:B 2 :A 1})", "(1 2)"},
+		{"for each", "(PROGN (SETQ TOTAL 0) (MAP-FOR-EACH (LAMBDA (K V) (SETQ TOTAL (+ TOTAL (* K V)))) {1 2 3 4}) TOTAL)", "14"},
+		{"for each error", "(MAP-FOR-EACH (LAMBDA (K) K) {1 2})", "too many parameters for LAMBDA. Expected 1, got 2"},
+		{"literal is new each time", "(PROGN (SETQ F (LAMBDA () {})) (MAP-PUT (F) 1 2) (F))", "{}"},
+		{"nested literal is new each time", "(PROGN (SETQ F (LAMBDA () {:A {} :B ({})})) (MAP-PUT (MAP-GET (F) :A) 1 2) (MAP-PUT (CAR (MAP-GET (F) :B)) 3 4) (F))", "{:A {} :B ({})}"},
+		{"eq", "(EQ {:A 1 :B (1 2)} (MAKE-MAP :B (CONS 1 (CONS 2 NIL)) :A 1))", "T"},
+		{"not eq", "(EQ {:A 1} {:A 2})", "()"},
+		{"atom", "(ATOM {})", "T"},
+		{"match", "(MATCH {:A 1} ({:A 2} 'TWO) ({:A 1} 'ONE))", "ONE"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, New(), d.input, d.expected)
+		})
+	}
+}
+
+func TestMapStoreLoad(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "maps.lisp")
+	in := New()
+	interpreterEvaluator(t, in, `(SETQ M (MAKE-MAP :NAME "Bob" 'NUMS '(1 2/3 4.5) '(A B) {1 2}))`, `{:NAME "Bob" NUMS (1 2/3 4.5) (A B) {1 2}}`)
+	interpreterEvaluator(t, in, fmt.Sprintf("(STORE %q)", fileName), "T")
+	in2 := New(WithOutput(&bytes.Buffer{}))
+	interpreterEvaluator(t, in2, fmt.Sprintf("(LOAD %q)", fileName), "T")
+	interpreterEvaluator(t, in2, `(EQ M (MAKE-MAP :NAME "Bob" 'NUMS '(1 2/3 4.5) '(A B) {1 2}))`, "T")
+}
+
+func TestVectors(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"literal", "[1 (2 3) A]", "[1 (2 3) A]"},
+		{"hash literal", "#(1 2 3)", "[1 2 3]"},
+		{"empty literal", "[]", "[]"},
+		{"literal is data", "[(+ 1 2)]", "[(+ 1 2)]"},
+		{"vector", "(VECTOR 1 (+ 1 1) 'C)", "[1 2 C]"},
+		{"vector empty", "(VECTOR)", "[]"},
+		{"make", "(MAKE-VECTOR 3 'X)", "[X X X]"},
+		{"make no fill", "(MAKE-VECTOR 2)", "[() ()]"},
+		{"make negative", "(MAKE-VECTOR -1)", "MAKE-VECTOR length -1 can't be negative"},
+		{"make not integer", "(MAKE-VECTOR 'A)", "MAKE-VECTOR parameter must be an integer"},
+		{"ref", "(VECTOR-REF [A B C] 1)", "B"},
+		{"ref out of bounds", "(VECTOR-REF [A B C] 3)", "VECTOR-REF index 3 is out of bounds for a length of 3"},
+		{"ref negative", "(VECTOR-REF [A] -1)", "VECTOR-REF index -1 is out of bounds for a length of 1"},
+		{"ref not a vector", "(VECTOR-REF '(A) 0)", "VECTOR-REF parameter must be a vector"},
+		{"set", "(VECTOR-SET! [A B C] 1 'X)", "[A X C]"},
+		{"set changes vector", "(PROGN (SETQ V (MAKE-VECTOR 2 0)) (VECTOR-SET! V 0 5) (VECTOR-REF V 0))", "5"},
+		{"set out of bounds", "(VECTOR-SET! [] 0 1)", "VECTOR-SET! index 0 is out of bounds for a length of 0"},
+		{"length", "(VECTOR-LENGTH [1 2 3])", "3"},
+		{"to list", "(VECTOR->LIST [1 2 3])", "(1 2 3)"},
+		{"to list empty", "(VECTOR->LIST [])", "()"},
+		{"to list range", "(VECTOR->LIST [1 2 3 4] 1 3)", "(2 3)"},
+		{"to list start", "(VECTOR->LIST [1 2 3 4] 2)", "(3 4)"},
+		{"from list", "(LIST->VECTOR (CONS 1 (CONS 2 NIL)))", "[1 2]"},
+		{"from empty list", "(LIST->VECTOR NIL)", "[]"},
+		{"from end of list", "(LIST->VECTOR (CDR '(1)))", "[]"},
+		{"from not a list", "(LIST->VECTOR 5)", "LIST->VECTOR parameter must be a list"},
+		{"from dotted list", "(LIST->VECTOR (CONS 1 2))", "can't have a dotted pair here"},
+		{"append", "(VECTOR-APPEND [1 2] [] [3])", "[1 2 3]"},
+		{"append none", "(VECTOR-APPEND)", "[]"},
+		{"append not a vector", "(VECTOR-APPEND [1] '(2))", "VECTOR-APPEND parameter must be a vector"},
+		{"slice", "(VECTOR-SLICE [A B C D] 1 3)", "[B C]"},
+		{"slice to end", "(VECTOR-SLICE [A B C D] 2)", "[C D]"},
+		{"slice bad range", "(VECTOR-SLICE [A B] 2 1)", "VECTOR-SLICE range 2 to 1 is out of bounds for a vector of length 2"},
+		{"slice is a copy", "(PROGN (SETQ V [1 2 3]) (VECTOR-SET! (VECTOR-SLICE V 0 2) 0 'X) V)", "[1 2 3]"},
+		{"literal is new each time", "(PROGN (SETQ F (LAMBDA () [1])) (VECTOR-SET! (F) 0 2) (F))", "[1]"},
+		{"nested literal is new each time", "(PROGN (SETQ G (LAMBDA () [[1 2] {:A [3]}])) (VECTOR-SET! (VECTOR-REF (G) 0) 0 'X) (VECTOR-SET! (MAP-GET (VECTOR-REF (G) 1) :A) 0 'Y) (G))", "[[1 2] {:A [3]}]"},
+		{"eq", "(EQ [1 (2) [3]] (VECTOR 1 (CONS 2 NIL) (VECTOR 3)))", "T"},
+		{"not eq", "(EQ [1 2] [1 2 3])", "()"},
+		{"not eq list", "(EQ [1 2] '(1 2))", "()"},
+		{"atom", "(ATOM [])", "T"},
+		{"in map", "(MAP-GET {:V [1 2]} :V)", "[1 2]"},
+		{"not a map key", "(MAKE-MAP [1] 2)", "[1] can't be used as a map key"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, New(), d.input, d.expected)
+		})
+	}
+}
+
+func TestSets(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"literal", "#{C A B A}", "#{A B C}"},
+		{"empty literal", "#{}", "#{}"},
+		{"literal is data", "#{(+ 1 2)}", "#{(+ 1 2)}"},
+		{"equal numbers", "#{1/2 2/4 0.5 1 1.0}", "#{1/2 0.5 1 1.0}"},
+		{"make", "(MAKE-SET 1 (+ 1 1) 2)", "#{1 2}"},
+		{"make bad member", "(MAKE-SET [1])", "[1] can't be a member of a set"},
+		{"add", "(SET-ADD #{1} 2)", "#{1 2}"},
+		{"add existing", "(SET-ADD #{1/2} (/ 2 4))", "#{1/2}"},
+		{"add changes set", "(PROGN (SETQ S (MAKE-SET)) (SET-ADD S 'A) S)", "#{A}"},
+		{"add bad member", "(SET-ADD #{} {})", "{} can't be a member of a set"},
+		{"add not a set", "(SET-ADD '(1) 2)", "SET-ADD parameter must be a set"},
+		{"remove", "(SET-REMOVE #{1 2} 1)", "#{2}"},
+		{"remove missing", "(SET-REMOVE #{1 2} 3)", "#{1 2}"},
+		{"contains", "(SET-CONTAINS #{1/2 (A B)} (/ 2 4))", "T"},
+		{"contains list", "(SET-CONTAINS #{1/2 (A B)} (CONS 'A (CONS 'B NIL)))", "T"},
+		{"contains not", "(SET-CONTAINS #{1} 1.0)", "()"},
+		{"union", "(UNION #{1 2} #{2 3} #{4})", "#{1 2 3 4}"},
+		{"union none", "(UNION)", "#{}"},
+		{"intersection", "(INTERSECTION #{1 2 3} #{2 3 4} #{3 2})", "#{2 3}"},
+		{"intersection one", "(INTERSECTION #{1})", "#{1}"},
+		{"intersection none", "(INTERSECTION)", "missing parameters for INTERSECTION"},
+		{"difference", "(DIFFERENCE #{1 2 3 4} #{2} #{4 5})", "#{1 3}"},
+		{"difference not a set", "(DIFFERENCE #{1} '(1))", "DIFFERENCE parameter must be a set"},
+		{"subset", "(SUBSET? #{1 2} #{3 2 1})", "T"},
+		{"subset equal", "(SUBSET? #{1 2} #{1 2})", "T"},
+		{"subset empty", "(SUBSET? #{} #{})", "T"},
+		{"not subset", "(SUBSET? #{1 4} #{1 2 3})", "()"},
+		{"subset too many", "(SUBSET? #{} #{} #{})", "too many parameters for SUBSET?"},
+		{"to list", "(SET->LIST #{B A})", "(A B)"},
+		{"to list empty", "(SET->LIST #{})", "()"},
+		{"from list", "(LIST->SET (CONS 2 (CONS 1 (CONS 2 NIL))))", "#{1 2}"},
+		{"from empty list", "(LIST->SET NIL)", "#{}"},
+		{"from end of list", "(LIST->SET (CDR '(1)))", "#{}"},
+		{"from not a list", "(LIST->SET #{1})", "LIST->SET parameter must be a list"},
+		{"literal is new each time", "(PROGN (SETQ F (LAMBDA () #{})) (SET-ADD (F) 1) (F))", "#{}"},
+		{"eq", "(EQ #{1/2 A} (MAKE-SET 'A (/ 2 4)))", "T"},
+		{"not eq", "(EQ #{1 2} #{1 3})", "()"},
+		{"not eq size", "(EQ #{1 2} #{1})", "()"},
+		{"atom", "(ATOM #{})", "T"},
+		{"in map", "(MAP-GET {:S #{1}} :S)", "#{1}"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, New(), d.input, d.expected)
+		})
+	}
+}
+
+func TestStructs(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"defstruct", "(DEFSTRUCT POINT X Y)", "POINT"},
+		{"constructor", "(PROGN (DEFSTRUCT POINT X Y) (MAKE-POINT :Y 2 :X (+ 1 0)))", "#S(POINT :X 1 :Y 2)"},
+		{"constructor defaults", "(PROGN (DEFSTRUCT POINT X (Y (+ 1 1))) (MAKE-POINT))", "#S(POINT :X () :Y 2)"},
+		{"constructor bad field", "(PROGN (DEFSTRUCT POINT X) (MAKE-POINT :Z 1))", "MAKE-POINT has no keyword parameter :Z"},
+		{"no fields", "(PROGN (DEFSTRUCT EMPTY) (MAKE-EMPTY))", "#S(EMPTY)"},
+		{"predicate", "(PROGN (DEFSTRUCT POINT X) (POINT-P (MAKE-POINT)))", "T"},
+		{"predicate other struct", "(PROGN (DEFSTRUCT POINT X) (DEFSTRUCT LINE X) (POINT-P (MAKE-LINE)))", "()"},
+		{"predicate not struct", "(PROGN (DEFSTRUCT POINT X) (POINT-P '(X)))", "()"},
+		{"accessor", "(PROGN (DEFSTRUCT POINT X Y) (POINT-Y (MAKE-POINT :X 1 :Y 2)))", "2"},
+		{"accessor other struct", "(PROGN (DEFSTRUCT POINT X) (DEFSTRUCT LINE X) (POINT-X (MAKE-LINE :X 1)))", "#S(LINE :X 1) is not a POINT"},
+		{"setter", "(PROGN (DEFSTRUCT POINT X Y) (SETQ P (MAKE-POINT :X 1)) (SET-POINT-Y! P 5) P)", "#S(POINT :X 1 :Y 5)"},
+		{"setter not struct", "(PROGN (DEFSTRUCT POINT X) (SET-POINT-X! 5 1))", "5 is not a POINT"},
+		{"literal", "#S(POINT :X (1 2) :Y A)", "#S(POINT :X (1 2) :Y A)"},
+		{"literal works with accessors", "(PROGN (DEFSTRUCT POINT X Y) (POINT-X #S(POINT :X 3 :Y 4)))", "3"},
+		{"literal is new each time", "(PROGN (SETQ F (LAMBDA () #S(P :X 1))) (STRUCT-SET! (F) 'X 2) (F))", "#S(P :X 1)"},
+		{"nested literal is new each time", "(PROGN (SETQ H (LAMBDA () #S(P :X #S(Q :Y 1) :Z [#S(Q :Y 2)]))) (STRUCT-SET! (STRUCT-GET (H) 'X) 'Y 99) (STRUCT-SET! (VECTOR-REF (STRUCT-GET (H) 'Z) 0) 'Y 98) (H))", "#S(P :X #S(Q :Y 1) :Z [#S(Q :Y 2)])"},
+		{"eq", "(PROGN (DEFSTRUCT POINT X Y) (EQ (MAKE-POINT :X 1/2 :Y '(A)) #S(POINT :Y (A) :X 2/4)))", "T"},
+		{"not eq value", "(EQ #S(POINT :X 1) #S(POINT :X 2))", "()"},
+		{"not eq name", "(EQ #S(POINT :X 1) #S(LINE :X 1))", "()"},
+		{"not eq fields", "(EQ #S(POINT :X 1) #S(POINT :X 1 :Y 2))", "()"},
+		{"atom", "(ATOM #S(POINT))", "T"},
+		{"match", "(MATCH #S(P :X 1) (#S(P :X 2) 'TWO) (#S(P :X 1) 'ONE))", "ONE"},
+		{"make struct", "(MAKE-STRUCT 'POINT :X 1 'Y 2)", "#S(POINT :X 1 :Y 2)"},
+		{"make struct odd", "(MAKE-STRUCT 'POINT :X)", "MAKE-STRUCT requires a value for every field"},
+		{"make struct duplicate", "(MAKE-STRUCT 'POINT :X 1 :X 2)", "MAKE-STRUCT has the field X more than once"},
+		{"make struct bad name", "(MAKE-STRUCT 5)", "MAKE-STRUCT name must be an Atom"},
+		{"structp", "(STRUCTP #S(POINT))", "T"},
+		{"structp name", "(STRUCTP #S(POINT) 'LINE)", "()"},
+		{"struct name", "(STRUCT-NAME #S(POINT))", "POINT"},
+		{"struct name not struct", "(STRUCT-NAME 5)", "STRUCT-NAME parameter must be a struct"},
+		{"struct get", "(STRUCT-GET #S(POINT :X 1) :X)", "1"},
+		{"struct get missing", "(STRUCT-GET #S(POINT :X 1) 'Y)", "POINT has no field Y"},
+		{"struct get bad field", "(STRUCT-GET #S(POINT :X 1) 1)", "STRUCT-GET field name must be a symbol"},
+		{"struct set missing", "(STRUCT-SET! #S(POINT :X 1) 'Y 2)", "POINT has no field Y"},
+		{"bad name", "(DEFSTRUCT (POINT) X)", "DEFSTRUCT name must be an Atom"},
+		{"missing name", "(DEFSTRUCT)", "missing parameters for DEFSTRUCT"},
+		{"bad field", "(DEFSTRUCT POINT (X 1 2))", "fields in DEFSTRUCT POINT must be a name or a name and a default value"},
+		{"keyword field", "(DEFSTRUCT POINT :X)", "fields in DEFSTRUCT POINT must be a name or a name and a default value"},
+		{"duplicate field", "(DEFSTRUCT POINT X (X 1))", "X appears more than once in DEFSTRUCT POINT"},
+		{"field named P", "(DEFSTRUCT FOO X (P 1))", "field P in DEFSTRUCT FOO would replace the predicate FOO-P"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, New(), d.input, d.expected)
+		})
+	}
+}
+
+func TestStructStoreLoad(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "structs.lisp")
+	in := New()
+	interpreterEvaluator(t, in, "(DEFSTRUCT POINT X (Y 0))", "POINT")
+	interpreterEvaluator(t, in, `(SETQ P (MAKE-POINT :X '(1 "two" 3/4)))`, `#S(POINT :X (1 "two" 3/4) :Y 0)`)
+	interpreterEvaluator(t, in, fmt.Sprintf("(STORE %q)", fileName), "T")
+	in2 := New(WithOutput(&bytes.Buffer{}))
+	interpreterEvaluator(t, in2, fmt.Sprintf("(LOAD %q)", fileName), "T")
+	interpreterEvaluator(t, in2, `(EQ P (MAKE-POINT :X '(1 "two" 3/4)))`, "T")
+	interpreterEvaluator(t, in2, "(POINT-P P)", "T")
+	interpreterEvaluator(t, in2, "(SET-POINT-Y! P 5)", `#S(POINT :X (1 "two" 3/4) :Y 5)`)
+}
+
+func TestPersistent(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"assoc map", "(ASSOC {A 1} 'B 2 'A 3)", "{A 3 B 2}"},
+		{"assoc doesn't change map", "(PROGN (SETQ M {A 1}) (ASSOC M 'A 2) M)", "{A 1}"},
+		{"assoc vector", "(ASSOC [A B C] 1 'X)", "[A X C]"},
+		{"assoc vector end", "(ASSOC [A B] 2 'C 3 'D)", "[A B C D]"},
+		{"assoc doesn't change vector", "(PROGN (SETQ V [A B]) (ASSOC V 0 'X) V)", "[A B]"},
+		{"assoc out of bounds", "(ASSOC [A] 2 'X)", "ASSOC index 2 is out of bounds for a length of 1"},
+		{"assoc bad index", "(ASSOC [A] 'A 'X)", "ASSOC parameter must be an integer"},
+		{"assoc odd", "(ASSOC {} 'A 1 'B)", "ASSOC requires a value for every key"},
+		{"assoc map missing value", "(ASSOC {A 1} 'A)", "ASSOC requires at least 3 parameters"},
+		{"assoc vector missing value", "(ASSOC [A] 0)", "ASSOC requires at least 3 parameters"},
+		{"assoc alist", "(ASSOC '((A . 1)) 'A 2)", "ASSOC parameter must be a map or a vector"},
+		{"assoc bad key", "(ASSOC {} [1] 1)", "[1] can't be used as a map key"},
+		{"assoc bad coll", "(ASSOC #{} 1 2)", "ASSOC parameter must be a map or a vector"},
+		{"dissoc map", "(DISSOC {A 1 B 2 C 3} 'A 'C 'D)", "{B 2}"},
+		{"dissoc doesn't change map", "(PROGN (SETQ M {A 1}) (DISSOC M 'A) M)", "{A 1}"},
+		{"dissoc set", "(DISSOC #{1 2 3} 2)", "#{1 3}"},
+		{"dissoc bad coll", "(DISSOC [1] 0)", "DISSOC parameter must be a map or a set"},
+		{"conj vector", "(CONJ [1] 2 3)", "[1 2 3]"},
+		{"conj doesn't change vector", "(PROGN (SETQ V [1]) (CONJ V 2) V)", "[1]"},
+		{"conj set", "(CONJ #{1} 2 1)", "#{1 2}"},
+		{"conj map", "(CONJ {A 1} '(B . 2) [C 3])", "{A 1 B 2 C 3}"},
+		{"conj map bad pair", "(CONJ {} [A])", "CONJ can only add a (KEY . VALUE) pair or a [KEY VALUE] vector to a map"},
+		{"conj list", "(CONJ '(3) 2 1)", "(1 2 3)"},
+		{"conj empty list", "(CONJ NIL 1)", "(1)"},
+		{"conj bad coll", "(CONJ 5 1)", "CONJ parameter must be a vector, a set, a map or a list"},
+		{"conj shares structure", "(PROGN (SETQ A (CONJ [] 1)) (SETQ B (CONJ A 2)) (SETQ C (CONJ A 3)) (VECTOR A B C))", "[[1] [1 2] [1 3]]"},
+		{"large vector", `(PROGN
+			(SETQ BUILD (LAMBDA (V N) (COND ((EQ (VECTOR-LENGTH V) N) V) (T (BUILD (CONJ V (VECTOR-LENGTH V)) N)))))
+			(SETQ V (BUILD [] 2000))
+			(VECTOR (VECTOR-LENGTH V) (VECTOR-REF V 1999) (VECTOR-REF (ASSOC V 1500 'X) 1500) (VECTOR-REF V 1500)))`, "[2000 1999 X 1500]"},
+		{"transient vector", "(PERSISTENT! (CONJ! (ASSOC! (TRANSIENT [A B]) 0 'X 2 'C) 'D))", "[X B C D]"},
+		{"transient doesn't change vector", "(PROGN (SETQ V [A]) (CONJ! (TRANSIENT V) 'B) V)", "[A]"},
+		{"transient map", "(PERSISTENT! (DISSOC! (CONJ! (ASSOC! (TRANSIENT {A 1}) 'B 2) '(C . 3)) 'A))", "{B 2 C 3}"},
+		{"transient builds", `(PROGN
+			(SETQ FILL (LAMBDA (TV N) (COND ((EQ N 0) TV) (T (FILL (CONJ! TV N) (- N 1))))))
+			(VECTOR-LENGTH (PERSISTENT! (FILL (TRANSIENT []) 100))))`, "100"},
+		{"transient prints", "(TRANSIENT {A 1})", "#<TRANSIENT {A 1}>"},
+		{"transient atom", "(ATOM (TRANSIENT []))", "T"},
+		{"transient used after persistent", "(PROGN (SETQ TV (TRANSIENT [])) (PERSISTENT! TV) (CONJ! TV 1))", "transient used after it was made persistent"},
+		{"transient bad coll", "(TRANSIENT #{})", "TRANSIENT parameter must be a map or a vector"},
+		{"assoc! out of bounds", "(ASSOC! (TRANSIENT []) 1 'A)", "ASSOC! index 1 is out of bounds for a length of 0"},
+		{"assoc! not transient", "(ASSOC! {} 'A 1)", "ASSOC! parameter must be a transient"},
+		{"dissoc! vector", "(DISSOC! (TRANSIENT []) 0)", "DISSOC! parameter must be a transient map"},
+		{"conj! not transient", "(CONJ! [] 1)", "CONJ! parameter must be a transient"},
+		{"persistent! not transient", "(PERSISTENT! [])", "PERSISTENT! parameter must be a transient"},
+		{"map literal is still new each time", "(PROGN (SETQ F (LAMBDA () {A 1})) (MAP-PUT (F) 'A 2) (F))", "{A 1}"},
+		{"map-put doesn't change assoc result", "(PROGN (SETQ M {A 1}) (SETQ M2 (ASSOC M 'B 2)) (MAP-PUT M 'A 5) (VECTOR M M2))", "[{A 5} {A 1 B 2}]"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			interpreterEvaluator(t, New(), d.input, d.expected)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"empty", "(LIST)", "()"},
+		{"values", "(LIST 1 (+ 1 1) 'A)", "(1 2 A)"},
+		{"nested", "(LIST (LIST) '(1))", "(() (1))"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestLength(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"empty", "(LENGTH NIL)", "0"},
+		{"end of list", "(LENGTH (CDR '(1)))", "0"},
+		{"values", "(LENGTH '(1 2 (3 4)))", "3"},
+		{"not list", "(LENGTH 5)", "LENGTH parameter must be a list"},
+		{"dotted", "(LENGTH '(1 . 2))", "can't have a dotted pair here"},
+		{"missing", "(LENGTH)", "missing parameters for LENGTH"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"none", "(APPEND)", "()"},
+		{"one", "(APPEND '(1 2))", "(1 2)"},
+		{"several", "(APPEND '(1) NIL '(2 3) '(4))", "(1 2 3 4)"},
+		{"empty", "(APPEND NIL NIL)", "()"},
+		{"end of list", "(APPEND (CDR '(1)) '(2) (CDR '(3)))", "(2)"},
+		{"dotted end", "(APPEND '(1) 2)", "(1 . 2)"},
+		{"doesn't change first", "(LET ((A '(1))) (PROGN (APPEND A '(2)) A))", "(1)"},
+		{"not list", "(APPEND 1 '(2))", "APPEND parameter must be a list"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestReverse(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"empty", "(REVERSE NIL)", "()"},
+		{"end of list", "(REVERSE (CDR '(1)))", "()"},
+		{"values", "(REVERSE '(1 2 (3 4)))", "((3 4) 2 1)"},
+		{"not list", "(REVERSE 'A)", "REVERSE parameter must be a list"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestNth(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"first", "(NTH 0 '(A B C))", "A"},
+		{"last", "(NTH 2 '(A B C))", "C"},
+		{"past end", "(NTH 3 '(A B C))", "()"},
+		{"empty", "(NTH 0 NIL)", "()"},
+		{"end of list", "(NTH 0 (CDR '(1)))", "()"},
+		{"negative", "(NTH -1 '(A))", "NTH index -1 can't be negative"},
+		{"bad index", "(NTH 'A '(A))", "NTH parameter must be an integer"},
+		{"not list", "(NTH 0 5)", "NTH parameter must be a list"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestNthcdr(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"zero", "(NTHCDR 0 '(A B C))", "(A B C)"},
+		{"middle", "(NTHCDR 1 '(A B C))", "(B C)"},
+		{"end", "(NTHCDR 3 '(A B C))", "()"},
+		{"past end", "(NTHCDR 5 '(A B C))", "()"},
+		{"end of list", "(NTHCDR 1 (CDR '(1)))", "()"},
+		{"dotted", "(NTHCDR 1 '(A . B))", "B"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestLast(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"values", "(LAST '(1 2 3))", "(3)"},
+		{"one", "(LAST '(1))", "(1)"},
+		{"empty", "(LAST NIL)", "()"},
+		{"end of list", "(LAST (CDR '(1)))", "()"},
+		{"dotted", "(LAST '(1 2 . 3))", "(2 . 3)"},
+		{"not list", "(LAST 5)", "LAST parameter must be a list"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestMember(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"found", "(MEMBER 2 '(1 2 3))", "(2 3)"},
+		{"found list", "(MEMBER '(A) '(1 (A) 3))", "((A) 3)"},
+		{"found by value", "(MEMBER 1/2 '(2/4))", "(1/2)"},
+		{"missing", "(MEMBER 4 '(1 2 3))", "()"},
+		{"end of list", "(MEMBER 1 (CDR '(1)))", "()"},
+		{"not list", "(MEMBER 4 5)", "MEMBER parameter must be a list"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestAssq(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"found", "(ASSQ 'B '((A . 1) (B . 2)))", "(B . 2)"},
+		{"found list", "(ASSQ '(1) '(((1) X Y)))", "((1) X Y)"},
+		{"missing", "(ASSQ 'C '((A . 1) (B . 2)))", "()"},
+		{"empty", "(ASSQ 'C NIL)", "()"},
+		{"end of list", "(ASSQ 'C (CDR '(1)))", "()"},
+		{"not pairs", "(ASSQ 'C '(A))", "ASSQ list elements must be pairs"},
+		{"not list", "(ASSQ 'C 5)", "ASSQ parameter must be a list"},
+		{"vector key", "(ASSQ [1 2] '(([1 2] . X)))", "([1 2] . X)"},
+		{"map key", "(ASSQ {A 1} '((B . 1) ({A 1} . 2)))", "({A 1} . 2)"},
+		{"missing parameters", "(ASSQ 'A)", "ASSQ requires at least 2 parameters"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestMapcar(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"lambda", "(MAPCAR (LAMBDA (X) (* X X)) '(1 2 3))", "(1 4 9)"},
+		{"builtin", "(MAPCAR 'CAR '((1 2) (3 4)))", "(1 3)"},
+		{"several lists", "(MAPCAR '+ '(1 2 3) '(10 20))", "(11 22)"},
+		{"empty", "(MAPCAR 'CAR NIL)", "()"},
+		{"end of list", "(MAPCAR 'CAR (CDR '(1)))", "()"},
+		{"error", "(MAPCAR 'CAR '(1))", "CAR parameter must be a list"},
+		{"not list", "(MAPCAR 'CAR 1)", "MAPCAR parameter must be a list"},
+		{"missing list", "(MAPCAR 'CAR)", "MAPCAR requires at least 2 parameters"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"values", "(FILTER 'EVENP '(1 2 3 4))", "(2 4)"},
+		{"none", "(FILTER 'EVENP '(1 3))", "()"},
+		{"end of list", "(FILTER 'EVENP (CDR '(1)))", "()"},
+		{"lambda", "(FILTER (LAMBDA (X) (> X 1)) '(1 2 3))", "(2 3)"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestReduce(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"values", "(REDUCE '+ '(1 2 3 4))", "10"},
+		{"initial", "(REDUCE '- '(1 2) 10)", "7"},
+		{"one", "(REDUCE '+ '(5))", "5"},
+		{"empty initial", "(REDUCE '+ NIL 0)", "0"},
+		{"end of list", "(REDUCE '+ (CDR '(1)) 0)", "0"},
+		{"empty", "(REDUCE '+ NIL)", "REDUCE of an empty list requires an initial value"},
+		{"left to right", "(REDUCE (LAMBDA (A B) (CONS B A)) '(1 2 3) NIL)", "(3 2 1)"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestFoldLeft(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"values", "(FOLD-LEFT '- 10 '(1 2 3))", "4"},
+		{"order", "(FOLD-LEFT (LAMBDA (A X) (CONS X A)) NIL '(1 2 3))", "(3 2 1)"},
+		{"empty", "(FOLD-LEFT '+ 5 NIL)", "5"},
+		{"end of list", "(FOLD-LEFT '+ 5 (CDR '(1)))", "5"},
+		{"not list", "(FOLD-LEFT '+ 5 6)", "FOLD-LEFT parameter must be a list"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestFoldRight(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"values", "(FOLD-RIGHT '- 10 '(1 2 3))", "-8"},
+		{"order", "(FOLD-RIGHT 'CONS NIL '(1 2 3))", "(1 2 3)"},
+		{"empty", "(FOLD-RIGHT '+ 5 NIL)", "5"},
+		{"end of list", "(FOLD-RIGHT '+ 5 (CDR '(1)))", "5"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"numbers", "(SORT '(3 1 2) '<)", "(1 2 3)"},
+		{"descending", "(SORT '(3 1 2) '>)", "(3 2 1)"},
+		{"stable", "(SORT '((1 A) (0 B) (1 C) (0 D)) (LAMBDA (X Y) (< (CAR X) (CAR Y))))", "((0 B) (0 D) (1 A) (1 C))"},
+		{"doesn't change list", "(LET ((L '(2 1))) (PROGN (SORT L '<) L))", "(2 1)"},
+		{"empty", "(SORT NIL '<)", "()"},
+		{"end of list", "(SORT (CDR '(1)) '<)", "()"},
+		{"error", "(SORT '(1 A) '<)", "A is not a valid number"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"values", "(REMOVE 1 '(1 2 1 3))", "(2 3)"},
+		{"list", "(REMOVE '(A) '((A) B))", "(B)"},
+		{"missing", "(REMOVE 4 '(1 2))", "(1 2)"},
+		{"end of list", "(REMOVE 1 (CDR '(1)))", "()"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"found", "(FIND 'EVENP '(1 2 3 4))", "2"},
+		{"missing", "(FIND 'EVENP '(1 3))", "()"},
+		{"end of list", "(FIND 'EVENP (CDR '(1)))", "()"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestEvery(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"true", "(EVERY 'EVENP '(2 4))", "T"},
+		{"false", "(EVERY 'EVENP '(2 3))", "()"},
+		{"empty", "(EVERY 'EVENP NIL)", "T"},
+		{"end of list", "(EVERY 'EVENP (CDR '(1)))", "T"},
+		{"several lists", "(EVERY '< '(1 2) '(2 3 0))", "T"},
+		{"stops", "(EVERY 'EVENP '(1 A))", "()"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestSome(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"true", "(SOME 'EVENP '(1 2))", "T"},
+		{"value", "(SOME (LAMBDA (X) (COND ((> X 1) (* X 10)))) '(1 2 3))", "20"},
+		{"false", "(SOME 'EVENP '(1 3))", "()"},
+		{"empty", "(SOME 'EVENP NIL)", "()"},
+		{"end of list", "(SOME 'EVENP (CDR '(1)))", "()"},
+		{"several lists", "(SOME '> '(1 5) '(2 3))", "T"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestRange(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"end", "(RANGE 4)", "(0 1 2 3)"},
+		{"start end", "(RANGE 2 5)", "(2 3 4)"},
+		{"step", "(RANGE 0 10 3)", "(0 3 6 9)"},
+		{"down", "(RANGE 5 0 -2)", "(5 3 1)"},
+		{"empty", "(RANGE 0)", "()"},
+		{"wrong way", "(RANGE 5 0)", "()"},
+		{"zero step", "(RANGE 0 5 0)", "RANGE step can't be 0"},
+		{"not integer", "(RANGE 1/2)", "RANGE parameter must be an integer"},
+		{"too many", "(RANGE 1 2 3 4)", "too many parameters for RANGE"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
+}
+
+func TestZip(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"two", "(ZIP '(1 2) '(A B))", "((1 A) (2 B))"},
+		{"shortest", "(ZIP '(1 2 3) '(A) '(X Y))", "((1 A X))"},
+		{"one", "(ZIP '(1 2))", "((1) (2))"},
+		{"empty", "(ZIP NIL '(1))", "()"},
+		{"end of list", "(ZIP '(1) (CDR '(1)))", "()"},
+		{"not list", "(ZIP 1)", "ZIP parameter must be a list"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			internalEvaluator(t, d.input, d.expected)
+		})
+	}
 }
@@ -0,0 +1,188 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["GO-CALL"] = goCall
+	BuiltIn["GO-METHOD"] = goMethod
+	BuiltIn["GO-FIELD"] = goField
+	BuiltIn["GO-SET-FIELD"] = goSetField
+}
+
+// Go values are handed to Lisp code by the host program with Interpreter.Define, or returned
+// from Go functions. The rules for converting between Go and Lisp values are described on Define.
+
+func asGoValue(name string, e types.Expr) (reflect.Value, error) {
+	g, ok := e.(types.GoValue)
+	if !ok || g.Value == nil {
+		return reflect.Value{}, fmt.Errorf("%s parameter must be a Go value", name)
+	}
+	return reflect.ValueOf(g.Value), nil
+}
+
+// goName returns the name of a field or method, which can be a symbol or a string
+func goName(name string, e types.Expr) (string, error) {
+	switch n := e.(type) {
+	case types.Atom:
+		return string(n), nil
+	case types.String:
+		return string(n), nil
+	}
+	return "", fmt.Errorf("%s name must be a symbol or a string", name)
+}
+
+// goCall calls a Go function with the remaining parameters.
+// If the function returns more than one value, they are returned as a list.
+// If the last value returned is a non-nil error, it is returned as the error.
+// (GO-CALL FUNC PARAMS...)
+func goCall(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("GO-CALL", t, env, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	fv, err := asGoValue("GO-CALL", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	if fv.Kind() != reflect.Func {
+		return nil, fmt.Errorf("GO-CALL can't call %s", vals[0])
+	}
+	return callGo("GO-CALL", fv, vals[1:])
+}
+
+// goMethod calls the method NAME on a Go value with the remaining parameters.
+// Methods with pointer receivers can only be called if the host supplied a pointer.
+// (GO-METHOD VALUE NAME PARAMS...)
+func goMethod(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("GO-METHOD", t, env, 2, -1)
+	if err != nil {
+		return nil, err
+	}
+	v, err := asGoValue("GO-METHOD", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	name, err := goName("GO-METHOD", vals[1])
+	if err != nil {
+		return nil, err
+	}
+	m := v.MethodByName(name)
+	if !m.IsValid() {
+		return nil, fmt.Errorf("%s has no method %s", v.Type(), name)
+	}
+	return callGo(name, m, vals[2:])
+}
+
+// goField returns a field of a struct (or a pointer to a struct), the value for a key in a map,
+// or an element of a slice or array. A key that isn't in a map returns NIL.
+// If the field is itself a struct and the host supplied a pointer, a pointer to the field is returned,
+// so that it can be changed with GO-SET-FIELD.
+// (GO-FIELD VALUE NAME)
+func goField(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("GO-FIELD", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	v, err := asGoValue("GO-FIELD", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	f, err := goElem("GO-FIELD", v, vals[1])
+	if err != nil {
+		return nil, err
+	}
+	if !f.IsValid() {
+		return types.EMPTY, nil
+	}
+	if f.Kind() == reflect.Struct && f.CanAddr() {
+		f = f.Addr()
+	}
+	return fromGo(f)
+}
+
+// goSetField changes a field of a pointer to a struct, the value for a key in a map,
+// or an element of a slice. It returns the new value.
+// (GO-SET-FIELD VALUE NAME NEW-VALUE)
+func goSetField(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("GO-SET-FIELD", t, env, 3, 3)
+	if err != nil {
+		return nil, err
+	}
+	v, err := asGoValue("GO-SET-FIELD", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	if v.Kind() == reflect.Map {
+		if v.IsNil() {
+			return nil, errors.New("GO-SET-FIELD can't set a key in a nil map")
+		}
+		k, err := toGo(vals[1], v.Type().Key())
+		if err != nil {
+			return nil, fmt.Errorf("GO-SET-FIELD key: %w", err)
+		}
+		nv, err := toGo(vals[2], v.Type().Elem())
+		if err != nil {
+			return nil, fmt.Errorf("GO-SET-FIELD value: %w", err)
+		}
+		v.SetMapIndex(k, nv)
+		return vals[2], nil
+	}
+	f, err := goElem("GO-SET-FIELD", v, vals[1])
+	if err != nil {
+		return nil, err
+	}
+	if !f.CanSet() {
+		return nil, fmt.Errorf("GO-SET-FIELD can't change %s of %s; use a pointer", vals[1], v.Type())
+	}
+	nv, err := toGo(vals[2], f.Type())
+	if err != nil {
+		return nil, fmt.Errorf("GO-SET-FIELD value: %w", err)
+	}
+	f.Set(nv)
+	return vals[2], nil
+}
+
+// goElem finds the struct field, map value, or slice or array element of v named by key.
+// The returned value is invalid if key isn't in a map.
+func goElem(name string, v reflect.Value, key types.Expr) (reflect.Value, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, fmt.Errorf("%s can't use a nil %s", name, v.Type())
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		fName, err := goName(name, key)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		sf, ok := v.Type().FieldByName(fName)
+		if !ok || sf.PkgPath != "" {
+			return reflect.Value{}, fmt.Errorf("%s has no exported field %s", v.Type(), fName)
+		}
+		return v.FieldByIndex(sf.Index), nil
+	case reflect.Map:
+		k, err := toGo(key, v.Type().Key())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s key: %w", name, err)
+		}
+		return v.MapIndex(k), nil
+	case reflect.Slice, reflect.Array:
+		i, err := asInt(name, key)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if i < 0 || i >= v.Len() {
+			return reflect.Value{}, fmt.Errorf("%s index %d is out of bounds for a length of %d", name, i, v.Len())
+		}
+		return v.Index(i), nil
+	}
+	return reflect.Value{}, fmt.Errorf("%s can't get a field from %s", name, v.Type())
+}
@@ -0,0 +1,137 @@
+package evaluator
+
+import (
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jonbodner/my_lisp/parser"
+	"github.com/jonbodner/my_lisp/reader"
+	"github.com/jonbodner/my_lisp/scanner"
+	"github.com/jonbodner/my_lisp/types"
+)
+
+// Interpreter holds everything needed to evaluate expressions: the top-level environment,
+// the built-in functions and special forms, and where output and debugging messages go.
+// Interpreters don't share any state, so a program can run as many as it likes,
+// and each one can be used from multiple goroutines.
+type Interpreter struct {
+	topLevel    *types.GlobalEnv
+	builtIn     map[types.Atom]Evaluator
+	tailBuiltIn map[types.Atom]tailEvaluator
+	logger      *log.Logger
+	debug       atomic.Bool
+	out         io.Writer
+}
+
+// Option configures an Interpreter when it is created by New
+type Option func(*Interpreter)
+
+// WithLogger sets where debugging messages and errors from GO are written. The default is the standard logger.
+func WithLogger(l *log.Logger) Option {
+	return func(in *Interpreter) {
+		in.logger = l
+	}
+}
+
+// WithDebug turns debugging messages on or off. They are off by default.
+// They can also be changed from Lisp code with (**DEBUG** T) and (**DEBUG** NIL).
+func WithDebug(debug bool) Option {
+	return func(in *Interpreter) {
+		in.debug.Store(debug)
+	}
+}
+
+// WithOutput sets where LOAD writes the value of each expression it evaluates. The default is os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(in *Interpreter) {
+		in.out = w
+	}
+}
+
+// New creates an Interpreter with its own top-level environment and its own copy of the built-in functions.
+func New(opts ...Option) *Interpreter {
+	in := &Interpreter{
+		topLevel:    types.NewGlobalEnv(),
+		builtIn:     make(map[types.Atom]Evaluator, len(BuiltIn)),
+		tailBuiltIn: make(map[types.Atom]tailEvaluator, len(tailBuiltIn)),
+		logger:      log.Default(),
+		out:         os.Stdout,
+	}
+	for k, v := range BuiltIn {
+		in.builtIn[k] = v
+	}
+	for k, v := range tailBuiltIn {
+		in.tailBuiltIn[k] = v
+	}
+	in.topLevel.Put(types.T, types.T)
+	in.topLevel.Put(types.Atom("NIL"), types.EMPTY)
+	for _, opt := range opts {
+		opt(in)
+	}
+	return in
+}
+
+var (
+	defaultOnce        sync.Once
+	defaultInterpreter *Interpreter
+)
+
+// Default returns the Interpreter that is used by Eval. It has debugging messages turned on.
+func Default() *Interpreter {
+	defaultOnce.Do(func() {
+		defaultInterpreter = New(WithDebug(true))
+	})
+	return defaultInterpreter
+}
+
+// Eval evaluates an expression in the top-level environment
+func (in *Interpreter) Eval(e types.Expr) (types.Expr, error) {
+	return in.evalInner(e, in.topLevel)
+}
+
+// EvalString parses and evaluates every expression in s, in order.
+// It returns the value of the last one, or NIL if there are no expressions.
+func (in *Interpreter) EvalString(s string) (types.Expr, error) {
+	tokens, _ := scanner.Scan(s)
+	exprs, err := parser.ParseAll(tokens)
+	if err != nil {
+		return nil, err
+	}
+	var result types.Expr = types.EMPTY
+	for _, expr := range exprs {
+		result, err = in.Eval(expr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// EvalReader parses and evaluates every expression read from r, in order.
+// It returns the value of the last one, or NIL if there are no expressions.
+func (in *Interpreter) EvalReader(r io.Reader) (types.Expr, error) {
+	rd := reader.New("", r)
+	var result types.Expr = types.EMPTY
+	for {
+		expr, err := rd.Read()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		result, err = in.Eval(expr)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (in *Interpreter) log(vals ...interface{}) {
+	if in.debug.Load() {
+		in.logger.Println(vals...)
+	}
+}
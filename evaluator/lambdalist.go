@@ -0,0 +1,240 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+// The parts of a parameter list, in the order that they have to appear.
+// Each part after the required parameters starts with its marker.
+const (
+	requiredPart = iota
+	optionalPart
+	restPart
+	keyPart
+)
+
+var lambdaListMarkers = map[types.Atom]int{
+	"&OPTIONAL": optionalPart,
+	"&REST":     restPart,
+	"&KEY":      keyPart,
+}
+
+// parseLambdaList reads the parameter list of a LAMBDA or DEFMACRO. kind is used in errors.
+// (REQUIRED... [&OPTIONAL OPT-OR-(OPT DEFAULT)...] [&REST REST] [&KEY KEY-OR-(KEY DEFAULT)...])
+// A dotted parameter list like (A B . REST) is the same as (A B &REST REST).
+func parseLambdaList(kind string, params *types.SExpr) (types.Lambda, error) {
+	var l types.Lambda
+	if isEmpty(params) {
+		return l, nil
+	}
+	part := requiredPart
+	seen := map[types.Atom]bool{}
+	addName := func(name types.Atom) error {
+		if _, ok := lambdaListMarkers[name]; ok {
+			return fmt.Errorf("%s is in the wrong place in the parameter list for %s", name, kind)
+		}
+		if seen[name] {
+			return fmt.Errorf("%s appears more than once in the parameter list for %s", name, kind)
+		}
+		seen[name] = true
+		return nil
+	}
+	var cur types.Expr = params
+	for cur != types.NIL {
+		switch c := cur.(type) {
+		case types.Atom:
+			//dotted rest parameter
+			if part > optionalPart {
+				return l, fmt.Errorf("%s parameter list can't end with a dot after &REST or &KEY", kind)
+			}
+			if err := addName(c); err != nil {
+				return l, err
+			}
+			l.Rest = c
+			return l, nil
+		case *types.SExpr:
+			if marker, ok := c.Left.(types.Atom); ok {
+				if next, ok := lambdaListMarkers[marker]; ok {
+					if next <= part {
+						return l, fmt.Errorf("%s is in the wrong place in the parameter list for %s", marker, kind)
+					}
+					if part == restPart && l.Rest == "" {
+						return l, fmt.Errorf("&REST must be followed by a parameter name in the parameter list for %s", kind)
+					}
+					part = next
+					cur = c.Right
+					continue
+				}
+			}
+			if part == requiredPart {
+				//required parameters can be patterns
+				if err := patternNames(c.Left, addName); err != nil {
+					return l, err
+				}
+				l.Params = append(l.Params, c.Left)
+				cur = c.Right
+				continue
+			}
+			p, err := parseParam(kind, part, c.Left)
+			if err != nil {
+				return l, err
+			}
+			if err := addName(p.Name); err != nil {
+				return l, err
+			}
+			switch part {
+			case optionalPart:
+				l.Optional = append(l.Optional, p)
+			case restPart:
+				if l.Rest != "" {
+					return l, fmt.Errorf("only one parameter can follow &REST in the parameter list for %s", kind)
+				}
+				l.Rest = p.Name
+			case keyPart:
+				l.Keys = append(l.Keys, p)
+			}
+			cur = c.Right
+		default:
+			return l, errors.New("only Atoms can be parameter names")
+		}
+	}
+	if part == restPart && l.Rest == "" {
+		return l, fmt.Errorf("&REST must be followed by a parameter name in the parameter list for %s", kind)
+	}
+	return l, nil
+}
+
+// parseParam reads a single parameter. &OPTIONAL and &KEY parameters can be written (NAME DEFAULT).
+func parseParam(kind string, part int, e types.Expr) (types.Param, error) {
+	switch p := e.(type) {
+	case types.Atom:
+		return types.Param{Name: p}, nil
+	case *types.SExpr:
+		if part != optionalPart && part != keyPart {
+			break
+		}
+		name, ok := p.Left.(types.Atom)
+		if !ok {
+			break
+		}
+		switch d := p.Right.(type) {
+		case types.Nil:
+			return types.Param{Name: name}, nil
+		case *types.SExpr:
+			if d.Right != types.NIL {
+				return types.Param{}, fmt.Errorf("parameter %s in the parameter list for %s can only have one default value", name, kind)
+			}
+			return types.Param{Name: name, Default: d.Left}, nil
+		}
+	}
+	return types.Param{}, errors.New("only Atoms can be parameter names")
+}
+
+// arity describes how many parameters l takes, for error messages
+func arity(l types.Lambda) string {
+	switch {
+	case l.Rest != "" || len(l.Keys) > 0:
+		return "at least " + strconv.Itoa(len(l.Params))
+	case len(l.Optional) > 0:
+		return fmt.Sprintf("%d to %d", len(l.Params), len(l.Params)+len(l.Optional))
+	}
+	return strconv.Itoa(len(l.Params))
+}
+
+// maxParams returns the most parameters that l takes, or -1 if there's no limit
+func maxParams(l types.Lambda) int {
+	if l.Rest != "" || len(l.Keys) > 0 {
+		return -1
+	}
+	return len(l.Params) + len(l.Optional)
+}
+
+// bindParams builds the environment that the body of a LAMBDA or MACRO runs in, with each parameter name
+// assigned its value. name is the name of the function or macro, for errors. Default values are evaluated in
+// the new environment, so they can use the parameters before them.
+func (in *Interpreter) bindParams(name string, l types.Lambda, vals []types.Expr) (*types.LocalEnv, error) {
+	if len(vals) < len(l.Params) {
+		return nil, fmt.Errorf("too few parameters for %s. Expected %s, got %d", name, arity(l), len(vals))
+	}
+	if max := maxParams(l); max >= 0 && len(vals) > max {
+		return nil, fmt.Errorf("too many parameters for %s. Expected %s, got %d", name, arity(l), len(vals))
+	}
+	le := types.NewLocalEnv(l.ParentEnv)
+	for k, p := range l.Params {
+		if err := destructure(p, vals[k], le); err != nil {
+			return nil, err
+		}
+	}
+	rest := vals[len(l.Params):]
+	for _, p := range l.Optional {
+		if len(rest) > 0 {
+			le.Define(p.Name, rest[0])
+			rest = rest[1:]
+			continue
+		}
+		if err := in.bindDefault(p, le); err != nil {
+			return nil, err
+		}
+	}
+	if l.Rest != "" {
+		le.Define(l.Rest, sliceToList(rest))
+	}
+	if len(l.Keys) == 0 {
+		return le, nil
+	}
+	if len(rest)%2 != 0 {
+		return nil, fmt.Errorf("keyword parameters for %s must come in pairs", name)
+	}
+	keyVals := map[types.Atom]types.Expr{}
+	for i := 0; i < len(rest); i += 2 {
+		k, ok := rest[i].(types.Atom)
+		if !ok || !k.IsKeyword() {
+			return nil, fmt.Errorf("%s is not a keyword in the parameters for %s", rest[i], name)
+		}
+		if !hasKey(l.Keys, k) {
+			return nil, fmt.Errorf("%s has no keyword parameter %s", name, k)
+		}
+		//the first value for a keyword wins
+		if _, ok := keyVals[k]; !ok {
+			keyVals[k] = rest[i+1]
+		}
+	}
+	for _, p := range l.Keys {
+		if v, ok := keyVals[":"+p.Name]; ok {
+			le.Define(p.Name, v)
+			continue
+		}
+		if err := in.bindDefault(p, le); err != nil {
+			return nil, err
+		}
+	}
+	return le, nil
+}
+
+// hasKey reports whether there is a &KEY parameter for the keyword k
+func hasKey(keys []types.Param, k types.Atom) bool {
+	for _, p := range keys {
+		if ":"+p.Name == k {
+			return true
+		}
+	}
+	return false
+}
+
+// bindDefault evaluates the default value of p in le, and assigns it to p
+func (in *Interpreter) bindDefault(p types.Param, le *types.LocalEnv) error {
+	var v types.Expr = types.EMPTY
+	if p.Default != nil {
+		var err error
+		v, err = in.evalInner(p.Default, le)
+		if err != nil {
+			return err
+		}
+	}
+	le.Define(p.Name, v)
+	return nil
+}
@@ -0,0 +1,533 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["LIST"] = list
+	BuiltIn["LENGTH"] = length
+	BuiltIn["APPEND"] = appendLists
+	BuiltIn["REVERSE"] = reverse
+	BuiltIn["NTH"] = nthBuiltIn
+	BuiltIn["NTHCDR"] = nthcdr
+	BuiltIn["LAST"] = last
+	BuiltIn["MEMBER"] = member
+	BuiltIn["ASSQ"] = assq
+	BuiltIn["MAPCAR"] = mapcar
+	BuiltIn["FILTER"] = filter
+	BuiltIn["REDUCE"] = reduce
+	BuiltIn["FOLD-LEFT"] = foldLeft
+	BuiltIn["FOLD-RIGHT"] = foldRight
+	BuiltIn["SORT"] = sortList
+	BuiltIn["REMOVE"] = remove
+	BuiltIn["FIND"] = find
+	BuiltIn["EVERY"] = every
+	BuiltIn["SOME"] = some
+	BuiltIn["RANGE"] = rangeList
+	BuiltIn["ZIP"] = zip
+}
+
+// isList reports whether e is a list. NIL, which CDR returns at the end of a list, is the empty list.
+func isList(e types.Expr) bool {
+	if isEmpty(e) {
+		return true
+	}
+	_, ok := e.(*types.SExpr)
+	return ok
+}
+
+// asList returns the elements of the list e
+func asList(name string, e types.Expr) ([]types.Expr, error) {
+	if !isList(e) {
+		return nil, fmt.Errorf("%s parameter must be a list", name)
+	}
+	return listToExprs(e)
+}
+
+// listParams evaluates the parameters for the named builtin, and returns the ones from start on as lists
+func (in *Interpreter) listParams(name string, t *types.SExpr, env types.Env, minCount, maxCount, start int) ([]types.Expr, [][]types.Expr, error) {
+	vals, err := in.checkedParams(name, t, env, minCount, maxCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	lists := make([][]types.Expr, 0, len(vals)-start)
+	for _, v := range vals[start:] {
+		l, err := asList(name, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		lists = append(lists, l)
+	}
+	return vals, lists, nil
+}
+
+// listTail returns the rest of a list that starts at e, which is the empty list if there's nothing left
+func listTail(e types.Expr) types.Expr {
+	if e == types.NIL {
+		return types.EMPTY
+	}
+	return e
+}
+
+// (LIST X...)
+func list(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("LIST", t, env, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	return sliceToList(vals), nil
+}
+
+// (LENGTH LIST)
+func length(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	_, lists, err := in.listParams("LENGTH", t, env, 1, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewInteger(int64(len(lists[0]))), nil
+}
+
+// appendLists returns a list with the elements of each LIST in order. The last LIST isn't copied; the new list
+// ends with it. Like in Common Lisp, the last parameter doesn't have to be a list, in which case the new list is dotted.
+// (APPEND LIST...)
+func appendLists(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("APPEND", t, env, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 0 {
+		return types.EMPTY, nil
+	}
+	out := vals[len(vals)-1]
+	if isEmpty(out) {
+		out = types.NIL
+	}
+	for i := len(vals) - 2; i >= 0; i-- {
+		l, err := asList("APPEND", vals[i])
+		if err != nil {
+			return nil, err
+		}
+		for j := len(l) - 1; j >= 0; j-- {
+			out = &types.SExpr{Left: l[j], Right: out}
+		}
+	}
+	return listTail(out), nil
+}
+
+// (REVERSE LIST)
+func reverse(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	_, lists, err := in.listParams("REVERSE", t, env, 1, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out types.Expr = types.NIL
+	for _, e := range lists[0] {
+		out = &types.SExpr{Left: e, Right: out}
+	}
+	return listTail(out), nil
+}
+
+// listIndex returns the index and the list for NTH and NTHCDR
+func (in *Interpreter) listIndex(name string, t *types.SExpr, env types.Env) (int, types.Expr, error) {
+	vals, err := in.checkedParams(name, t, env, 2, 2)
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := asInt(name, vals[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	if n < 0 {
+		return 0, nil, fmt.Errorf("%s index %d can't be negative", name, n)
+	}
+	if !isList(vals[1]) {
+		return 0, nil, fmt.Errorf("%s parameter must be a list", name)
+	}
+	return n, vals[1], nil
+}
+
+// nthcdrOf returns the list that's left after taking n elements off the front of l, or NIL if l is shorter than that
+func nthcdrOf(n int, l types.Expr) (types.Expr, error) {
+	for i := 0; i < n && !isEmpty(l); i++ {
+		c, ok := l.(*types.SExpr)
+		if !ok {
+			return nil, errors.New("can't have a dotted pair here")
+		}
+		l = c.Right
+	}
+	return listTail(l), nil
+}
+
+// nthBuiltIn returns the element of LIST at N, which starts at 0, or NIL if LIST is too short
+// (NTH N LIST)
+func nthBuiltIn(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	n, l, err := in.listIndex("NTH", t, env)
+	if err != nil {
+		return nil, err
+	}
+	rest, err := nthcdrOf(n, l)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := rest.(*types.SExpr)
+	if !ok {
+		return nil, errors.New("can't have a dotted pair here")
+	}
+	if isEmpty(c) {
+		return types.EMPTY, nil
+	}
+	return c.Left, nil
+}
+
+// nthcdr returns LIST without its first N elements
+// (NTHCDR N LIST)
+func nthcdr(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	n, l, err := in.listIndex("NTHCDR", t, env)
+	if err != nil {
+		return nil, err
+	}
+	return nthcdrOf(n, l)
+}
+
+// last returns the last cell of LIST, like in Common Lisp, so (LAST '(1 2 3)) is (3)
+// (LAST LIST)
+func last(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("LAST", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if isEmpty(vals[0]) {
+		return types.EMPTY, nil
+	}
+	c, ok := vals[0].(*types.SExpr)
+	if !ok {
+		return nil, errors.New("LAST parameter must be a list")
+	}
+	for {
+		next, ok := c.Right.(*types.SExpr)
+		if !ok || isEmpty(next) {
+			return c, nil
+		}
+		c = next
+	}
+}
+
+// member returns the rest of LIST, starting at the first element that is EQ to X, or NIL if there isn't one
+// (MEMBER X LIST)
+func member(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MEMBER", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	if !isList(vals[1]) {
+		return nil, errors.New("MEMBER parameter must be a list")
+	}
+	for l := vals[1]; !isEmpty(l); {
+		c, ok := l.(*types.SExpr)
+		if !ok {
+			return nil, errors.New("can't have a dotted pair here")
+		}
+		if isEqual(vals[0], c.Left) {
+			return c, nil
+		}
+		l = c.Right
+	}
+	return types.EMPTY, nil
+}
+
+// assq returns the first pair in the association list ALIST whose CAR is EQ to KEY, or NIL if there isn't one.
+// It's called ASSQ instead of ASSOC, like in Common Lisp, because ASSOC updates maps and vectors.
+// (ASSQ KEY ALIST)
+func assq(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, lists, err := in.listParams("ASSQ", t, env, 2, 2, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range lists[0] {
+		c, ok := p.(*types.SExpr)
+		if !ok || isEmpty(c) {
+			return nil, errors.New("ASSQ list elements must be pairs")
+		}
+		if isEqual(vals[0], c.Left) {
+			return c, nil
+		}
+	}
+	return types.EMPTY, nil
+}
+
+// mapcar calls FUNC with the first element of each LIST, then the second, and so on, and returns a list of the results.
+// It stops at the end of the shortest LIST.
+// (MAPCAR FUNC LIST...)
+func mapcar(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, lists, err := in.listParams("MAPCAR", t, env, 2, -1, 1)
+	if err != nil {
+		return nil, err
+	}
+	var out []types.Expr
+	for i := 0; i < shortest(lists); i++ {
+		v, err := in.callFunction(vals[0], column(lists, i), env)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return sliceToList(out), nil
+}
+
+// shortest returns the length of the shortest list in lists
+func shortest(lists [][]types.Expr) int {
+	n := len(lists[0])
+	for _, l := range lists[1:] {
+		if len(l) < n {
+			n = len(l)
+		}
+	}
+	return n
+}
+
+// column returns the element at i in each list
+func column(lists [][]types.Expr, i int) []types.Expr {
+	out := make([]types.Expr, len(lists))
+	for j, l := range lists {
+		out[j] = l[i]
+	}
+	return out
+}
+
+// filter returns a list of the elements of LIST that PRED returns true for
+// (FILTER PRED LIST)
+func filter(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, lists, err := in.listParams("FILTER", t, env, 2, 2, 1)
+	if err != nil {
+		return nil, err
+	}
+	var out []types.Expr
+	for _, e := range lists[0] {
+		v, err := in.callFunction(vals[0], []types.Expr{e}, env)
+		if err != nil {
+			return nil, err
+		}
+		if !isEmpty(v) {
+			out = append(out, e)
+		}
+	}
+	return sliceToList(out), nil
+}
+
+// reduce combines the elements of LIST with FUNC, from left to right. If INITIAL is passed in, it's combined with
+// the first element; otherwise the first two elements are combined first. A list with one element and no INITIAL
+// reduces to that element.
+// (REDUCE FUNC LIST [INITIAL])
+func reduce(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("REDUCE", t, env, 2, 3)
+	if err != nil {
+		return nil, err
+	}
+	l, err := asList("REDUCE", vals[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 3 {
+		return in.fold(vals[0], vals[2], l, env)
+	}
+	if len(l) == 0 {
+		return nil, errors.New("REDUCE of an empty list requires an initial value")
+	}
+	return in.fold(vals[0], l[0], l[1:], env)
+}
+
+// fold calls fn with acc and each element of l in turn, replacing acc with the result
+func (in *Interpreter) fold(fn types.Expr, acc types.Expr, l []types.Expr, env types.Env) (types.Expr, error) {
+	for _, e := range l {
+		var err error
+		acc, err = in.callFunction(fn, []types.Expr{acc, e}, env)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// foldLeft returns (FUNC (FUNC (FUNC INITIAL X1) X2) ... XN)
+// (FOLD-LEFT FUNC INITIAL LIST)
+func foldLeft(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, lists, err := in.listParams("FOLD-LEFT", t, env, 3, 3, 2)
+	if err != nil {
+		return nil, err
+	}
+	return in.fold(vals[0], vals[1], lists[0], env)
+}
+
+// foldRight returns (FUNC X1 (FUNC X2 ... (FUNC XN INITIAL)))
+// (FOLD-RIGHT FUNC INITIAL LIST)
+func foldRight(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, lists, err := in.listParams("FOLD-RIGHT", t, env, 3, 3, 2)
+	if err != nil {
+		return nil, err
+	}
+	acc, l := vals[1], lists[0]
+	for i := len(l) - 1; i >= 0; i-- {
+		acc, err = in.callFunction(vals[0], []types.Expr{l[i], acc}, env)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// sortList returns a new list with the elements of LIST in order. LESS is called with two elements, and returns true
+// if the first one goes before the second. Elements that are the same stay in the same order. LIST isn't changed.
+// (SORT LIST LESS)
+func sortList(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("SORT", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	l, err := asList("SORT", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	//the first error from LESS stops the sort from calling it again
+	var sortErr error
+	sort.SliceStable(l, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		v, err := in.callFunction(vals[1], []types.Expr{l[i], l[j]}, env)
+		if err != nil {
+			sortErr = err
+			return false
+		}
+		return !isEmpty(v)
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	return sliceToList(l), nil
+}
+
+// remove returns a list of the elements of LIST that aren't EQ to X
+// (REMOVE X LIST)
+func remove(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, lists, err := in.listParams("REMOVE", t, env, 2, 2, 1)
+	if err != nil {
+		return nil, err
+	}
+	var out []types.Expr
+	for _, e := range lists[0] {
+		if !isEqual(vals[0], e) {
+			out = append(out, e)
+		}
+	}
+	return sliceToList(out), nil
+}
+
+// find returns the first element of LIST that PRED returns true for, or NIL if there isn't one
+// (FIND PRED LIST)
+func find(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, lists, err := in.listParams("FIND", t, env, 2, 2, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range lists[0] {
+		v, err := in.callFunction(vals[0], []types.Expr{e}, env)
+		if err != nil {
+			return nil, err
+		}
+		if !isEmpty(v) {
+			return e, nil
+		}
+	}
+	return types.EMPTY, nil
+}
+
+// every returns T if PRED returns true for the first element of each LIST, and the second, and so on, stopping
+// at the end of the shortest LIST. It returns NIL as soon as PRED returns NIL.
+// (EVERY PRED LIST...)
+func every(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, lists, err := in.listParams("EVERY", t, env, 2, -1, 1)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < shortest(lists); i++ {
+		v, err := in.callFunction(vals[0], column(lists, i), env)
+		if err != nil {
+			return nil, err
+		}
+		if isEmpty(v) {
+			return types.EMPTY, nil
+		}
+	}
+	return types.T, nil
+}
+
+// some returns the first true value that PRED returns for the elements of the LISTs, taken the same way as EVERY,
+// or NIL if PRED never returns true
+// (SOME PRED LIST...)
+func some(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, lists, err := in.listParams("SOME", t, env, 2, -1, 1)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < shortest(lists); i++ {
+		v, err := in.callFunction(vals[0], column(lists, i), env)
+		if err != nil {
+			return nil, err
+		}
+		if !isEmpty(v) {
+			return v, nil
+		}
+	}
+	return types.EMPTY, nil
+}
+
+// rangeList returns a list of the integers from START (or 0 if it's left off) up to, but not including, END,
+// counting by STEP (or 1). If STEP is negative, it counts down to END.
+// (RANGE [START] END [STEP])
+func rangeList(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("RANGE", t, env, 1, 3)
+	if err != nil {
+		return nil, err
+	}
+	nums := make([]int, len(vals))
+	for i, v := range vals {
+		if nums[i], err = asInt("RANGE", v); err != nil {
+			return nil, err
+		}
+	}
+	start, end, step := 0, nums[0], 1
+	if len(nums) > 1 {
+		start, end = nums[0], nums[1]
+	}
+	if len(nums) > 2 {
+		step = nums[2]
+	}
+	if step == 0 {
+		return nil, errors.New("RANGE step can't be 0")
+	}
+	var out []types.Expr
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		out = append(out, types.NewInteger(int64(i)))
+	}
+	return sliceToList(out), nil
+}
+
+// zip returns a list of lists: the first elements of each LIST, then the second elements, and so on,
+// stopping at the end of the shortest LIST
+// (ZIP LIST...)
+func zip(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	_, lists, err := in.listParams("ZIP", t, env, 1, -1, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out []types.Expr
+	for i := 0; i < shortest(lists); i++ {
+		out = append(out, sliceToList(column(lists, i)))
+	}
+	return sliceToList(out), nil
+}
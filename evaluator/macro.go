@@ -0,0 +1,295 @@
+package evaluator
+
+import (
+	"errors"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["DEFMACRO"] = defmacro
+	BuiltIn["QUASIQUOTE"] = quasiquote
+	BuiltIn["UNQUOTE"] = unquote
+	BuiltIn["UNQUOTE-SPLICING"] = unquote
+	BuiltIn["MACROEXPAND-1"] = macroexpand1
+	BuiltIn["MACROEXPAND"] = macroexpand
+}
+
+// defmacro defines a new macro.
+// (DEFMACRO NAME (PARAMS) BODY)
+// When (NAME ARGS) is evaluated, BODY is evaluated with PARAMS bound to the unevaluated ARGS,
+// and the result is evaluated in place of the original call.
+func defmacro(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	n, err := nth(1, t)
+	if err != nil {
+		return nil, err
+	}
+	if n == types.NIL {
+		return nil, errors.New("missing parameters for DEFMACRO")
+	}
+	name, ok := n.(types.Atom)
+	if !ok {
+		return nil, errors.New("DEFMACRO name must be an Atom")
+	}
+	params, err := nth(2, t)
+	if err != nil {
+		return nil, err
+	}
+	if params == types.NIL {
+		return nil, errors.New("missing parameter list for DEFMACRO")
+	}
+	l, ok := params.(*types.SExpr)
+	if !ok {
+		return nil, errors.New("DEFMACRO parameter list must be a List")
+	}
+	m, err := parseLambdaList("DEFMACRO", l)
+	if err != nil {
+		return nil, err
+	}
+	body, err := nth(3, t)
+	if err != nil {
+		return nil, err
+	}
+	if body == types.NIL {
+		return nil, errors.New("must have three parameters for DEFMACRO")
+	}
+	m.ParentEnv, m.Body = env, body
+	env.Put(name, types.Macro(m))
+	return name, nil
+}
+
+// expandMacro runs the body of the macro with the unevaluated parameters of the call
+// and returns the expansion. name is what the macro was called, for errors.
+func (in *Interpreter) expandMacro(name types.Atom, m types.Macro, t *types.SExpr) (types.Expr, error) {
+	var vals []types.Expr
+	switch paramVals := t.Right.(type) {
+	case types.Nil:
+		//do nothing
+	case *types.SExpr:
+		for count := 0; ; count++ {
+			param, err := nth(count, paramVals)
+			if err != nil {
+				return nil, err
+			}
+			if param == types.NIL {
+				break
+			}
+			vals = append(vals, param)
+		}
+	default:
+		return nil, errors.New("can't have a dotted pair here")
+	}
+	le, err := in.bindParams(string(name), types.Lambda(m), vals)
+	if err != nil {
+		return nil, err
+	}
+	return in.evalInner(m.Body, le)
+}
+
+// macroFor returns the macro that would be invoked if e were evaluated, if there is one
+func (in *Interpreter) macroFor(e types.Expr, env types.Env) (types.Macro, *types.SExpr, bool) {
+	t, ok := e.(*types.SExpr)
+	if !ok {
+		return types.Macro{}, nil, false
+	}
+	switch a := t.Left.(type) {
+	case types.Macro:
+		return a, t, true
+	case types.Atom:
+		if _, ok := in.builtIn[a]; ok {
+			return types.Macro{}, nil, false
+		}
+		v, ok := env.Get(a)
+		if !ok {
+			return types.Macro{}, nil, false
+		}
+		m, ok := v.(types.Macro)
+		return m, t, ok
+	}
+	return types.Macro{}, nil, false
+}
+
+// macroName returns the name that a macro was called by
+func macroName(call *types.SExpr) types.Atom {
+	if a, ok := call.Left.(types.Atom); ok {
+		return a
+	}
+	return "MACRO"
+}
+
+// macroexpand1 expands the macro call its parameter evaluates to one time.
+// If the parameter isn't a macro call, it is returned unchanged.
+func macroexpand1(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	form, err := in.macroexpandParam("MACROEXPAND-1", t, env)
+	if err != nil {
+		return nil, err
+	}
+	if m, call, ok := in.macroFor(form, env); ok {
+		return in.expandMacro(macroName(call), m, call)
+	}
+	return form, nil
+}
+
+// macroexpand expands the macro call its parameter evaluates to until the result
+// is no longer a macro call.
+func macroexpand(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	form, err := in.macroexpandParam("MACROEXPAND", t, env)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		m, call, ok := in.macroFor(form, env)
+		if !ok {
+			return form, nil
+		}
+		form, err = in.expandMacro(macroName(call), m, call)
+		if err != nil {
+			return nil, err
+		}
+		in.log("expanded to ", form)
+	}
+}
+
+func (in *Interpreter) macroexpandParam(name string, t *types.SExpr, env types.Env) (types.Expr, error) {
+	if t.Right == types.NIL {
+		return nil, errors.New("missing parameter for " + name)
+	}
+	a2, ok := t.Right.(*types.SExpr)
+	if !ok {
+		return nil, errors.New(name + " parameter must be a list")
+	}
+	//should only have a single parameter
+	if a2.Right != types.NIL {
+		return nil, errors.New("shouldn't have more than one parameter for " + name)
+	}
+	return in.evalArg(a2, env)
+}
+
+// quasiquote works like quote, except that forms inside of UNQUOTE are evaluated,
+// and forms inside of UNQUOTE-SPLICING are evaluated and spliced into the surrounding list.
+// `(A ,B ,@C) is read as (QUASIQUOTE (A (UNQUOTE B) (UNQUOTE-SPLICING C)))
+func quasiquote(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	if t.Right == types.NIL {
+		return nil, errors.New("missing parameter for QUASIQUOTE")
+	}
+	a2, ok := t.Right.(*types.SExpr)
+	if !ok {
+		return nil, errors.New("shouldn't have an types.Atom after a QUASIQUOTE")
+	}
+	//should only have a single parameter for QUASIQUOTE
+	if a2.Right != types.NIL {
+		return nil, errors.New("shouldn't have more than one parameter for QUASIQUOTE")
+	}
+	return in.quasi(a2.Left, env, 1)
+}
+
+func unquote(in *Interpreter, t *types.SExpr, _ types.Env) (types.Expr, error) {
+	return nil, errors.New(t.Left.String() + " is only valid inside of QUASIQUOTE")
+}
+
+// quasi builds the value of a quasiquoted template.
+// level tracks how many QUASIQUOTEs deep we are; only unquotes at level 1 are evaluated.
+func (in *Interpreter) quasi(e types.Expr, env types.Env, level int) (types.Expr, error) {
+	s, ok := e.(*types.SExpr)
+	if !ok || isEmpty(s) {
+		return e, nil
+	}
+	if name, param, ok := quasiForm(s); ok {
+		switch name {
+		case "UNQUOTE":
+			if level == 1 {
+				return in.evalInner(param, env)
+			}
+			return in.quasiWrap(name, param, env, level-1)
+		case "UNQUOTE-SPLICING":
+			if level == 1 {
+				return nil, errors.New("UNQUOTE-SPLICING must be inside of a list")
+			}
+			return in.quasiWrap(name, param, env, level-1)
+		case "QUASIQUOTE":
+			return in.quasiWrap(name, param, env, level+1)
+		}
+	}
+
+	var out types.Expr = types.EMPTY
+	var last *types.SExpr
+	add := func(v types.Expr) {
+		cell := &types.SExpr{Left: v, Right: types.NIL}
+		if last == nil {
+			out = cell
+		} else {
+			last.Right = cell
+		}
+		last = cell
+	}
+	var cur types.Expr = s
+	for {
+		c, ok := cur.(*types.SExpr)
+		if !ok {
+			break
+		}
+		//a tail of (UNQUOTE X) comes from `(A . ,X)
+		if _, _, ok := quasiForm(c); ok && c != s {
+			break
+		}
+		if inner, ok := c.Left.(*types.SExpr); ok && level == 1 {
+			if name, param, ok := quasiForm(inner); ok && name == "UNQUOTE-SPLICING" {
+				spliced, err := in.evalInner(param, env)
+				if err != nil {
+					return nil, err
+				}
+				for sc := spliced; !isEmpty(sc); {
+					cell, ok := sc.(*types.SExpr)
+					if !ok {
+						return nil, errors.New("UNQUOTE-SPLICING value must be a list")
+					}
+					add(cell.Left)
+					sc = cell.Right
+				}
+				cur = c.Right
+				continue
+			}
+		}
+		v, err := in.quasi(c.Left, env, level)
+		if err != nil {
+			return nil, err
+		}
+		add(v)
+		cur = c.Right
+	}
+	if cur != types.NIL {
+		tail, err := in.quasi(cur, env, level)
+		if err != nil {
+			return nil, err
+		}
+		if last == nil {
+			return tail, nil
+		}
+		if tail == types.EMPTY {
+			tail = types.NIL
+		}
+		last.Right = tail
+	}
+	return out, nil
+}
+
+// quasiForm checks if s is a two element list starting with QUASIQUOTE, UNQUOTE, or UNQUOTE-SPLICING
+func quasiForm(s *types.SExpr) (types.Atom, types.Expr, bool) {
+	name, ok := s.Left.(types.Atom)
+	if !ok || (name != "QUASIQUOTE" && name != "UNQUOTE" && name != "UNQUOTE-SPLICING") {
+		return "", nil, false
+	}
+	rest, ok := s.Right.(*types.SExpr)
+	if !ok || rest.Right != types.NIL {
+		return "", nil, false
+	}
+	return name, rest.Left, true
+}
+
+func (in *Interpreter) quasiWrap(name types.Atom, param types.Expr, env types.Env, level int) (types.Expr, error) {
+	inner, err := in.quasi(param, env, level)
+	if err != nil {
+		return nil, err
+	}
+	return &types.SExpr{Left: name, Right: &types.SExpr{Left: inner, Right: types.NIL}}, nil
+}
@@ -0,0 +1,172 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["MAKE-MAP"] = makeMap
+	BuiltIn["MAP-GET"] = mapGet
+	BuiltIn["MAP-PUT"] = mapPut
+	BuiltIn["MAP-DELETE"] = mapDelete
+	BuiltIn["MAP-HAS"] = mapHas
+	BuiltIn["MAP-KEYS"] = mapKeys
+	BuiltIn["MAP-VALUES"] = mapValues
+	BuiltIn["MAP-FOR-EACH"] = mapForEach
+}
+
+func asMap(name string, e types.Expr) (*types.Map, error) {
+	m, ok := e.(*types.Map)
+	if !ok {
+		return nil, fmt.Errorf("%s parameter must be a map", name)
+	}
+	return m, nil
+}
+
+// makeMap returns a new map with the keys and values that are passed in.
+// (MAKE-MAP [KEY VALUE]...)
+func makeMap(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MAKE-MAP", t, env, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals)%2 != 0 {
+		return nil, errors.New("MAKE-MAP requires a value for every key")
+	}
+	m := types.NewMap().Transient()
+	for i := 0; i < len(vals); i += 2 {
+		if err := m.Put(vals[i], vals[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return m.Persistent()
+}
+
+// mapGet returns the value for KEY, or DEFAULT (which is NIL if it's left off) if KEY isn't in the map.
+// (MAP-GET MAP KEY [DEFAULT])
+func mapGet(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MAP-GET", t, env, 2, 3)
+	if err != nil {
+		return nil, err
+	}
+	m, err := asMap("MAP-GET", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := m.Get(vals[1]); ok {
+		return v, nil
+	}
+	if len(vals) == 3 {
+		return vals[2], nil
+	}
+	return types.EMPTY, nil
+}
+
+// mapPut changes the map so that KEY has VALUE, and returns the map
+// (MAP-PUT MAP KEY VALUE)
+func mapPut(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MAP-PUT", t, env, 3, 3)
+	if err != nil {
+		return nil, err
+	}
+	m, err := asMap("MAP-PUT", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Put(vals[1], vals[2]); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// mapDelete removes KEY from the map, and returns the map. It's not an error if KEY isn't there.
+// (MAP-DELETE MAP KEY)
+func mapDelete(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MAP-DELETE", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	m, err := asMap("MAP-DELETE", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	m.Delete(vals[1])
+	return m, nil
+}
+
+// (MAP-HAS MAP KEY)
+func mapHas(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MAP-HAS", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	m, err := asMap("MAP-HAS", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	_, ok := m.Get(vals[1])
+	return boolToExpr(ok), nil
+}
+
+// mapKeys returns a list of the keys in the map, in the order that the map prints them
+// (MAP-KEYS MAP)
+func mapKeys(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	entries, err := in.mapEntries("MAP-KEYS", t, env)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]types.Expr, len(entries))
+	for i, e := range entries {
+		out[i] = e.Key
+	}
+	return sliceToList(out), nil
+}
+
+// mapValues returns a list of the values in the map, in the same order as MAP-KEYS returns the keys
+// (MAP-VALUES MAP)
+func mapValues(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	entries, err := in.mapEntries("MAP-VALUES", t, env)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]types.Expr, len(entries))
+	for i, e := range entries {
+		out[i] = e.Value
+	}
+	return sliceToList(out), nil
+}
+
+func (in *Interpreter) mapEntries(name string, t *types.SExpr, env types.Env) ([]types.MapEntry, error) {
+	vals, err := in.checkedParams(name, t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	m, err := asMap(name, vals[0])
+	if err != nil {
+		return nil, err
+	}
+	return m.Entries(), nil
+}
+
+// mapForEach calls FUNC with each key and its value, in the same order as MAP-KEYS returns the keys.
+// Changes that FUNC makes to the map don't change which keys it is called with.
+// (MAP-FOR-EACH FUNC MAP)
+func mapForEach(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MAP-FOR-EACH", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	m, err := asMap("MAP-FOR-EACH", vals[1])
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range m.Entries() {
+		if _, err := in.callFunction(vals[0], []types.Expr{e.Key, e.Value}, env); err != nil {
+			return nil, err
+		}
+	}
+	return types.EMPTY, nil
+}
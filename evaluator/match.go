@@ -0,0 +1,319 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	registerTail("MATCH", match)
+}
+
+// match evaluates EXPR and tries each clause in order. The first clause whose PATTERN matches the value,
+// and whose GUARD (if there is one) is true, is chosen. Its BODY is evaluated in a new environment with
+// the symbols in the pattern bound to the parts of the value that they matched. The last expression of
+// the body is in tail position. If no clause matches, MATCH signals an error.
+// (MATCH EXPR (PATTERN [:WHEN GUARD] BODY...)...)
+//
+// Patterns are:
+//   - _ matches anything
+//   - a symbol matches anything, and is bound to the value. If a symbol appears more than once in a pattern,
+//     each value it matches must be EQ
+//   - numbers, strings, maps, vectors, sets, structs, keywords and T match themselves, and NIL and () match the empty list
+//   - 'DATUM matches a value that is EQ to DATUM
+//   - (P1 P2 ...) matches a list with the same number of elements, where each element matches the pattern in the same place
+//   - (P1 ... . REST) and (P1 ... &REST REST) match the rest of the list with REST
+//   - (? PRED P...) matches a value when (PRED VALUE) is true and all of the Ps match it
+//   - (OR P...) matches a value when any of the Ps match it. The bindings come from the first one that does
+//
+// To match a list that starts with the symbol QUOTE, ? or OR, quote the symbol: ('OR A B)
+func match(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, types.Env, error) {
+	a1, ok := t.Right.(*types.SExpr)
+	if !ok {
+		return nil, nil, errors.New("missing expression for MATCH")
+	}
+	value, err := in.evalArg(a1, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	for cur := a1.Right; cur != types.NIL; {
+		c, ok := cur.(*types.SExpr)
+		if !ok {
+			return nil, nil, errors.New("MATCH clauses must be a list")
+		}
+		clause, ok := c.Left.(*types.SExpr)
+		if !ok || isEmpty(clause) {
+			return nil, nil, errors.New("MATCH clause must be a list that starts with a pattern")
+		}
+		p, err := compilePattern(clause.Left)
+		if err != nil {
+			return nil, nil, err
+		}
+		var b bindings
+		ok, err = p.match(in, value, env, &b)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			next, nextEnv, matched, err := in.matchBody(clause, b, env)
+			if err != nil || matched {
+				return next, nextEnv, err
+			}
+		}
+		cur = c.Right
+	}
+	return nil, nil, fmt.Errorf("no MATCH clause matched %s", value)
+}
+
+// matchBody checks the guard of a clause whose pattern matched, and returns its body to evaluate.
+// It returns false if the guard is false.
+func (in *Interpreter) matchBody(clause *types.SExpr, b bindings, env types.Env) (types.Expr, types.Env, bool, error) {
+	innerEnv := types.NewLocalEnv(env)
+	for _, v := range b {
+		innerEnv.Define(v.name, v.value)
+	}
+	body := clause.Right
+	if c, ok := body.(*types.SExpr); ok && c.Left == types.Atom(":WHEN") {
+		g, ok := c.Right.(*types.SExpr)
+		if !ok {
+			return nil, nil, false, errors.New("missing guard after :WHEN in MATCH clause")
+		}
+		guard, err := in.evalArg(g, innerEnv)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if isEmpty(guard) {
+			return nil, nil, false, nil
+		}
+		body = g.Right
+	}
+	if body == types.NIL {
+		return types.EMPTY, nil, true, nil
+	}
+	return bodyExpr(body), innerEnv, true, nil
+}
+
+// binding is a symbol in a pattern and the value it matched
+type binding struct {
+	name  types.Atom
+	value types.Expr
+}
+
+// bindings are collected in order while a pattern is matched. When part of an OR pattern fails,
+// the bindings it made are thrown away by going back to the length from before it was tried.
+type bindings []binding
+
+func (b bindings) get(name types.Atom) (types.Expr, bool) {
+	for _, v := range b {
+		if v.name == name {
+			return v.value, true
+		}
+	}
+	return nil, false
+}
+
+// pattern is a compiled MATCH pattern. match reports whether value matches, adding to b the symbols that it binds.
+// env is the environment of the MATCH, which is used to evaluate predicates.
+type pattern interface {
+	match(in *Interpreter, value types.Expr, env types.Env, b *bindings) (bool, error)
+}
+
+type wildcardPattern struct{}
+
+func (wildcardPattern) match(*Interpreter, types.Expr, types.Env, *bindings) (bool, error) {
+	return true, nil
+}
+
+type binderPattern struct {
+	name types.Atom
+}
+
+func (p binderPattern) match(_ *Interpreter, value types.Expr, _ types.Env, b *bindings) (bool, error) {
+	if prev, ok := b.get(p.name); ok {
+		return isEqual(prev, value), nil
+	}
+	*b = append(*b, binding{name: p.name, value: value})
+	return true, nil
+}
+
+type literalPattern struct {
+	value types.Expr
+}
+
+func (p literalPattern) match(_ *Interpreter, value types.Expr, _ types.Env, _ *bindings) (bool, error) {
+	if isEmpty(p.value) {
+		return isEmpty(value), nil
+	}
+	return isEqual(p.value, value), nil
+}
+
+// listPattern matches the elements of a list, and then the rest of the list if rest isn't nil
+type listPattern struct {
+	elems []pattern
+	rest  pattern
+}
+
+func (p listPattern) match(in *Interpreter, value types.Expr, env types.Env, b *bindings) (bool, error) {
+	cur := value
+	for _, elem := range p.elems {
+		c, ok := cur.(*types.SExpr)
+		if !ok || isEmpty(c) {
+			return false, nil
+		}
+		ok, err := elem.match(in, c.Left, env, b)
+		if err != nil || !ok {
+			return false, err
+		}
+		cur = c.Right
+	}
+	if p.rest != nil {
+		if isEmpty(cur) {
+			cur = types.EMPTY
+		}
+		return p.rest.match(in, cur, env, b)
+	}
+	return isEmpty(cur), nil
+}
+
+// predicatePattern matches when (PRED VALUE) is true and all of the patterns match
+type predicatePattern struct {
+	pred types.Expr
+	pats []pattern
+}
+
+func (p predicatePattern) match(in *Interpreter, value types.Expr, env types.Env, b *bindings) (bool, error) {
+	result, err := in.callFunction(p.pred, []types.Expr{value}, env)
+	if err != nil || isEmpty(result) {
+		return false, err
+	}
+	for _, pat := range p.pats {
+		ok, err := pat.match(in, value, env, b)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+type orPattern struct {
+	alts []pattern
+}
+
+func (p orPattern) match(in *Interpreter, value types.Expr, env types.Env, b *bindings) (bool, error) {
+	start := len(*b)
+	for _, alt := range p.alts {
+		ok, err := alt.match(in, value, env, b)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+		*b = (*b)[:start]
+	}
+	return false, nil
+}
+
+// compilePattern turns the expression for a pattern into a pattern
+func compilePattern(e types.Expr) (pattern, error) {
+	switch p := e.(type) {
+	case types.Atom:
+		switch {
+		case p == "_":
+			return wildcardPattern{}, nil
+		case p == "NIL":
+			return literalPattern{value: types.EMPTY}, nil
+		case p == types.T || p.IsKeyword():
+			return literalPattern{value: p}, nil
+		}
+		return binderPattern{name: p}, nil
+	case types.Number, types.String, *types.Map, *types.Vector, *types.Set, *types.Struct:
+		return literalPattern{value: p}, nil
+	case *types.SExpr:
+		if isEmpty(p) {
+			return literalPattern{value: types.EMPTY}, nil
+		}
+		switch p.Left {
+		case types.Atom("QUOTE"):
+			datum, err := nth(1, p)
+			if err != nil {
+				return nil, err
+			}
+			return literalPattern{value: datum}, nil
+		case types.Atom("?"):
+			rest, ok := p.Right.(*types.SExpr)
+			if !ok {
+				return nil, errors.New("missing predicate in ? pattern")
+			}
+			pats, err := compilePatterns(rest.Right)
+			if err != nil {
+				return nil, err
+			}
+			return predicatePattern{pred: rest.Left, pats: pats}, nil
+		case types.Atom("OR"):
+			alts, err := compilePatterns(p.Right)
+			if err != nil {
+				return nil, err
+			}
+			return orPattern{alts: alts}, nil
+		}
+		return compileListPattern(p)
+	}
+	return nil, fmt.Errorf("%s can't be used in a pattern", e)
+}
+
+// compilePatterns compiles each of the patterns in the list l
+func compilePatterns(l types.Expr) ([]pattern, error) {
+	exprs, err := listToExprs(l)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]pattern, len(exprs))
+	for i, e := range exprs {
+		out[i], err = compilePattern(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func compileListPattern(l *types.SExpr) (pattern, error) {
+	var out listPattern
+	var cur types.Expr = l
+	for {
+		switch c := cur.(type) {
+		case types.Nil:
+			return out, nil
+		case *types.SExpr:
+			if c.Left == types.Atom("&REST") {
+				r, ok := c.Right.(*types.SExpr)
+				if !ok || r.Right != types.NIL {
+					return nil, errors.New("&REST must be followed by a single pattern")
+				}
+				rest, err := compilePattern(r.Left)
+				if err != nil {
+					return nil, err
+				}
+				out.rest = rest
+				return out, nil
+			}
+			elem, err := compilePattern(c.Left)
+			if err != nil {
+				return nil, err
+			}
+			out.elems = append(out.elems, elem)
+			cur = c.Right
+		default:
+			//dotted tail
+			rest, err := compilePattern(c)
+			if err != nil {
+				return nil, err
+			}
+			out.rest = rest
+			return out, nil
+		}
+	}
+}
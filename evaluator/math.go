@@ -3,9 +3,10 @@ package evaluator
 import (
 	"errors"
 	"fmt"
-	"github.com/jonbodner/my_lisp/global"
-	"github.com/jonbodner/my_lisp/types"
+	"math"
 	"math/big"
+
+	"github.com/jonbodner/my_lisp/types"
 )
 
 func init() {
@@ -13,9 +14,41 @@ func init() {
 	BuiltIn[("-")] = minus
 	BuiltIn[("*")] = times
 	BuiltIn[("/")] = div
+	BuiltIn["EXACT->INEXACT"] = exactToInexact
+	BuiltIn["INEXACT->EXACT"] = inexactToExact
+	BuiltIn["SQRT"] = sqrt
+	BuiltIn["EXPT"] = expt
+	BuiltIn["EXP"] = floatFunc("EXP", math.Exp)
+	BuiltIn["SIN"] = floatFunc("SIN", math.Sin)
+	BuiltIn["COS"] = floatFunc("COS", math.Cos)
+	BuiltIn["LOG"] = logFunc
+	BuiltIn["ATAN"] = atan
+	BuiltIn["FLOOR"] = roundFunc("FLOOR", math.Floor, floorInt)
+	BuiltIn["CEILING"] = roundFunc("CEILING", math.Ceil, ceilingInt)
+	BuiltIn["ROUND"] = roundFunc("ROUND", math.RoundToEven, roundInt)
+	BuiltIn["TRUNCATE"] = roundFunc("TRUNCATE", math.Trunc, truncateInt)
+	BuiltIn["<"] = compareFunc("<", func(c int) bool { return c < 0 })
+	BuiltIn[">"] = compareFunc(">", func(c int) bool { return c > 0 })
+	BuiltIn["<="] = compareFunc("<=", func(c int) bool { return c <= 0 })
+	BuiltIn[">="] = compareFunc(">=", func(c int) bool { return c >= 0 })
+	BuiltIn["="] = compareFunc("=", func(c int) bool { return c == 0 })
+	BuiltIn["/="] = notEqual
+	BuiltIn["MOD"] = intDivFunc("MOD", bigMod, smallMod)
+	BuiltIn["REM"] = intDivFunc("REM", (*big.Int).Rem, func(x, y int64) int64 { return x % y })
+	BuiltIn["QUOTIENT"] = intDivFunc("QUOTIENT", (*big.Int).Quo, func(x, y int64) int64 { return x / y })
+	BuiltIn["GCD"] = gcd
+	BuiltIn["LCM"] = lcm
+	BuiltIn["ABS"] = abs
+	BuiltIn["MIN"] = extremeFunc("MIN", func(c int) bool { return c < 0 })
+	BuiltIn["MAX"] = extremeFunc("MAX", func(c int) bool { return c > 0 })
+	BuiltIn["NUMERATOR"] = numerator
+	BuiltIn["DENOMINATOR"] = denominator
+	BuiltIn["ZEROP"] = zerop
+	BuiltIn["EVENP"] = parityFunc("EVENP", 0)
+	BuiltIn["ODDP"] = parityFunc("ODDP", 1)
 }
 
-func plus(t *types.SExpr, env types.Env) (types.Expr, error) {
+func plus(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameters for + operator")
 	}
@@ -23,19 +56,18 @@ func plus(t *types.SExpr, env types.Env) (types.Expr, error) {
 	if !ok {
 		return nil, errors.New("+ parameters must be a list")
 	}
-	r := &big.Rat{}
+	var r types.Number = types.NewInteger(0)
 	for {
-		ev, err := evalInner(params.Left, env)
+		ev, err := in.evalArg(params, env)
 		if err != nil {
 			return nil, err
 		}
-		global.Log("\tfinished eval -- checking if it's a number")
-		r2 := &big.Rat{}
-		_, ok = r2.SetString(ev.String())
-		if !ok {
-			return nil, fmt.Errorf("%s is not a valid number", ev)
+		in.log("\tfinished eval -- checking if it's a number")
+		r2, err := asNumber(ev)
+		if err != nil {
+			return nil, err
 		}
-		r.Add(r, r2)
+		r = add(r, r2)
 		next := params.Right
 		if next == types.NIL {
 			break
@@ -46,10 +78,10 @@ func plus(t *types.SExpr, env types.Env) (types.Expr, error) {
 		}
 		params = n
 	}
-	return types.Atom(r.RatString()), nil
+	return r, nil
 }
 
-func minus(t *types.SExpr, env types.Env) (types.Expr, error) {
+func minus(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameters for - operator")
 	}
@@ -62,22 +94,21 @@ func minus(t *types.SExpr, env types.Env) (types.Expr, error) {
 	if v == types.NIL {
 		return nil, errors.New("- requires at least one parameter")
 	}
-	r := &big.Rat{}
+	var r types.Number
 	first := true
 	for {
-		ev, err := evalInner(v, env)
+		ev, err := in.evalInner(v, env)
 		if err != nil {
 			return nil, err
 		}
-		r2 := &big.Rat{}
-		_, ok := r2.SetString(ev.String())
-		if !ok {
-			return nil, fmt.Errorf("%s is not a valid number", ev)
+		r2, err := asNumber(ev)
+		if err != nil {
+			return nil, err
 		}
 		if first {
 			r = r2
 		} else {
-			r.Sub(r, r2)
+			r = sub(r, r2)
 		}
 		pos++
 		v, err = nth(pos, t)
@@ -87,16 +118,16 @@ func minus(t *types.SExpr, env types.Env) (types.Expr, error) {
 		if v == types.NIL {
 			//if there was only one value, just negate it
 			if first {
-				r.Neg(r)
+				r = sub(types.NewInteger(0), r)
 			}
 			break
 		}
 		first = false
 	}
-	return types.Atom(r.RatString()), nil
+	return r, nil
 }
 
-func times(t *types.SExpr, env types.Env) (types.Expr, error) {
+func times(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameters for * operator")
 	}
@@ -109,19 +140,17 @@ func times(t *types.SExpr, env types.Env) (types.Expr, error) {
 	if v == types.NIL {
 		return nil, errors.New("- requires at least one parameter")
 	}
-	r := &big.Rat{}
-	r.SetString("1")
+	var r types.Number = types.NewInteger(1)
 	for {
-		ev, err := evalInner(v, env)
+		ev, err := in.evalInner(v, env)
 		if err != nil {
 			return nil, err
 		}
-		r2 := &big.Rat{}
-		_, ok := r2.SetString(ev.String())
-		if !ok {
-			return nil, fmt.Errorf("%s is not a valid number", ev)
+		r2, err := asNumber(ev)
+		if err != nil {
+			return nil, err
 		}
-		r.Mul(r, r2)
+		r = mul(r, r2)
 		pos++
 		v, err = nth(pos, t)
 		if err != nil {
@@ -131,10 +160,10 @@ func times(t *types.SExpr, env types.Env) (types.Expr, error) {
 			break
 		}
 	}
-	return types.Atom(r.RatString()), nil
+	return r, nil
 }
 
-func div(t *types.SExpr, env types.Env) (types.Expr, error) {
+func div(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
 	if t.Right == types.NIL {
 		return nil, errors.New("missing parameters for / operator")
 	}
@@ -147,22 +176,24 @@ func div(t *types.SExpr, env types.Env) (types.Expr, error) {
 	if v == types.NIL {
 		return nil, errors.New("/ requires at least one parameter")
 	}
-	r := &big.Rat{}
+	var r types.Number
 	first := true
 	for {
-		ev, err := evalInner(v, env)
+		ev, err := in.evalInner(v, env)
 		if err != nil {
 			return nil, err
 		}
-		r2 := &big.Rat{}
-		_, ok := r2.SetString(ev.String())
-		if !ok {
-			return nil, fmt.Errorf("%s is not a valid number", ev)
+		r2, err := asNumber(ev)
+		if err != nil {
+			return nil, err
 		}
 		if first {
 			r = r2
 		} else {
-			r.Mul(r, r2.Inv(r2))
+			r, err = quo(r, r2)
+			if err != nil {
+				return nil, err
+			}
 		}
 		pos++
 		v, err = nth(pos, t)
@@ -170,13 +201,621 @@ func div(t *types.SExpr, env types.Env) (types.Expr, error) {
 			return nil, err
 		}
 		if v == types.NIL {
-			//if there was only one value, just negate it
+			//if there was only one value, just invert it
 			if first {
-				r.Inv(r)
+				r, err = quo(types.NewInteger(1), r)
+				if err != nil {
+					return nil, err
+				}
 			}
 			break
 		}
 		first = false
 	}
-	return types.Atom(r.RatString()), nil
+	return r, nil
+}
+
+func asNumber(e types.Expr) (types.Number, error) {
+	n, ok := e.(types.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a valid number", e)
+	}
+	return n, nil
+}
+
+// The arithmetic helpers below follow the same rules: if both numbers are Integers that fit in an int64,
+// and the result does too, the work is done with int64s. Otherwise, if either number is a Float, the
+// result is a Float. If not, the work is done with big.Int or big.Rat and the result is an Integer
+// if it's a whole number, or a Ratio if it isn't.
+
+func add(a, b types.Number) types.Number {
+	if x, y, ok := smallInts(a, b); ok {
+		s := x + y
+		//overflow happens if both operands have the same sign and the result has a different one
+		if (x^s)&(y^s) >= 0 {
+			return types.NewInteger(s)
+		}
+	}
+	return arith(a, b, (*big.Int).Add, (*big.Rat).Add, func(x, y float64) float64 { return x + y })
+}
+
+func sub(a, b types.Number) types.Number {
+	if x, y, ok := smallInts(a, b); ok {
+		s := x - y
+		//overflow happens if the operands have different signs and the result's sign differs from x
+		if (x^y)&(x^s) >= 0 {
+			return types.NewInteger(s)
+		}
+	}
+	return arith(a, b, (*big.Int).Sub, (*big.Rat).Sub, func(x, y float64) float64 { return x - y })
+}
+
+func mul(a, b types.Number) types.Number {
+	if x, y, ok := smallInts(a, b); ok {
+		if x == 0 || y == 0 {
+			return types.NewInteger(0)
+		}
+		p := x * y
+		if p/y == x && !(x == -1 && y == minInt64) && !(y == -1 && x == minInt64) {
+			return types.NewInteger(p)
+		}
+	}
+	return arith(a, b, (*big.Int).Mul, (*big.Rat).Mul, func(x, y float64) float64 { return x * y })
+}
+
+func quo(a, b types.Number) (types.Number, error) {
+	if isExactZero(b) {
+		return nil, errors.New("division by zero")
+	}
+	if x, y, ok := smallInts(a, b); ok && x%y == 0 && !(x == minInt64 && y == -1) {
+		return types.NewInteger(x / y), nil
+	}
+	if isFloat(a) || isFloat(b) {
+		return types.Float(toFloat64(a) / toFloat64(b)), nil
+	}
+	return types.NewRatio(new(big.Rat).Quo(toRat(a), toRat(b))), nil
+}
+
+const minInt64 = -1 << 63
+
+// smallInts returns the values of a and b if they are both Integers that fit in an int64
+func smallInts(a, b types.Number) (int64, int64, bool) {
+	ai, ok := a.(types.Integer)
+	if !ok {
+		return 0, 0, false
+	}
+	bi, ok := b.(types.Integer)
+	if !ok {
+		return 0, 0, false
+	}
+	x, ok := ai.Int64()
+	if !ok {
+		return 0, 0, false
+	}
+	y, ok := bi.Int64()
+	return x, y, ok
+}
+
+// arith applies the operation that matches the types of a and b
+func arith(a, b types.Number,
+	intOp func(z, x, y *big.Int) *big.Int,
+	ratOp func(z, x, y *big.Rat) *big.Rat,
+	floatOp func(x, y float64) float64) types.Number {
+	if isFloat(a) || isFloat(b) {
+		return types.Float(floatOp(toFloat64(a), toFloat64(b)))
+	}
+	ai, aInt := a.(types.Integer)
+	bi, bInt := b.(types.Integer)
+	if aInt && bInt {
+		return types.NewBigInteger(intOp(new(big.Int), ai.BigInt(), bi.BigInt()))
+	}
+	return types.NewRatio(ratOp(new(big.Rat), toRat(a), toRat(b)))
+}
+
+func isFloat(n types.Number) bool {
+	_, ok := n.(types.Float)
+	return ok
+}
+
+func isExactZero(n types.Number) bool {
+	i, ok := n.(types.Integer)
+	return ok && i.Sign() == 0
+}
+
+// toRat returns the exact value of n. Floats are converted exactly; infinities and NaN become 0.
+func toRat(n types.Number) *big.Rat {
+	switch n := n.(type) {
+	case types.Integer:
+		return n.Rat()
+	case types.Ratio:
+		return n.Rat()
+	case types.Float:
+		r := new(big.Rat)
+		if r.SetFloat64(float64(n)) == nil {
+			return new(big.Rat)
+		}
+		return r
+	}
+	return new(big.Rat)
+}
+
+func toFloat64(n types.Number) float64 {
+	switch n := n.(type) {
+	case types.Integer:
+		return n.Float64()
+	case types.Ratio:
+		return n.Float64()
+	case types.Float:
+		return float64(n)
+	}
+	return 0
+}
+
+// compareNumbers returns -1, 0 or 1 depending on whether a is less than, equal to or greater than b.
+// If either number is NaN, ok is false.
+func compareNumbers(a, b types.Number) (result int, ok bool) {
+	if x, y, ok := smallInts(a, b); ok {
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	if isFloat(a) || isFloat(b) {
+		x, y := toFloat64(a), toFloat64(b)
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		case x == y:
+			return 0, true
+		}
+		return 0, false
+	}
+	return toRat(a).Cmp(toRat(b)), true
+}
+
+// Exact numbers are Integers and Ratios; Floats are inexact. The math functions below return an exact
+// result when given exact parameters and the answer can be represented exactly, and a Float otherwise.
+
+// numberParam evaluates the single parameter of a math function and checks that it's a number
+func (in *Interpreter) numberParam(name string, t *types.SExpr, env types.Env) (types.Number, error) {
+	vals, err := in.checkedParams(name, t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return asNumber(vals[0])
+}
+
+func exactToInexact(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	n, err := in.numberParam("EXACT->INEXACT", t, env)
+	if err != nil {
+		return nil, err
+	}
+	return types.Float(toFloat64(n)), nil
+}
+
+// inexactToExact returns the exact value of a Float, so (INEXACT->EXACT 0.1) is the ratio
+// closest to 0.1 that a float64 can hold. Infinities and NaN don't have an exact value.
+func inexactToExact(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	n, err := in.numberParam("INEXACT->EXACT", t, env)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := n.(types.Float)
+	if !ok {
+		return n, nil
+	}
+	if math.IsInf(float64(f), 0) || math.IsNaN(float64(f)) {
+		return nil, fmt.Errorf("%s has no exact value", f)
+	}
+	return types.NewRatio(new(big.Rat).SetFloat64(float64(f))), nil
+}
+
+// floatFunc makes a math function with a single parameter that always returns a Float
+func floatFunc(name string, f func(float64) float64) Evaluator {
+	return func(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+		n, err := in.numberParam(name, t, env)
+		if err != nil {
+			return nil, err
+		}
+		return types.Float(f(toFloat64(n))), nil
+	}
+}
+
+// sqrt returns an exact result for exact squares, like (SQRT 16) or (SQRT 1/4)
+func sqrt(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	n, err := in.numberParam("SQRT", t, env)
+	if err != nil {
+		return nil, err
+	}
+	if sign(n) < 0 {
+		return nil, errors.New("SQRT parameter must not be negative")
+	}
+	if !isFloat(n) {
+		r := toRat(n)
+		num, numOK := exactSqrt(r.Num())
+		den, denOK := exactSqrt(r.Denom())
+		if numOK && denOK {
+			return types.NewRatio(new(big.Rat).SetFrac(num, den)), nil
+		}
+	}
+	return types.Float(math.Sqrt(toFloat64(n))), nil
+}
+
+// exactSqrt returns the square root of i if it is a whole number
+func exactSqrt(i *big.Int) (*big.Int, bool) {
+	root := new(big.Int).Sqrt(i)
+	return root, new(big.Int).Mul(root, root).Cmp(i) == 0
+}
+
+// expt raises BASE to POWER. If BASE is exact and POWER is an Integer, the result is exact.
+// (EXPT BASE POWER)
+func expt(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("EXPT", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	base, err := asNumber(vals[0])
+	if err != nil {
+		return nil, err
+	}
+	power, err := asNumber(vals[1])
+	if err != nil {
+		return nil, err
+	}
+	p, ok := power.(types.Integer)
+	if !ok || isFloat(base) {
+		return types.Float(math.Pow(toFloat64(base), toFloat64(power))), nil
+	}
+	pi := p.BigInt()
+	if sign(base) == 0 && pi.Sign() < 0 {
+		return nil, errors.New("division by zero")
+	}
+	r := toRat(base)
+	num := new(big.Int).Exp(r.Num(), new(big.Int).Abs(pi), nil)
+	den := new(big.Int).Exp(r.Denom(), new(big.Int).Abs(pi), nil)
+	if pi.Sign() < 0 {
+		num, den = den, num
+	}
+	return types.NewRatio(new(big.Rat).SetFrac(num, den)), nil
+}
+
+// logFunc returns the natural logarithm of X, or the logarithm in BASE if one is supplied.
+// (LOG X [BASE])
+func logFunc(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("LOG", t, env, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	nums := make([]float64, len(vals))
+	for i, v := range vals {
+		n, err := asNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		if sign(n) <= 0 {
+			return nil, errors.New("LOG parameters must be positive")
+		}
+		nums[i] = math.Log(toFloat64(n))
+	}
+	if len(nums) == 2 {
+		return types.Float(nums[0] / nums[1]), nil
+	}
+	return types.Float(nums[0]), nil
+}
+
+// atan returns the arc tangent of Y, or of Y/X using the signs of both to pick the quadrant.
+// (ATAN Y [X])
+func atan(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("ATAN", t, env, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	y, err := asNumber(vals[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 1 {
+		return types.Float(math.Atan(toFloat64(y))), nil
+	}
+	x, err := asNumber(vals[1])
+	if err != nil {
+		return nil, err
+	}
+	return types.Float(math.Atan2(toFloat64(y), toFloat64(x))), nil
+}
+
+// roundFunc makes a function that rounds a number to a whole number.
+// Exact numbers become Integers; Floats stay Floats.
+func roundFunc(name string, f func(float64) float64, exact func(num, den *big.Int) *big.Int) Evaluator {
+	return func(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+		n, err := in.numberParam(name, t, env)
+		if err != nil {
+			return nil, err
+		}
+		switch n := n.(type) {
+		case types.Float:
+			return types.Float(f(float64(n))), nil
+		case types.Ratio:
+			r := n.Rat()
+			return types.NewBigInteger(exact(r.Num(), r.Denom())), nil
+		}
+		return n, nil
+	}
+}
+
+// the exact rounding functions are only called with a positive denominator that isn't 1
+
+func floorInt(num, den *big.Int) *big.Int {
+	//Div rounds toward negative infinity when the divisor is positive
+	return new(big.Int).Div(num, den)
+}
+
+func ceilingInt(num, den *big.Int) *big.Int {
+	q := floorInt(num, den)
+	return q.Add(q, big.NewInt(1))
+}
+
+func truncateInt(num, den *big.Int) *big.Int {
+	return new(big.Int).Quo(num, den)
+}
+
+// roundInt rounds to the nearest whole number, and to the even one when it's halfway between two
+func roundInt(num, den *big.Int) *big.Int {
+	q, m := new(big.Int).DivMod(num, den, new(big.Int))
+	c := new(big.Int).Lsh(m, 1).Cmp(den)
+	if c > 0 || (c == 0 && q.Bit(0) == 1) {
+		q.Add(q, big.NewInt(1))
+	}
+	return q
+}
+
+// sign returns -1, 0 or 1 depending on whether n is negative, zero or positive. NaN returns 0.
+func sign(n types.Number) int {
+	switch n := n.(type) {
+	case types.Integer:
+		return n.Sign()
+	case types.Ratio:
+		return n.Sign()
+	case types.Float:
+		switch {
+		case n < 0:
+			return -1
+		case n > 0:
+			return 1
+		}
+	}
+	return 0
+}
+
+// numberParams evaluates the parameters of a math function and checks that they are all numbers
+func (in *Interpreter) numberParams(name string, t *types.SExpr, env types.Env, minCount, maxCount int) ([]types.Number, error) {
+	vals, err := in.checkedParams(name, t, env, minCount, maxCount)
+	if err != nil {
+		return nil, err
+	}
+	nums := make([]types.Number, len(vals))
+	for i, v := range vals {
+		nums[i], err = asNumber(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nums, nil
+}
+
+// integerParams evaluates the parameters of a math function and checks that they are all Integers
+func (in *Interpreter) integerParams(name string, t *types.SExpr, env types.Env, minCount, maxCount int) ([]types.Integer, error) {
+	vals, err := in.checkedParams(name, t, env, minCount, maxCount)
+	if err != nil {
+		return nil, err
+	}
+	ints := make([]types.Integer, len(vals))
+	for i, v := range vals {
+		n, ok := v.(types.Integer)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an integer", v)
+		}
+		ints[i] = n
+	}
+	return ints, nil
+}
+
+func boolToExpr(b bool) types.Expr {
+	if b {
+		return types.T
+	}
+	return types.EMPTY
+}
+
+// compareFunc makes a comparison that is true if every pair of neighboring parameters passes the test.
+// Exact and inexact numbers are compared by value, so (= 1 1.0) is true. Comparisons with NaN are false.
+func compareFunc(name string, test func(int) bool) Evaluator {
+	return func(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+		nums, err := in.numberParams(name, t, env, 1, -1)
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(nums); i++ {
+			c, ok := compareNumbers(nums[i-1], nums[i])
+			if !ok || !test(c) {
+				return types.EMPTY, nil
+			}
+		}
+		return types.T, nil
+	}
+}
+
+// notEqual is true if no two of its parameters are equal
+func notEqual(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	nums, err := in.numberParams("/=", t, env, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for i := range nums {
+		for j := i + 1; j < len(nums); j++ {
+			if c, ok := compareNumbers(nums[i], nums[j]); ok && c == 0 {
+				return types.EMPTY, nil
+			}
+		}
+	}
+	return types.T, nil
+}
+
+// intDivFunc makes a function that divides one Integer by another.
+// QUOTIENT and REM round toward zero, so the remainder has the sign of the dividend.
+// MOD rounds toward negative infinity, so the result has the sign of the divisor.
+func intDivFunc(name string, bigOp func(z, x, y *big.Int) *big.Int, smallOp func(x, y int64) int64) Evaluator {
+	return func(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+		ints, err := in.integerParams(name, t, env, 2, 2)
+		if err != nil {
+			return nil, err
+		}
+		if ints[1].Sign() == 0 {
+			return nil, errors.New("division by zero")
+		}
+		if x, y, ok := smallInts(ints[0], ints[1]); ok && !(x == minInt64 && y == -1) {
+			return types.NewInteger(smallOp(x, y)), nil
+		}
+		return types.NewBigInteger(bigOp(new(big.Int), ints[0].BigInt(), ints[1].BigInt())), nil
+	}
+}
+
+func smallMod(x, y int64) int64 {
+	m := x % y
+	if m != 0 && (m < 0) != (y < 0) {
+		m += y
+	}
+	return m
+}
+
+func bigMod(z, x, y *big.Int) *big.Int {
+	//big.Int's Mod is never negative; adjust it to have the sign of the divisor
+	z.Mod(x, y)
+	if z.Sign() != 0 && y.Sign() < 0 {
+		z.Add(z, y)
+	}
+	return z
+}
+
+// gcd returns the greatest common divisor of its parameters. (GCD) is 0.
+func gcd(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	ints, err := in.integerParams("GCD", t, env, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	out := new(big.Int)
+	for _, i := range ints {
+		out.GCD(nil, nil, out, new(big.Int).Abs(i.BigInt()))
+	}
+	return types.NewBigInteger(out), nil
+}
+
+// lcm returns the least common multiple of its parameters. (LCM) is 1.
+func lcm(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	ints, err := in.integerParams("LCM", t, env, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	out := big.NewInt(1)
+	for _, i := range ints {
+		b := new(big.Int).Abs(i.BigInt())
+		if b.Sign() == 0 {
+			return types.NewInteger(0), nil
+		}
+		g := new(big.Int).GCD(nil, nil, out, b)
+		out.Mul(out, b.Quo(b, g))
+	}
+	return types.NewBigInteger(out), nil
+}
+
+func abs(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	n, err := in.numberParam("ABS", t, env)
+	if err != nil {
+		return nil, err
+	}
+	if sign(n) < 0 {
+		return sub(types.NewInteger(0), n), nil
+	}
+	return n, nil
+}
+
+// extremeFunc makes MIN or MAX. If any parameter is a Float, the result is a Float.
+func extremeFunc(name string, better func(int) bool) Evaluator {
+	return func(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+		nums, err := in.numberParams(name, t, env, 1, -1)
+		if err != nil {
+			return nil, err
+		}
+		out := nums[0]
+		inexact := isFloat(out)
+		for _, n := range nums[1:] {
+			inexact = inexact || isFloat(n)
+			c, ok := compareNumbers(n, out)
+			if !ok {
+				return types.Float(math.NaN()), nil
+			}
+			if better(c) {
+				out = n
+			}
+		}
+		if inexact {
+			return types.Float(toFloat64(out)), nil
+		}
+		return out, nil
+	}
+}
+
+// exactParam evaluates the single parameter of a math function and checks that it's an exact number
+func (in *Interpreter) exactParam(name string, t *types.SExpr, env types.Env) (*big.Rat, error) {
+	n, err := in.numberParam(name, t, env)
+	if err != nil {
+		return nil, err
+	}
+	if isFloat(n) {
+		return nil, fmt.Errorf("%s parameter must be an exact number", name)
+	}
+	return toRat(n), nil
+}
+
+func numerator(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	r, err := in.exactParam("NUMERATOR", t, env)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBigInteger(r.Num()), nil
+}
+
+func denominator(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	r, err := in.exactParam("DENOMINATOR", t, env)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBigInteger(r.Denom()), nil
+}
+
+func zerop(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	n, err := in.numberParam("ZEROP", t, env)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := n.(types.Float); ok {
+		return boolToExpr(f == 0), nil
+	}
+	return boolToExpr(sign(n) == 0), nil
+}
+
+// parityFunc makes EVENP or ODDP, which are true if the remainder after dividing by 2 is bit
+func parityFunc(name string, bit uint) Evaluator {
+	return func(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+		ints, err := in.integerParams(name, t, env, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		return boolToExpr(ints[0].BigInt().Bit(0) == bit), nil
+	}
 }
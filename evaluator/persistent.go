@@ -0,0 +1,257 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["ASSOC"] = assoc
+	BuiltIn["DISSOC"] = dissoc
+	BuiltIn["CONJ"] = conj
+	BuiltIn["TRANSIENT"] = transient
+	BuiltIn["ASSOC!"] = assocTransient
+	BuiltIn["DISSOC!"] = dissocTransient
+	BuiltIn["CONJ!"] = conjTransient
+	BuiltIn["PERSISTENT!"] = persistent
+}
+
+// assoc returns a new map or vector with each KEY set to its VALUE. The collection that's passed in isn't changed,
+// and the new one shares most of its structure, so this takes O(log32 n) time for each KEY.
+// The keys of a vector are indexes; an index that is the length of the vector adds VALUE to the end.
+// To look a key up in an association list, use ASSQ.
+// (ASSOC COLL KEY VALUE [KEY VALUE]...)
+func assoc(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("ASSOC", t, env, 3, -1)
+	if err != nil {
+		return nil, err
+	}
+	coll, kvs := vals[0], vals[1:]
+	if len(kvs)%2 != 0 {
+		return nil, errors.New("ASSOC requires a value for every key")
+	}
+	for i := 0; i < len(kvs); i += 2 {
+		switch c := coll.(type) {
+		case *types.Map:
+			m, err := c.Assoc(kvs[i], kvs[i+1])
+			if err != nil {
+				return nil, err
+			}
+			coll = m
+		case *types.Vector:
+			idx, err := asInt("ASSOC", kvs[i])
+			if err != nil {
+				return nil, err
+			}
+			v, ok := c.Assoc(idx, kvs[i+1])
+			if !ok {
+				return nil, fmt.Errorf("ASSOC index %d is out of bounds for a length of %d", idx, c.Len())
+			}
+			coll = v
+		default:
+			return nil, errors.New("ASSOC parameter must be a map or a vector")
+		}
+	}
+	return coll, nil
+}
+
+// dissoc returns a new map without any of the KEYs, or a new set without any of them.
+// The collection that's passed in isn't changed. It's not an error if a KEY isn't there.
+// (DISSOC COLL KEY...)
+func dissoc(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("DISSOC", t, env, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	switch c := vals[0].(type) {
+	case *types.Map:
+		for _, k := range vals[1:] {
+			c = c.Dissoc(k)
+		}
+		return c, nil
+	case *types.Set:
+		for _, k := range vals[1:] {
+			c = c.Disj(k)
+		}
+		return c, nil
+	}
+	return nil, errors.New("DISSOC parameter must be a map or a set")
+}
+
+// conj returns a new collection with each X added to it: to the end of a vector, to a set, or to the front of a list.
+// To add to a map, X is a (KEY . VALUE) pair or a [KEY VALUE] vector. The collection that's passed in isn't changed.
+// (CONJ COLL X...)
+func conj(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("CONJ", t, env, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	coll := vals[0]
+	for _, x := range vals[1:] {
+		switch c := coll.(type) {
+		case *types.Vector:
+			coll = c.Conj(x)
+		case *types.Set:
+			s, err := c.Conj(x)
+			if err != nil {
+				return nil, err
+			}
+			coll = s
+		case *types.Map:
+			k, v, err := mapPair("CONJ", x)
+			if err != nil {
+				return nil, err
+			}
+			m, err := c.Assoc(k, v)
+			if err != nil {
+				return nil, err
+			}
+			coll = m
+		case *types.SExpr, types.Nil:
+			if isEmpty(c) {
+				coll = &types.SExpr{Left: x, Right: types.NIL}
+			} else {
+				coll = &types.SExpr{Left: x, Right: c}
+			}
+		default:
+			return nil, errors.New("CONJ parameter must be a vector, a set, a map or a list")
+		}
+	}
+	return coll, nil
+}
+
+// mapPair returns the key and value in a (KEY . VALUE) pair or a [KEY VALUE] vector
+func mapPair(name string, e types.Expr) (types.Expr, types.Expr, error) {
+	switch e := e.(type) {
+	case *types.SExpr:
+		if !isEmpty(e) {
+			return e.Left, e.Right, nil
+		}
+	case *types.Vector:
+		if e.Len() == 2 {
+			elems := e.Elems()
+			return elems[0], elems[1], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%s can only add a (KEY . VALUE) pair or a [KEY VALUE] vector to a map", name)
+}
+
+// transient returns a transient version of a map or a vector. ASSOC!, DISSOC! and CONJ! change a transient in place,
+// which is faster than making a new version for each change, and PERSISTENT! turns it back into a map or vector.
+// The collection that's passed in isn't changed.
+// (TRANSIENT COLL)
+func transient(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("TRANSIENT", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	switch c := vals[0].(type) {
+	case *types.Map:
+		return c.Transient(), nil
+	case *types.Vector:
+		return c.Transient(), nil
+	}
+	return nil, errors.New("TRANSIENT parameter must be a map or a vector")
+}
+
+// assocTransient sets each KEY in the transient to its VALUE, and returns the transient.
+// Like ASSOC, the keys of a transient vector are indexes.
+// (ASSOC! TRANSIENT KEY VALUE [KEY VALUE]...)
+func assocTransient(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("ASSOC!", t, env, 3, -1)
+	if err != nil {
+		return nil, err
+	}
+	kvs := vals[1:]
+	if len(kvs)%2 != 0 {
+		return nil, errors.New("ASSOC! requires a value for every key")
+	}
+	for i := 0; i < len(kvs); i += 2 {
+		switch c := vals[0].(type) {
+		case *types.TransientMap:
+			if err := c.Put(kvs[i], kvs[i+1]); err != nil {
+				return nil, err
+			}
+		case *types.TransientVector:
+			idx, err := asInt("ASSOC!", kvs[i])
+			if err != nil {
+				return nil, err
+			}
+			ok, err := c.Set(idx, kvs[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("ASSOC! index %d is out of bounds for a length of %d", idx, c.Len())
+			}
+		default:
+			return nil, errors.New("ASSOC! parameter must be a transient")
+		}
+	}
+	return vals[0], nil
+}
+
+// dissocTransient removes each KEY from a transient map, and returns it
+// (DISSOC! TRANSIENT KEY...)
+func dissocTransient(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("DISSOC!", t, env, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := vals[0].(*types.TransientMap)
+	if !ok {
+		return nil, errors.New("DISSOC! parameter must be a transient map")
+	}
+	for _, k := range vals[1:] {
+		if err := m.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// conjTransient adds each X to the end of a transient vector, or each (KEY . VALUE) pair or [KEY VALUE] vector
+// to a transient map, and returns the transient
+// (CONJ! TRANSIENT X...)
+func conjTransient(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("CONJ!", t, env, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, x := range vals[1:] {
+		switch c := vals[0].(type) {
+		case *types.TransientVector:
+			err = c.Conj(x)
+		case *types.TransientMap:
+			k, v, pairErr := mapPair("CONJ!", x)
+			if pairErr != nil {
+				return nil, pairErr
+			}
+			err = c.Put(k, v)
+		default:
+			return nil, errors.New("CONJ! parameter must be a transient")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vals[0], nil
+}
+
+// persistent returns the map or vector that a transient has built. After this, the transient can't be used.
+// (PERSISTENT! TRANSIENT)
+func persistent(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("PERSISTENT!", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	switch c := vals[0].(type) {
+	case *types.TransientMap:
+		return c.Persistent()
+	case *types.TransientVector:
+		return c.Persistent()
+	}
+	return nil, errors.New("PERSISTENT! parameter must be a transient")
+}
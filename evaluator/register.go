@@ -0,0 +1,388 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+var (
+	exprType  = reflect.TypeOf((*types.Expr)(nil)).Elem()
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	ratType   = reflect.TypeOf((*big.Rat)(nil))
+	intType   = reflect.TypeOf((*big.Int)(nil))
+)
+
+// Register makes the Go function fn available to Lisp code as the built-in function name.
+// The parameters to name are evaluated and converted to the types of fn's parameters,
+// and fn's result is converted back into a Lisp value, following the rules described on Define.
+// If fn is variadic, any number of parameters can be supplied for the variadic part.
+// fn can return nothing, a single value, an error, or a single value and an error.
+// If fn returns nothing, the Lisp function returns NIL. A non-nil error is returned as the error for the call.
+// Passing the wrong number of parameters, or a parameter that can't be converted, is also an error.
+//
+// Register should be called before the Interpreter is used from multiple goroutines.
+func (in *Interpreter) Register(name string, fn interface{}) error {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		return fmt.Errorf("can't register %s: %T is not a function", name, fn)
+	}
+	ft := fv.Type()
+	for i := 0; i < ft.NumIn(); i++ {
+		pt := ft.In(i)
+		if ft.IsVariadic() && i == ft.NumIn()-1 {
+			pt = pt.Elem()
+		}
+		if !canConvert(pt) {
+			return fmt.Errorf("can't register %s: unsupported parameter type %s", name, pt)
+		}
+	}
+	switch {
+	case ft.NumOut() > 2:
+		return fmt.Errorf("can't register %s: too many return values", name)
+	case ft.NumOut() == 2 && ft.Out(1) != errorType:
+		return fmt.Errorf("can't register %s: second return value must be an error", name)
+	case ft.NumOut() >= 1 && !canConvert(ft.Out(0)):
+		return fmt.Errorf("can't register %s: unsupported return type %s", name, ft.Out(0))
+	}
+	in.builtIn[types.Atom(name)] = func(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+		vals, err := in.evalParams(t, env)
+		if err != nil {
+			return nil, err
+		}
+		return callGo(name, fv, vals)
+	}
+	return nil
+}
+
+// Define converts the Go value v to a Lisp value and binds it to name in the top-level environment.
+// This is how a program hands its own data to Lisp code.
+//
+// Go values are converted to Lisp values like this:
+//
+//	Go value                          Lisp value
+//	types.Expr                        the same value
+//	string                            a string
+//	bool                              T or NIL
+//	*big.Rat, *big.Int, int*, uint*   an integer or a ratio
+//	float32, float64                  a float
+//	slices and arrays                 a list of the converted elements
+//	nil pointers, maps, funcs, etc.   NIL
+//	anything else                     a types.GoValue holding the value
+//
+// Values like structs, pointers, maps and functions become GoValues that can be
+// used with GO-FIELD, GO-SET-FIELD, GO-METHOD and GO-CALL. To hand a slice to Lisp code without
+// copying it into a list, so that GO-SET-FIELD can change it, wrap it in a types.GoValue.
+//
+// When a Lisp value is passed to a Go function or stored in a Go variable, it is converted to the Go type:
+//
+//	Go type                   Lisp value
+//	types.Expr                any value, unconverted
+//	string                    a string
+//	bool                      NIL is false, anything else is true
+//	*big.Rat                  any number
+//	*big.Int, int*, uint*     an integer that fits in the Go type
+//	float32, float64          any number
+//	slices                    a list or vector whose elements convert to the element type
+//	arrays                    a list or vector of the same length whose elements convert to the element type
+//	interface{}               a string becomes a string, an integer an int (or *big.Int if it's too big),
+//	                          a ratio a *big.Rat, a float a float64, T becomes true, NIL becomes nil,
+//	                          a list becomes an []interface{}, other symbols become strings,
+//	                          and a GoValue becomes the value it holds
+//	pointers, maps, funcs     a GoValue holding a value of that type, or NIL for nil
+//	any other type            a GoValue holding a value of that type
+//
+// A GoValue can be passed wherever the Go value it holds can be assigned.
+func (in *Interpreter) Define(name string, v interface{}) error {
+	e, err := fromGo(reflect.ValueOf(v))
+	if err != nil {
+		return fmt.Errorf("can't define %s: %w", name, err)
+	}
+	in.topLevel.Put(types.Atom(name), e)
+	return nil
+}
+
+// callGo converts vals to the parameter types of fv, calls it, and converts the result back.
+// If the last result is a non-nil error, it is returned as the error.
+// Otherwise, multiple results are returned as a list.
+func callGo(name string, fv reflect.Value, vals []types.Expr) (out types.Expr, err error) {
+	ft := fv.Type()
+	fixed := ft.NumIn()
+	if ft.IsVariadic() {
+		fixed--
+		if len(vals) < fixed {
+			return nil, fmt.Errorf("too few parameters for %s. Expected at least %d, got %d", name, fixed, len(vals))
+		}
+	} else if len(vals) != fixed {
+		return nil, fmt.Errorf("wrong number of parameters for %s. Expected %d, got %d", name, fixed, len(vals))
+	}
+	args := make([]reflect.Value, len(vals))
+	for i, v := range vals {
+		var pt reflect.Type
+		if i < fixed {
+			pt = ft.In(i)
+		} else {
+			pt = ft.In(fixed).Elem()
+		}
+		args[i], err = toGo(v, pt)
+		if err != nil {
+			return nil, fmt.Errorf("%s parameter %d: %w", name, i+1, err)
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			out = nil
+			err = fmt.Errorf("%s: %v", name, r)
+		}
+	}()
+	results := fv.Call(args)
+	if len(results) > 0 && ft.Out(len(results)-1) == errorType {
+		if e := results[len(results)-1]; !e.IsNil() {
+			return nil, e.Interface().(error)
+		}
+		results = results[:len(results)-1]
+	}
+	switch len(results) {
+	case 0:
+		return types.EMPTY, nil
+	case 1:
+		return fromGo(results[0])
+	}
+	//more than one result is returned as a list
+	vals = make([]types.Expr, len(results))
+	for i, r := range results {
+		vals[i], err = fromGo(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sliceToList(vals), nil
+}
+
+// canConvert reports if values of type t can be converted to and from Lisp values
+func canConvert(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return false
+	}
+	return true
+}
+
+// toGo converts a Lisp value to a Go value of type t
+func toGo(v types.Expr, t reflect.Type) (reflect.Value, error) {
+	if g, ok := v.(types.GoValue); ok && t != exprType {
+		gv := reflect.ValueOf(g.Value)
+		if g.Value == nil || !gv.Type().AssignableTo(t) {
+			return reflect.Value{}, fmt.Errorf("%s is not a %s", v, t)
+		}
+		out := reflect.New(t).Elem()
+		out.Set(gv)
+		return out, nil
+	}
+	if t == exprType {
+		return reflect.ValueOf(&v).Elem(), nil
+	}
+	if t == ratType {
+		n, ok := v.(types.Number)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s is not a number", v)
+		}
+		return reflect.ValueOf(toRat(n)), nil
+	}
+	if t == intType {
+		i, ok := v.(types.Integer)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s is not an integer", v)
+		}
+		return reflect.ValueOf(i.BigInt()), nil
+	}
+	out := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		s, ok := v.(types.String)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s is not a string", v)
+		}
+		out.SetString(string(s))
+	case reflect.Bool:
+		out.SetBool(!isEmpty(v))
+	case reflect.Float32, reflect.Float64:
+		n, ok := v.(types.Number)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s is not a number", v)
+		}
+		out.SetFloat(toFloat64(n))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := v.(types.Integer)
+		x, fits := i.Int64()
+		if !ok || !fits || out.OverflowInt(x) {
+			return reflect.Value{}, fmt.Errorf("%s is not an integer that fits in an %s", v, t)
+		}
+		out.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := v.(types.Integer)
+		b := i.BigInt()
+		if !ok || !b.IsUint64() || out.OverflowUint(b.Uint64()) {
+			return reflect.Value{}, fmt.Errorf("%s is not an integer that fits in a %s", v, t)
+		}
+		out.SetUint(b.Uint64())
+	case reflect.Slice, reflect.Array:
+		var elems []types.Expr
+		var err error
+		if vec, ok := v.(*types.Vector); ok {
+			elems = vec.Elems()
+		} else if elems, err = listToExprs(v); err != nil {
+			return reflect.Value{}, fmt.Errorf("%s is not a list", v)
+		}
+		if t.Kind() == reflect.Slice {
+			out = reflect.MakeSlice(t, len(elems), len(elems))
+		} else if len(elems) != t.Len() {
+			return reflect.Value{}, fmt.Errorf("%s doesn't have %d elements", v, t.Len())
+		}
+		for i, e := range elems {
+			ev, err := toGo(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(ev)
+		}
+	case reflect.Interface:
+		if isEmpty(v) {
+			break
+		}
+		nv, err := toNatural(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		switch {
+		case reflect.TypeOf(nv).Implements(t):
+			out.Set(reflect.ValueOf(nv))
+		case reflect.TypeOf(v).Implements(t):
+			out.Set(reflect.ValueOf(v))
+		default:
+			return reflect.Value{}, fmt.Errorf("%s is not a %s", v, t)
+		}
+	case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan:
+		if !isEmpty(v) {
+			return reflect.Value{}, fmt.Errorf("%s is not a %s", v, t)
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("%s is not a %s", v, t)
+	}
+	return out, nil
+}
+
+// toNatural converts a Lisp value to the Go value that's closest to it, for storing in an interface{}
+func toNatural(v types.Expr) (interface{}, error) {
+	switch v := v.(type) {
+	case types.String:
+		return string(v), nil
+	case types.GoValue:
+		return v.Value, nil
+	case types.Atom:
+		if v == types.T {
+			return true, nil
+		}
+		return string(v), nil
+	case types.Integer:
+		if x, ok := v.Int64(); ok && int64(int(x)) == x {
+			return int(x), nil
+		}
+		return v.BigInt(), nil
+	case types.Ratio:
+		return v.Rat(), nil
+	case types.Float:
+		return float64(v), nil
+	case *types.SExpr:
+		if isEmpty(v) {
+			return nil, nil
+		}
+		elems, err := listToExprs(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a list", v)
+		}
+		out := make([]interface{}, len(elems))
+		for i, e := range elems {
+			out[i], err = toNatural(e)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// fromGo converts a Go value to a Lisp value
+func fromGo(v reflect.Value) (types.Expr, error) {
+	if !v.IsValid() {
+		return types.EMPTY, nil
+	}
+	if v.Type().Implements(exprType) {
+		if v.Kind() == reflect.Interface && v.IsNil() {
+			return types.EMPTY, nil
+		}
+		return v.Interface().(types.Expr), nil
+	}
+	switch v.Type() {
+	case ratType:
+		if v.IsNil() {
+			return types.EMPTY, nil
+		}
+		return types.NewRatio(v.Interface().(*big.Rat)), nil
+	case intType:
+		if v.IsNil() {
+			return types.EMPTY, nil
+		}
+		return types.NewBigInteger(v.Interface().(*big.Int)), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return types.String(v.String()), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return types.T, nil
+		}
+		return types.EMPTY, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return types.NewInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return types.NewBigInteger(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if v.Kind() == reflect.Float32 {
+			//use the shortest decimal that represents the float32, so 0.1 doesn't become 0.10000000149011612
+			f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', -1, 32), 64)
+		}
+		return types.Float(f), nil
+	case reflect.Slice, reflect.Array:
+		out := make([]types.Expr, v.Len())
+		for i := range out {
+			e, err := fromGo(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = e
+		}
+		return sliceToList(out), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return types.EMPTY, nil
+		}
+		return fromGo(v.Elem())
+	case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan:
+		if v.IsNil() {
+			return types.EMPTY, nil
+		}
+	case reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return nil, errors.New("can't convert " + v.Type().String() + " to a Lisp value")
+	}
+	if !v.CanInterface() {
+		return nil, errors.New("can't convert unexported " + v.Type().String() + " to a Lisp value")
+	}
+	return types.GoValue{Value: v.Interface()}, nil
+}
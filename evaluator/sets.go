@@ -0,0 +1,218 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["MAKE-SET"] = makeSet
+	BuiltIn["SET-ADD"] = setAdd
+	BuiltIn["SET-REMOVE"] = setRemove
+	BuiltIn["SET-CONTAINS"] = setContains
+	BuiltIn["UNION"] = union
+	BuiltIn["INTERSECTION"] = intersection
+	BuiltIn["DIFFERENCE"] = difference
+	BuiltIn["SUBSET?"] = subset
+	BuiltIn["SET->LIST"] = setToList
+	BuiltIn["LIST->SET"] = listToSet
+}
+
+func asSet(name string, e types.Expr) (*types.Set, error) {
+	s, ok := e.(*types.Set)
+	if !ok {
+		return nil, fmt.Errorf("%s parameter must be a set", name)
+	}
+	return s, nil
+}
+
+// setParams evaluates the parameters for the named builtin, which must all be sets
+func (in *Interpreter) setParams(name string, t *types.SExpr, env types.Env, minCount, maxCount int) ([]*types.Set, error) {
+	vals, err := in.checkedParams(name, t, env, minCount, maxCount)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*types.Set, len(vals))
+	for i, v := range vals {
+		out[i], err = asSet(name, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// makeSet returns a new set with the parameters as its members
+// (MAKE-SET MEMBER...)
+func makeSet(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MAKE-SET", t, env, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSet(vals)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSet(vals []types.Expr) (*types.Set, error) {
+	s := types.NewSet()
+	for _, v := range vals {
+		if err := s.Add(v); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// setAdd puts MEMBER in the set, and returns the set
+// (SET-ADD SET MEMBER)
+func setAdd(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("SET-ADD", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	s, err := asSet("SET-ADD", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Add(vals[1]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// setRemove takes MEMBER out of the set, and returns the set. It's not an error if MEMBER isn't there.
+// (SET-REMOVE SET MEMBER)
+func setRemove(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("SET-REMOVE", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	s, err := asSet("SET-REMOVE", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	s.Remove(vals[1])
+	return s, nil
+}
+
+// (SET-CONTAINS SET MEMBER)
+func setContains(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("SET-CONTAINS", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	s, err := asSet("SET-CONTAINS", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	return boolToExpr(s.Contains(vals[1])), nil
+}
+
+// union returns a new set with the members that are in any of the sets
+// (UNION SET...)
+func union(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	sets, err := in.setParams("UNION", t, env, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	out := types.NewSet()
+	for _, s := range sets {
+		for _, m := range s.Members() {
+			out.Add(m)
+		}
+	}
+	return out, nil
+}
+
+// intersection returns a new set with the members that are in all of the sets
+// (INTERSECTION SET SET...)
+func intersection(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	sets, err := in.setParams("INTERSECTION", t, env, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	out := sets[0].Copy()
+	for _, m := range out.Members() {
+		for _, s := range sets[1:] {
+			if !s.Contains(m) {
+				out.Remove(m)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// difference returns a new set with the members of the first set that aren't in any of the others
+// (DIFFERENCE SET SET...)
+func difference(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	sets, err := in.setParams("DIFFERENCE", t, env, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	out := sets[0].Copy()
+	for _, s := range sets[1:] {
+		for _, m := range s.Members() {
+			out.Remove(m)
+		}
+	}
+	return out, nil
+}
+
+// subset returns T if every member of the first set is in the second
+// (SUBSET? SET SET)
+func subset(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	sets, err := in.setParams("SUBSET?", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	return boolToExpr(isSubset(sets[0], sets[1])), nil
+}
+
+func isSubset(s, s2 *types.Set) bool {
+	if s.Len() > s2.Len() {
+		return false
+	}
+	for _, m := range s.Members() {
+		if !s2.Contains(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// setToList returns a list of the members of the set, in the order that the set prints them
+// (SET->LIST SET)
+func setToList(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	sets, err := in.setParams("SET->LIST", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return sliceToList(sets[0].Members()), nil
+}
+
+// listToSet returns a new set with the elements of the list as its members. Duplicates are only included once.
+// (LIST->SET LIST)
+func listToSet(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("LIST->SET", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if !isList(vals[0]) {
+		return nil, errors.New("LIST->SET parameter must be a list")
+	}
+	elems, err := listToExprs(vals[0])
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSet(elems)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,214 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["STRING-APPEND"] = stringAppend
+	BuiltIn["SUBSTRING"] = substring
+	BuiltIn["STRING-LENGTH"] = stringLength
+	BuiltIn["STRING-SPLIT"] = stringSplit
+	BuiltIn["STRING-JOIN"] = stringJoin
+	BuiltIn["STRING->SYMBOL"] = stringToSymbol
+	BuiltIn["SYMBOL->STRING"] = symbolToString
+	BuiltIn["NUMBER->STRING"] = numberToString
+	BuiltIn["STRING-UPCASE"] = stringUpcase
+	BuiltIn["STRING-DOWNCASE"] = stringDowncase
+}
+
+func asString(name string, e types.Expr) (string, error) {
+	s, ok := e.(types.String)
+	if !ok {
+		return "", fmt.Errorf("%s parameter must be a string", name)
+	}
+	return string(s), nil
+}
+
+func asInt(name string, e types.Expr) (int, error) {
+	if i, ok := e.(types.Integer); ok {
+		if x, ok := i.Int64(); ok && int64(int(x)) == x {
+			return int(x), nil
+		}
+	}
+	return 0, fmt.Errorf("%s parameter must be an integer", name)
+}
+
+func stringAppend(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRING-APPEND", t, env, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for _, v := range vals {
+		s, err := asString("STRING-APPEND", v)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(s)
+	}
+	return types.String(sb.String()), nil
+}
+
+// (SUBSTRING S START [END])
+// START and END are character positions, not byte positions. If END is left off, the rest of the string is returned.
+func substring(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("SUBSTRING", t, env, 2, 3)
+	if err != nil {
+		return nil, err
+	}
+	s, err := asString("SUBSTRING", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, err := asInt("SUBSTRING", vals[1])
+	if err != nil {
+		return nil, err
+	}
+	end := len(runes)
+	if len(vals) == 3 {
+		end, err = asInt("SUBSTRING", vals[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if start < 0 || end > len(runes) || start > end {
+		return nil, fmt.Errorf("SUBSTRING range %d to %d is out of bounds for a string of length %d", start, end, len(runes))
+	}
+	return types.String(runes[start:end]), nil
+}
+
+func stringLength(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRING-LENGTH", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := asString("STRING-LENGTH", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewInteger(int64(len([]rune(s)))), nil
+}
+
+// (STRING-SPLIT S [SEPARATOR])
+// If there's no separator, the string is split around runs of whitespace.
+func stringSplit(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRING-SPLIT", t, env, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	s, err := asString("STRING-SPLIT", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	var parts []string
+	if len(vals) == 1 {
+		parts = strings.Fields(s)
+	} else {
+		sep, err := asString("STRING-SPLIT", vals[1])
+		if err != nil {
+			return nil, err
+		}
+		parts = strings.Split(s, sep)
+	}
+	out := make([]types.Expr, len(parts))
+	for k, v := range parts {
+		out[k] = types.String(v)
+	}
+	return sliceToList(out), nil
+}
+
+// (STRING-JOIN LIST [SEPARATOR])
+func stringJoin(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRING-JOIN", t, env, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	l, err := listToExprs(vals[0])
+	if err != nil {
+		return nil, errors.New("STRING-JOIN parameter must be a list")
+	}
+	sep := ""
+	if len(vals) == 2 {
+		sep, err = asString("STRING-JOIN", vals[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	parts := make([]string, len(l))
+	for k, v := range l {
+		parts[k], err = asString("STRING-JOIN", v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return types.String(strings.Join(parts, sep)), nil
+}
+
+func stringToSymbol(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRING->SYMBOL", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := asString("STRING->SYMBOL", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	if s == "" {
+		return nil, errors.New("STRING->SYMBOL parameter must not be empty")
+	}
+	return types.Atom(s), nil
+}
+
+func symbolToString(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("SYMBOL->STRING", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := vals[0].(types.Atom)
+	if !ok {
+		return nil, errors.New("SYMBOL->STRING parameter must be a symbol")
+	}
+	return types.String(a), nil
+}
+
+func numberToString(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("NUMBER->STRING", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	n, err := asNumber(vals[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.String(n.String()), nil
+}
+
+func stringUpcase(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRING-UPCASE", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := asString("STRING-UPCASE", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.String(strings.ToUpper(s)), nil
+}
+
+func stringDowncase(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRING-DOWNCASE", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := asString("STRING-DOWNCASE", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.String(strings.ToLower(s)), nil
+}
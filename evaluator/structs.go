@@ -0,0 +1,239 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["DEFSTRUCT"] = defstruct
+	BuiltIn["MAKE-STRUCT"] = makeStruct
+	BuiltIn["STRUCTP"] = structp
+	BuiltIn["STRUCT-NAME"] = structName
+	BuiltIn["STRUCT-GET"] = structGet
+	BuiltIn["STRUCT-SET!"] = structSet
+}
+
+// defstruct defines a record type called NAME with the named fields. A field can be written as (FIELD DEFAULT), where
+// DEFAULT is evaluated to get the value of the field when the constructor isn't given one. It defines:
+//   - MAKE-NAME, the constructor, which takes the values of the fields as &KEY parameters: (MAKE-POINT :X 1 :Y 2)
+//   - NAME-P, which returns T if its parameter is a NAME
+//   - NAME-FIELD for each field, which returns the value of the field. This is why a field can't be named P.
+//   - SET-NAME-FIELD! for each field, which changes the value of the field and returns the struct
+//
+// These are LAMBDAs built out of MAKE-STRUCT, STRUCTP, STRUCT-GET and STRUCT-SET!, so they are saved by STORE like any other function.
+// DEFSTRUCT returns NAME.
+// (DEFSTRUCT NAME FIELD...)
+func defstruct(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	a1, ok := t.Right.(*types.SExpr)
+	if !ok {
+		return nil, errors.New("missing parameters for DEFSTRUCT")
+	}
+	name, ok := a1.Left.(types.Atom)
+	if !ok || name.IsKeyword() {
+		return nil, errors.New("DEFSTRUCT name must be an Atom")
+	}
+	fieldExprs, err := listToExprs(a1.Right)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]types.Param, len(fieldExprs))
+	for i, f := range fieldExprs {
+		fields[i], err = structField(name, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, prev := range fields[:i] {
+			if prev.Name == fields[i].Name {
+				return nil, fmt.Errorf("%s appears more than once in DEFSTRUCT %s", prev.Name, name)
+			}
+		}
+		if fields[i].Name == "P" {
+			return nil, fmt.Errorf("field P in DEFSTRUCT %s would replace the predicate %s-P", name, name)
+		}
+	}
+
+	obj, value := types.Atom("OBJ"), types.Atom("VALUE")
+	makeArgs := []types.Expr{types.Atom("MAKE-STRUCT"), quoted(name)}
+	for _, f := range fields {
+		makeArgs = append(makeArgs, ":"+f.Name, f.Name)
+	}
+	env.Put("MAKE-"+name, types.Lambda{ParentEnv: env, Keys: fields, Body: sliceToList(makeArgs)})
+	env.Put(name+"-P", types.Lambda{
+		ParentEnv: env,
+		Params:    []types.Expr{obj},
+		Body:      sliceToList([]types.Expr{types.Atom("STRUCTP"), obj, quoted(name)}),
+	})
+	for _, f := range fields {
+		env.Put(name+"-"+f.Name, types.Lambda{
+			ParentEnv: env,
+			Params:    []types.Expr{obj},
+			Body:      sliceToList([]types.Expr{types.Atom("STRUCT-GET"), obj, quoted(f.Name), quoted(name)}),
+		})
+		env.Put("SET-"+name+"-"+f.Name+"!", types.Lambda{
+			ParentEnv: env,
+			Params:    []types.Expr{obj, value},
+			Body:      sliceToList([]types.Expr{types.Atom("STRUCT-SET!"), obj, quoted(f.Name), value, quoted(name)}),
+		})
+	}
+	return name, nil
+}
+
+// structField returns the name and default value of a field in a DEFSTRUCT
+func structField(name types.Atom, e types.Expr) (types.Param, error) {
+	switch f := e.(type) {
+	case types.Atom:
+		if !f.IsKeyword() {
+			return types.Param{Name: f}, nil
+		}
+	case *types.SExpr:
+		vals, err := listToExprs(f)
+		if err != nil {
+			return types.Param{}, err
+		}
+		if len(vals) == 2 {
+			if fName, ok := vals[0].(types.Atom); ok && !fName.IsKeyword() {
+				return types.Param{Name: fName, Default: vals[1]}, nil
+			}
+		}
+	}
+	return types.Param{}, fmt.Errorf("fields in DEFSTRUCT %s must be a name or a name and a default value", name)
+}
+
+// fieldName returns the name of a field, which can be written as a symbol or a keyword
+func fieldName(name string, e types.Expr) (types.Atom, error) {
+	f, ok := e.(types.Atom)
+	if !ok {
+		return "", fmt.Errorf("%s field name must be a symbol", name)
+	}
+	if f.IsKeyword() {
+		f = f[1:]
+	}
+	return f, nil
+}
+
+// asStruct returns e as a struct. If typeName is not nil, the struct must be of that type.
+func asStruct(name string, e types.Expr, typeName types.Expr) (*types.Struct, error) {
+	s, ok := e.(*types.Struct)
+	if typeName == nil {
+		if !ok {
+			return nil, fmt.Errorf("%s parameter must be a struct", name)
+		}
+		return s, nil
+	}
+	if !ok || s.Name != typeName {
+		return nil, fmt.Errorf("%s is not a %s", e, typeName)
+	}
+	return s, nil
+}
+
+// makeStruct returns a new struct of type NAME with the fields and values that are passed in
+// (MAKE-STRUCT NAME [:FIELD VALUE]...)
+func makeStruct(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MAKE-STRUCT", t, env, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := vals[0].(types.Atom)
+	if !ok || name.IsKeyword() {
+		return nil, errors.New("MAKE-STRUCT name must be an Atom")
+	}
+	if len(vals)%2 == 0 {
+		return nil, errors.New("MAKE-STRUCT requires a value for every field")
+	}
+	var fields []types.Atom
+	var values []types.Expr
+	for i := 1; i < len(vals); i += 2 {
+		f, err := fieldName("MAKE-STRUCT", vals[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, prev := range fields {
+			if prev == f {
+				return nil, fmt.Errorf("MAKE-STRUCT has the field %s more than once", f)
+			}
+		}
+		fields = append(fields, f)
+		values = append(values, vals[i+1])
+	}
+	return types.NewStruct(name, fields, values), nil
+}
+
+// structp returns T if OBJ is a struct, and if NAME is passed in, a struct of that type
+// (STRUCTP OBJ [NAME])
+func structp(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRUCTP", t, env, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := vals[0].(*types.Struct)
+	return boolToExpr(ok && (len(vals) == 1 || s.Name == vals[1])), nil
+}
+
+// (STRUCT-NAME STRUCT)
+func structName(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRUCT-NAME", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	s, err := asStruct("STRUCT-NAME", vals[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.Name, nil
+}
+
+// structGet returns the value of FIELD. If NAME is passed in, STRUCT must be of that type.
+// (STRUCT-GET STRUCT FIELD [NAME])
+func structGet(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRUCT-GET", t, env, 2, 3)
+	if err != nil {
+		return nil, err
+	}
+	s, f, err := structAndField("STRUCT-GET", vals, 2)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := s.Get(f)
+	if !ok {
+		return nil, fmt.Errorf("%s has no field %s", s.Name, f)
+	}
+	return v, nil
+}
+
+// structSet changes the value of FIELD and returns the struct. If NAME is passed in, STRUCT must be of that type.
+// (STRUCT-SET! STRUCT FIELD VALUE [NAME])
+func structSet(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("STRUCT-SET!", t, env, 3, 4)
+	if err != nil {
+		return nil, err
+	}
+	s, f, err := structAndField("STRUCT-SET!", vals, 3)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Set(f, vals[2]) {
+		return nil, fmt.Errorf("%s has no field %s", s.Name, f)
+	}
+	return s, nil
+}
+
+// structAndField returns the struct and field name at the start of vals. If vals has a value at typeIndex,
+// it's the type that the struct must have.
+func structAndField(name string, vals []types.Expr, typeIndex int) (*types.Struct, types.Atom, error) {
+	var typeName types.Expr
+	if len(vals) > typeIndex {
+		typeName = vals[typeIndex]
+	}
+	s, err := asStruct(name, vals[0], typeName)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := fieldName(name, vals[1])
+	if err != nil {
+		return nil, "", err
+	}
+	return s, f, nil
+}
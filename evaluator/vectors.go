@@ -0,0 +1,204 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+func init() {
+	BuiltIn["VECTOR"] = vector
+	BuiltIn["MAKE-VECTOR"] = makeVector
+	BuiltIn["VECTOR-REF"] = vectorRef
+	BuiltIn["VECTOR-SET!"] = vectorSet
+	BuiltIn["VECTOR-LENGTH"] = vectorLength
+	BuiltIn["VECTOR->LIST"] = vectorToList
+	BuiltIn["LIST->VECTOR"] = listToVector
+	BuiltIn["VECTOR-APPEND"] = vectorAppend
+	BuiltIn["VECTOR-SLICE"] = vectorSlice
+}
+
+func asVector(name string, e types.Expr) (*types.Vector, error) {
+	v, ok := e.(*types.Vector)
+	if !ok {
+		return nil, fmt.Errorf("%s parameter must be a vector", name)
+	}
+	return v, nil
+}
+
+// vector returns a new vector with the parameters as its elements
+// (VECTOR ELEM...)
+func vector(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("VECTOR", t, env, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewVector(vals), nil
+}
+
+// makeVector returns a new vector of length N, with every element set to FILL, or NIL if FILL is left off
+// (MAKE-VECTOR N [FILL])
+func makeVector(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("MAKE-VECTOR", t, env, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	n, err := asInt("MAKE-VECTOR", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("MAKE-VECTOR length %d can't be negative", n)
+	}
+	var fill types.Expr = types.EMPTY
+	if len(vals) == 2 {
+		fill = vals[1]
+	}
+	elems := make([]types.Expr, n)
+	for i := range elems {
+		elems[i] = fill
+	}
+	return types.NewVector(elems), nil
+}
+
+// (VECTOR-REF VECTOR INDEX)
+func vectorRef(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("VECTOR-REF", t, env, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	v, err := asVector("VECTOR-REF", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := asInt("VECTOR-REF", vals[1])
+	if err != nil {
+		return nil, err
+	}
+	e, ok := v.Get(i)
+	if !ok {
+		return nil, fmt.Errorf("VECTOR-REF index %d is out of bounds for a length of %d", i, v.Len())
+	}
+	return e, nil
+}
+
+// vectorSet changes the element at INDEX to VALUE, and returns the vector
+// (VECTOR-SET! VECTOR INDEX VALUE)
+func vectorSet(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("VECTOR-SET!", t, env, 3, 3)
+	if err != nil {
+		return nil, err
+	}
+	v, err := asVector("VECTOR-SET!", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := asInt("VECTOR-SET!", vals[1])
+	if err != nil {
+		return nil, err
+	}
+	if !v.Set(i, vals[2]) {
+		return nil, fmt.Errorf("VECTOR-SET! index %d is out of bounds for a length of %d", i, v.Len())
+	}
+	return v, nil
+}
+
+// (VECTOR-LENGTH VECTOR)
+func vectorLength(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("VECTOR-LENGTH", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	v, err := asVector("VECTOR-LENGTH", vals[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewInteger(int64(v.Len())), nil
+}
+
+// vectorToList returns a list of the elements of the vector from START (or the beginning) up to END (or the end)
+// (VECTOR->LIST VECTOR [START [END]])
+func vectorToList(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	elems, err := in.vectorRange("VECTOR->LIST", t, env, 1)
+	if err != nil {
+		return nil, err
+	}
+	return sliceToList(elems), nil
+}
+
+// (LIST->VECTOR LIST)
+func listToVector(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("LIST->VECTOR", t, env, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if !isList(vals[0]) {
+		return nil, errors.New("LIST->VECTOR parameter must be a list")
+	}
+	elems, err := listToExprs(vals[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewVector(elems), nil
+}
+
+// vectorAppend returns a new vector with the elements of each of the vectors, in order
+// (VECTOR-APPEND VECTOR...)
+func vectorAppend(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	vals, err := in.checkedParams("VECTOR-APPEND", t, env, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	var elems []types.Expr
+	for _, val := range vals {
+		v, err := asVector("VECTOR-APPEND", val)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, v.Elems()...)
+	}
+	return types.NewVector(elems), nil
+}
+
+// vectorSlice returns a new vector with the elements of the vector from START up to END (or the end).
+// Changing the new vector doesn't change the original.
+// (VECTOR-SLICE VECTOR START [END])
+func vectorSlice(in *Interpreter, t *types.SExpr, env types.Env) (types.Expr, error) {
+	elems, err := in.vectorRange("VECTOR-SLICE", t, env, 2)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewVector(elems), nil
+}
+
+// vectorRange evaluates the parameters VECTOR [START [END]] and returns a copy of the elements between START and END.
+// minCount is 1 if START is optional and 2 if it isn't.
+func (in *Interpreter) vectorRange(name string, t *types.SExpr, env types.Env, minCount int) ([]types.Expr, error) {
+	vals, err := in.checkedParams(name, t, env, minCount, 3)
+	if err != nil {
+		return nil, err
+	}
+	v, err := asVector(name, vals[0])
+	if err != nil {
+		return nil, err
+	}
+	elems := v.Elems()
+	start, end := 0, len(elems)
+	if len(vals) > 1 {
+		start, err = asInt(name, vals[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(vals) > 2 {
+		end, err = asInt(name, vals[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if start < 0 || end > len(elems) || start > end {
+		return nil, fmt.Errorf("%s range %d to %d is out of bounds for a vector of length %d", name, start, end, len(elems))
+	}
+	return elems[start:end], nil
+}
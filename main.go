@@ -1,59 +1,50 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 
 	"github.com/jonbodner/my_lisp/evaluator"
-	"github.com/jonbodner/my_lisp/global"
-	"github.com/jonbodner/my_lisp/parser"
-	"github.com/jonbodner/my_lisp/scanner"
-	"github.com/jonbodner/my_lisp/types"
+	"github.com/jonbodner/my_lisp/reader"
 )
 
+// maxFrames is the most calls that are printed in a backtrace
+const maxFrames = 20
+
 func main() {
-	bio := bufio.NewReader(os.Stdin)
-	done := false
-	depth := 0
-	var tokens []types.Token
-	for !done {
-		line, err := bio.ReadString('\n')
+	r := reader.New("", os.Stdin)
+	for {
+		expr, err := r.Read()
+		if err == io.EOF {
+			return
+		}
 		if err != nil {
-			if err != io.EOF {
-				log.Fatal(err)
-			}
-			done = true
+			fmt.Println(err)
 			continue
 		}
-		newTokens, newDepth := scanner.Scan(line)
-		depth = depth + newDepth
-		if depth < 0 {
-			fmt.Println("Invalid -- Too many closing parens")
-			depth = 0
-			tokens = []types.Token{}
-			continue
+		result, err := evaluator.Eval(expr)
+		if err != nil {
+			fmt.Println(err)
+			printBacktrace(err)
+		} else {
+			fmt.Println(result)
 		}
-		tokens = append(tokens, newTokens...)
-		if depth == 0 {
-			//global.Log(tokens)
-			expr, _, err := parser.Parse(tokens)
-			//global.Log(expr)
-			//global.Log(pos)
-			//global.Log(err)
-			if err != nil {
-				global.Log(err)
-			} else {
-				result, err := evaluator.Eval(expr)
-				if err != nil {
-					fmt.Println(err)
-				} else {
-					fmt.Println(result)
-				}
-			}
-			tokens = []types.Token{}
+	}
+}
+
+// printBacktrace prints the calls of the Lisp functions that were running when err happened, innermost first
+func printBacktrace(err error) {
+	var ee *evaluator.EvalError
+	if !errors.As(err, &ee) {
+		return
+	}
+	for i, f := range ee.Trace {
+		if i == maxFrames {
+			fmt.Printf("\t... and %d more\n", len(ee.Trace)-maxFrames)
+			return
 		}
+		fmt.Println("\tin", f)
 	}
 }
@@ -8,27 +8,18 @@ type ParseError struct {
 	pos    int
 }
 
-func (te ParseError) Error() string {
-	out := te.msg
-	tokenPart := te.buildTokenForm()
-	if len(tokenPart) > 0 {
-		out += ": "
-		out += tokenPart
+// Position returns where the error was found, if it's known
+func (te ParseError) Position() types.Pos {
+	if te.pos < len(te.tokens) {
+		return te.tokens[te.pos].Position()
 	}
-	return out
+	return types.Pos{}
 }
 
-func (te ParseError) buildTokenForm() string {
-	out := ""
-	for i := 0; i < len(te.tokens); i++ {
-		if i == te.pos {
-			out += "_"
-		}
-		out += te.tokens[i].TokenForm()
-		if i == te.pos {
-			out += "_"
-		}
-		out += " "
+// Error returns the message, after the position of the token where the error was found if it's known
+func (te ParseError) Error() string {
+	if pos := te.Position(); pos.IsValid() {
+		return pos.String() + ": " + te.msg
 	}
-	return out
+	return te.msg
 }
@@ -6,6 +6,21 @@ func Parse(tokens []types.Token) (types.Expr, int, error) {
 	return parseInner(tokens)
 }
 
+// ParseAll parses every expression in tokens and returns them in order.
+// If one of them can't be parsed, it returns the expressions before it along with the error.
+func ParseAll(tokens []types.Token) ([]types.Expr, error) {
+	var out []types.Expr
+	for len(tokens) > 0 {
+		expr, pos, err := parseInner(tokens)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, expr)
+		tokens = tokens[pos:]
+	}
+	return out, nil
+}
+
 func parseInner(tokens []types.Token) (types.Expr, int, error) {
 	//fmt.Println("incoming tokens:",tokens)
 	if len(tokens) == 0 {
@@ -15,18 +30,45 @@ func parseInner(tokens []types.Token) (types.Expr, int, error) {
 	switch t := token.(type) {
 	case types.NAME:
 		//name by itself is a complete expression, so return
-		out := types.Atom(t)
+		//names that are written like numbers are numbers
+		if n, ok := types.ParseNumber(t.Text); ok {
+			return n, 1, nil
+		}
+		out := types.Atom(t.Text)
 		return out, 1, nil
+	case types.STRING:
+		//so is a string
+		return types.String(t.Value), 1, nil
+	case types.Invalid:
+		//this is an error
+		return nil, 0, ParseError{t.Msg, tokens, 0}
 	case types.RParen:
 		//this is an error
 		return nil, 0, ParseError{"Right paren in unexpected location", tokens, 0}
+	case types.RBrace:
+		//this is an error
+		return nil, 0, ParseError{"Right brace in unexpected location", tokens, 0}
 	case types.Dot:
 		//this is an error
 		return nil, 0, ParseError{"Dot in unexpected location", tokens, 0}
-	case types.Quote:
-		//"reader macro" -- turns 'EXPR into (QUOTE EXPR)
+	case types.RBracket:
+		//this is an error
+		return nil, 0, ParseError{"Right bracket in unexpected location", tokens, 0}
+	case types.LBrace:
+		return parseMap(tokens)
+	case types.LBracket:
+		return parseVector(tokens, isRBracket, "Left bracket without matching right bracket")
+	case types.HashParen:
+		return parseVector(tokens, isRParen, "#( without matching right paren")
+	case types.HashBrace:
+		return parseSet(tokens)
+	case types.HashS:
+		return parseStruct(tokens)
+	case types.Quote, types.Backquote, types.Comma, types.CommaAt:
+		//"reader macro" -- turns 'EXPR into (QUOTE EXPR), `EXPR into (QUASIQUOTE EXPR),
+		//,EXPR into (UNQUOTE EXPR) and ,@EXPR into (UNQUOTE-SPLICING EXPR)
 		quoted := &types.SExpr{Left: types.NIL, Right: types.NIL}
-		out := &types.SExpr{Left: types.Atom("QUOTE"), Right: quoted}
+		out := &types.SExpr{Left: readerMacroName(t), Right: quoted, LeftSpan: spanOf(tokens, 0, 0)}
 		nested, remaining, err := parseInner(tokens[1:])
 		if err != nil {
 			if pe, ok := err.(ParseError); ok {
@@ -37,12 +79,22 @@ func parseInner(tokens []types.Token) (types.Expr, int, error) {
 			return nil, remaining + 1, err
 		}
 		quoted.Left = nested
+		quoted.Span = spanOf(tokens, 1, remaining)
+		quoted.LeftSpan = quoted.Span
+		out.Span = spanOf(tokens, 0, remaining)
 		return out, remaining + 1, nil
 	case types.LParen:
-		out := &types.SExpr{Left: types.NIL, Right: types.NIL}
+		out := &types.SExpr{Left: types.NIL, Right: types.NIL, Span: &types.Span{Start: t.Pos}}
 		cur := out
 		pos := 1
 		dotted := false
+		cells := []*types.SExpr{out}
+		//closeList sets the end of the span of every cell in the list to the position of the right paren
+		closeList := func() {
+			for _, c := range cells {
+				c.Span.End = tokens[pos].Position()
+			}
+		}
 		for {
 			//fmt.Println("pos == ",pos)
 			// if no more tokens, error
@@ -50,10 +102,15 @@ func parseInner(tokens []types.Token) (types.Expr, int, error) {
 				return nil, len(tokens), ParseError{"Left paren without matching right paren", tokens, 0}
 			}
 			// if the next token is RPAREN, we're done
-			if tokens[pos] == types.RPAREN {
+			if _, ok := tokens[pos].(types.RParen); ok {
+				closeList()
 				return out, pos + 1, nil
 			}
+			if dotted {
+				return nil, pos, ParseError{"More than one value to the right of the dot in a dotted pair", tokens, pos}
+			}
 			//otherwise, recurse for the left value of the SExpr
+			leftStart := pos
 			left, nextToken, err := parseInner(tokens[pos:])
 			pos += nextToken
 			if err != nil {
@@ -66,13 +123,21 @@ func parseInner(tokens []types.Token) (types.Expr, int, error) {
 			}
 			//fmt.Println("got left value ",left, "to add to ", cur)
 			cur.Left = left
+			cur.LeftSpan = spanOf(tokens, leftStart, pos-1)
+			if cur != out {
+				cur.Span = &types.Span{Start: cur.LeftSpan.Start}
+			}
+			if len(tokens) == pos {
+				return nil, len(tokens), ParseError{"Left paren without matching right paren", tokens, 0}
+			}
 			//if the next token is RPAREN, we're done
-			if tokens[pos] == types.RPAREN {
+			if _, ok := tokens[pos].(types.RParen); ok {
 				//fmt.Println("No right value -- done", out)
+				closeList()
 				return out, pos + 1, nil
 			}
 			//if the next token is a dot
-			if tokens[pos] == types.DOT {
+			if _, ok := tokens[pos].(types.Dot); ok {
 				if dotted {
 					return nil, pos, ParseError{"More than one dot in a dotted pair", tokens, pos}
 				}
@@ -89,6 +154,9 @@ func parseInner(tokens []types.Token) (types.Expr, int, error) {
 					return nil, pos, err
 				}
 				cur.Right = right
+				if len(tokens) == pos {
+					return nil, len(tokens), ParseError{"Left paren without matching right paren", tokens, 0}
+				}
 			} else {
 				if dotted {
 					return nil, pos, ParseError{"More than one value to the right of the dot in a dotted pair", tokens, pos}
@@ -97,9 +165,153 @@ func parseInner(tokens []types.Token) (types.Expr, int, error) {
 				right := &types.SExpr{Left: types.NIL, Right: types.NIL}
 				cur.Right = right
 				cur = right
+				cells = append(cells, cur)
 			}
 		}
 
 	}
 	return nil, 0, ParseError{"Unexpected Token found -- not processed!", tokens, 0}
 }
+
+// parseMap parses a map literal, {KEY VALUE ...}. The keys and values are read as data, the way the
+// elements of a quoted list are, so the map is complete when it has been read.
+func parseMap(tokens []types.Token) (types.Expr, int, error) {
+	elems, starts, pos, err := parseElements(tokens, isRBrace, "Left brace without matching right brace")
+	if err != nil {
+		return nil, pos, err
+	}
+	if len(elems)%2 != 0 {
+		return nil, pos, ParseError{"Map literal has a key without a value", tokens, pos - 1}
+	}
+	out := types.NewMap().Transient()
+	for i := 0; i < len(elems); i += 2 {
+		if err := out.Put(elems[i], elems[i+1]); err != nil {
+			return nil, pos, ParseError{err.Error(), tokens, starts[i]}
+		}
+	}
+	m, err := out.Persistent()
+	return m, pos, err
+}
+
+// parseVector parses a vector literal, [ELEM ...] or #(ELEM ...). isEnd reports whether a token closes the vector,
+// and unclosed is the error message when it isn't closed. Like the keys and values of a map literal, the elements are read as data.
+func parseVector(tokens []types.Token, isEnd func(types.Token) bool, unclosed string) (types.Expr, int, error) {
+	elems, _, pos, err := parseElements(tokens, isEnd, unclosed)
+	if err != nil {
+		return nil, pos, err
+	}
+	return types.NewVector(elems), pos, nil
+}
+
+// parseSet parses a set literal, #{MEMBER ...}. Like the elements of a vector literal, the members are read as data.
+func parseSet(tokens []types.Token) (types.Expr, int, error) {
+	elems, starts, pos, err := parseElements(tokens, isRBrace, "#{ without matching right brace")
+	if err != nil {
+		return nil, pos, err
+	}
+	out := types.NewSet()
+	for i, e := range elems {
+		if err := out.Add(e); err != nil {
+			return nil, pos, ParseError{err.Error(), tokens, starts[i]}
+		}
+	}
+	return out, pos, nil
+}
+
+// parseStruct parses a struct literal, #S(NAME :FIELD VALUE ...). Like the values in a map literal, the
+// values of the fields are read as data.
+func parseStruct(tokens []types.Token) (types.Expr, int, error) {
+	elems, starts, pos, err := parseElements(tokens, isRParen, "#S( without matching right paren")
+	if err != nil {
+		return nil, pos, err
+	}
+	if len(elems) == 0 {
+		return nil, pos, ParseError{"Struct literal must start with a name", tokens, pos - 1}
+	}
+	name, ok := elems[0].(types.Atom)
+	if !ok || name.IsKeyword() {
+		return nil, pos, ParseError{"Struct literal must start with a name", tokens, starts[0]}
+	}
+	if len(elems)%2 == 0 {
+		return nil, pos, ParseError{"Struct literal has a field without a value", tokens, pos - 1}
+	}
+	var fields []types.Atom
+	var values []types.Expr
+	for i := 1; i < len(elems); i += 2 {
+		f, ok := elems[i].(types.Atom)
+		if !ok || !f.IsKeyword() {
+			return nil, pos, ParseError{"Struct literal field names must be keywords", tokens, starts[i]}
+		}
+		for _, prev := range fields {
+			if prev == f[1:] {
+				return nil, pos, ParseError{"Struct literal has the field " + string(f) + " more than once", tokens, starts[i]}
+			}
+		}
+		fields = append(fields, f[1:])
+		values = append(values, elems[i+1])
+	}
+	return types.NewStruct(name, fields, values), pos, nil
+}
+
+// parseElements parses the expressions after the token that opens a map, vector or set literal, up to the
+// token that closes it. It returns the expressions, the index of the first token of each one, and the number of tokens used.
+func parseElements(tokens []types.Token, isEnd func(types.Token) bool, unclosed string) ([]types.Expr, []int, int, error) {
+	var elems []types.Expr
+	var starts []int
+	pos := 1
+	for {
+		if len(tokens) == pos {
+			return nil, nil, len(tokens), ParseError{unclosed, tokens, 0}
+		}
+		if isEnd(tokens[pos]) {
+			return elems, starts, pos + 1, nil
+		}
+		start := pos
+		e, nextToken, err := parseInner(tokens[pos:])
+		pos += nextToken
+		if err != nil {
+			if pe, ok := err.(ParseError); ok {
+				pe.pos = pos
+				pe.tokens = tokens
+				err = pe
+			}
+			return nil, nil, pos, err
+		}
+		elems = append(elems, e)
+		starts = append(starts, start)
+	}
+}
+
+func isRBrace(t types.Token) bool {
+	_, ok := t.(types.RBrace)
+	return ok
+}
+
+func isRBracket(t types.Token) bool {
+	_, ok := t.(types.RBracket)
+	return ok
+}
+
+func isRParen(t types.Token) bool {
+	_, ok := t.(types.RParen)
+	return ok
+}
+
+// readerMacroName returns the name of the special form that a reader macro token expands into
+func readerMacroName(t types.Token) types.Atom {
+	switch t.(type) {
+	case types.Backquote:
+		return "QUASIQUOTE"
+	case types.Comma:
+		return "UNQUOTE"
+	case types.CommaAt:
+		return "UNQUOTE-SPLICING"
+	default:
+		return "QUOTE"
+	}
+}
+
+// spanOf returns the span from the token at first to the token at last
+func spanOf(tokens []types.Token, first, last int) *types.Span {
+	return &types.Span{Start: tokens[first].Position(), End: tokens[last].Position()}
+}
@@ -22,6 +22,23 @@ func TestParserAtom(t *testing.T) {
 	a.Equals("expected an atom", types.Atom("hello"), expr)
 }
 
+func TestParserNumbers(t *testing.T) {
+	a := assert.Assert{T: t}
+	expr, _, err := getExpression("12")
+	a.Nil("err should not have a value", err)
+	a.Equals("expected an integer", types.NewInteger(12), expr)
+	expr, _, err = getExpression("1/2")
+	a.Nil("err should not have a value", err)
+	_, ok := expr.(types.Ratio)
+	a.True("expected a ratio", ok)
+	expr, _, err = getExpression("1.5")
+	a.Nil("err should not have a value", err)
+	a.Equals("expected a float", types.Float(1.5), expr)
+	expr, _, err = getExpression("1+")
+	a.Nil("err should not have a value", err)
+	a.Equals("expected an atom", types.Atom("1+"), expr)
+}
+
 func TestParserEmptyList(t *testing.T) {
 	a := assert.Assert{T: t}
 	expr, _, err := getExpression("()")
@@ -38,21 +55,31 @@ func TestBadLeftDottedPair(t *testing.T) {
 	a := assert.Assert{T: t}
 	_, _, err := getExpression("( . b)")
 	a.NotNil("err should have a value", err)
-	a.Equals("wrong error message", "Dot in unexpected location: ( _._ b ) ", err.Error())
+	a.Equals("wrong error message", "1:3: Dot in unexpected location", err.Error())
 }
 
 func TestBadRightDottedPair(t *testing.T) {
 	a := assert.Assert{T: t}
 	_, _, err := getExpression("(a . )")
 	a.NotNil("err should have a value", err)
-	a.Equals("wrong error message", "Right paren in unexpected location: ( a . _)_ ", err.Error())
+	a.Equals("wrong error message", "1:6: Right paren in unexpected location", err.Error())
 }
 
 func TestBadEmptyDottedPair(t *testing.T) {
 	a := assert.Assert{T: t}
 	_, _, err := getExpression("( . )")
 	a.NotNil("err should have a value", err)
-	a.Equals("wrong error message", "Dot in unexpected location: ( _._ ) ", err.Error())
+	a.Equals("wrong error message", "1:3: Dot in unexpected location", err.Error())
+}
+
+func TestUnclosedList(t *testing.T) {
+	a := assert.Assert{T: t}
+	_, _, err := getExpression("(a b")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:1: Left paren without matching right paren", err.Error())
+	_, _, err = getExpression("(a . b")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:1: Left paren without matching right paren", err.Error())
 }
 
 func TestGoodSimpleDottedPair(t *testing.T) {
@@ -98,6 +125,177 @@ func TestQuoteNested(t *testing.T) {
 	a.Nil("err should not have a value", err)
 }
 
+func TestQuasiquote(t *testing.T) {
+	a := assert.Assert{T: t}
+	expr, _, err := getExpression("`(a ,b ,@(c d))")
+	a.Nil("err should not have a value", err)
+	a.Equals("wrong expansion", "(QUASIQUOTE (a (UNQUOTE b) (UNQUOTE-SPLICING (c d))))", expr.String())
+}
+
+func TestParserString(t *testing.T) {
+	a := assert.Assert{T: t}
+	expr, _, err := getExpression(`(a "hello world" "\"quoted\"")`)
+	a.Nil("err should not have a value", err)
+	a.Equals("wrong string", `(a "hello world" "\"quoted\"")`, expr.String())
+	s := expr.(*types.SExpr).Right.(*types.SExpr).Left
+	a.Equals("should be a string", types.String("hello world"), s)
+}
+
+func TestParserBadString(t *testing.T) {
+	a := assert.Assert{T: t}
+	_, _, err := getExpression(`(a "oops)`)
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", `1:4: String without closing double quote`, err.Error())
+}
+
+func TestParserSpans(t *testing.T) {
+	a := assert.Assert{T: t}
+	tokens, _ := scanner.ScanFrom(types.Pos{File: "f.lisp", Line: 1, Col: 1}, "(CAR\n  '(A B)\n  X)")
+	expr, _, err := Parse(tokens)
+	a.Nil("err should not have a value", err)
+	s, ok := expr.(*types.SExpr)
+	a.True("should be an SExpr", ok)
+	a.Equals("list span", "f.lisp:1:1-f.lisp:3:4", s.Span.String())
+	a.Equals("CAR span", "f.lisp:1:2-f.lisp:1:2", s.LeftSpan.String())
+	second := s.Right.(*types.SExpr)
+	a.Equals("quoted list span", "f.lisp:2:3-f.lisp:2:8", second.LeftSpan.String())
+	a.Equals("rest of list span", "f.lisp:2:3-f.lisp:3:4", second.Span.String())
+	quoted := second.Left.(*types.SExpr)
+	a.Equals("QUOTE span", "f.lisp:2:3-f.lisp:2:3", quoted.LeftSpan.String())
+	inner := quoted.Right.(*types.SExpr)
+	a.Equals("quoted value span", "f.lisp:2:4-f.lisp:2:8", inner.LeftSpan.String())
+	innerList := inner.Left.(*types.SExpr)
+	a.Equals("B span", "f.lisp:2:7-f.lisp:2:7", innerList.Right.(*types.SExpr).LeftSpan.String())
+	third := second.Right.(*types.SExpr)
+	a.Equals("X span", "f.lisp:3:3-f.lisp:3:3", third.LeftSpan.String())
+}
+
+func TestParserErrorPosition(t *testing.T) {
+	a := assert.Assert{T: t}
+	tokens, _ := scanner.ScanFrom(types.Pos{File: "f.lisp", Line: 10, Col: 1}, "(A\n  (B . C D))")
+	_, _, err := Parse(tokens)
+	a.NotNil("err should have a value", err)
+	pe, ok := err.(ParseError)
+	a.True("should be a ParseError", ok)
+	a.Equals("wrong position", "f.lisp:11:10", pe.Position().String())
+}
+
+func TestParseAll(t *testing.T) {
+	a := assert.Assert{T: t}
+	tokens, _ := scanner.Scan("(SETQ A 1) (SETQ B 2) C 'D")
+	exprs, err := ParseAll(tokens)
+	a.Nil("err should not have a value", err)
+	a.Equals("wrong expressions", "[(SETQ A 1) (SETQ B 2) C (QUOTE D)]", fmt.Sprint(exprs))
+
+	exprs, err = ParseAll(nil)
+	a.Nil("err should not have a value", err)
+	a.Equals("should have no expressions", 0, len(exprs))
+
+	tokens, _ = scanner.Scan("A (B) (C")
+	exprs, err = ParseAll(tokens)
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error", "1:7: Left paren without matching right paren", err.Error())
+	a.Equals("should return the expressions before the error", "[A (B)]", fmt.Sprint(exprs))
+}
+
+func TestParserMap(t *testing.T) {
+	a := assert.Assert{T: t}
+	expr, pos, err := getExpression(`{:B (1 2) :A "x"} C`)
+	a.Nil("err should not have a value", err)
+	a.Equals("wrong number of tokens", 9, pos)
+	m, ok := expr.(*types.Map)
+	a.True("should be a Map", ok)
+	a.Equals("wrong map", `{:A "x" :B (1 2)}`, m.String())
+
+	_, _, err = getExpression("{:A 1 :B}")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:9: Map literal has a key without a value", err.Error())
+
+	_, _, err = getExpression("{:A 1")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:1: Left brace without matching right brace", err.Error())
+
+	_, _, err = getExpression("{{} 1}")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:2: {} can't be used as a map key", err.Error())
+
+	_, _, err = getExpression("(A })")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:4: Right brace in unexpected location", err.Error())
+}
+
+func TestParserVector(t *testing.T) {
+	a := assert.Assert{T: t}
+	expr, pos, err := getExpression(`[A (1 2) "x"] C`)
+	a.Nil("err should not have a value", err)
+	a.Equals("wrong number of tokens", 8, pos)
+	v, ok := expr.(*types.Vector)
+	a.True("should be a Vector", ok)
+	a.Equals("wrong vector", `[A (1 2) "x"]`, v.String())
+
+	expr, _, err = getExpression("#(A #(B))")
+	a.Nil("err should not have a value", err)
+	a.Equals("wrong vector", "[A [B]]", expr.String())
+
+	_, _, err = getExpression("[A (B]")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:6: Right bracket in unexpected location", err.Error())
+
+	_, _, err = getExpression("#(A")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:1: #( without matching right paren", err.Error())
+
+	_, _, err = getExpression("[A")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:1: Left bracket without matching right bracket", err.Error())
+}
+
+func TestParserSet(t *testing.T) {
+	a := assert.Assert{T: t}
+	expr, pos, err := getExpression(`#{B 2/4 1/2 (1 2)} C`)
+	a.Nil("err should not have a value", err)
+	a.Equals("wrong number of tokens", 9, pos)
+	s, ok := expr.(*types.Set)
+	a.True("should be a Set", ok)
+	a.Equals("wrong set", "#{1/2 B (1 2)}", s.String())
+
+	_, _, err = getExpression("#{A")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:1: #{ without matching right brace", err.Error())
+
+	_, _, err = getExpression("#{A [B]}")
+	a.NotNil("err should have a value", err)
+	a.Equals("wrong error message", "1:5: [B] can't be a member of a set", err.Error())
+}
+
+func TestParserStruct(t *testing.T) {
+	a := assert.Assert{T: t}
+	expr, pos, err := getExpression(`#S(POINT :X (1 2) :Y "y") C`)
+	a.Nil("err should not have a value", err)
+	a.Equals("wrong number of tokens", 10, pos)
+	s, ok := expr.(*types.Struct)
+	a.True("should be a Struct", ok)
+	a.Equals("wrong struct", `#S(POINT :X (1 2) :Y "y")`, s.String())
+	a.Equals("wrong fields", "[X Y]", fmt.Sprint(s.Fields()))
+
+	data := []struct {
+		in       string
+		expected string
+	}{
+		{"#S()", "1:4: Struct literal must start with a name"},
+		{"#S(:X 1)", "1:4: Struct literal must start with a name"},
+		{"#S(P :X)", "1:8: Struct literal has a field without a value"},
+		{"#S(P X 1)", "1:6: Struct literal field names must be keywords"},
+		{"#S(P :X 1 :X 2)", "1:11: Struct literal has the field :X more than once"},
+		{"#S(P :X 1", "1:1: #S( without matching right paren"},
+	}
+	for _, d := range data {
+		_, _, err = getExpression(d.in)
+		a.NotNil("err should have a value", err)
+		a.Equals("wrong error message", d.expected, err.Error())
+	}
+}
+
 func getExpression(in string) (types.Expr, int, error) {
 	tokens, _ := scanner.Scan(in)
 	expression, pos, err := Parse(tokens)
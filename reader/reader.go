@@ -0,0 +1,76 @@
+// Package reader reads Lisp expressions from an io.Reader, one at a time.
+package reader
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/jonbodner/my_lisp/parser"
+	"github.com/jonbodner/my_lisp/scanner"
+	"github.com/jonbodner/my_lisp/types"
+)
+
+// Reader reads expressions from an io.Reader. Expressions can be split across lines,
+// and a line can hold more than one expression.
+type Reader struct {
+	in     *bufio.Reader
+	name   string
+	line   int
+	tokens []types.Token
+	done   bool
+}
+
+// New returns a Reader for r. name is the file name used in the positions of the expressions
+// that are read; it can be empty.
+func New(name string, r io.Reader) *Reader {
+	return &Reader{in: bufio.NewReader(r), name: name}
+}
+
+// Read returns the next expression. It returns io.EOF when there are no more expressions.
+// If an expression can't be parsed, Read returns the error and skips the rest of the text that
+// was read along with the bad expression, so that the next call to Read starts on a new line.
+func (r *Reader) Read() (types.Expr, error) {
+	for len(r.tokens) == 0 {
+		if r.done {
+			return nil, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			return nil, err
+		}
+	}
+	expr, n, err := parser.Parse(r.tokens)
+	if err != nil {
+		r.tokens = nil
+		return nil, err
+	}
+	r.tokens = r.tokens[n:]
+	return expr, nil
+}
+
+// fill reads lines until the text that has been read doesn't end in the middle of an expression,
+// and then sets the tokens. Each line is scanned once, when it's read; the Scanner remembers when a line ends
+// inside of a comment or string, so they can span lines.
+// If the input ends in the middle of an expression, the tokens are incomplete and the parser reports what is missing.
+func (r *Reader) fill() error {
+	sc := scanner.NewScanner(types.Pos{File: r.name, Line: r.line + 1, Col: 1})
+	for !r.done {
+		line, err := r.in.ReadString('\n')
+		if err != nil {
+			//the last line might not end with a newline
+			r.done = true
+			if err != io.EOF {
+				return err
+			}
+		}
+		if line == "" {
+			continue
+		}
+		r.line++
+		sc.Scan(line)
+		if !sc.NeedsMore() {
+			break
+		}
+	}
+	r.tokens, _ = sc.Tokens()
+	return nil
+}
@@ -0,0 +1,120 @@
+package reader
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/jonbodner/my_lisp/types"
+)
+
+// readAll reads every expression from s, and returns them along with the errors, in order
+func readAll(s string) []string {
+	r := New("", iotest.OneByteReader(strings.NewReader(s)))
+	var out []string
+	for {
+		expr, err := r.Read()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			out = append(out, "error: "+err.Error())
+			continue
+		}
+		out = append(out, expr.String())
+	}
+}
+
+func TestReader(t *testing.T) {
+	data := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"empty", "", nil},
+		{"blank lines", "\n\n  \n", nil},
+		{"one per line", "(A B)\nC\n", []string{"(A B)", "C"}},
+		{"several per line", "(SETQ A 1) (SETQ B 2) C\n", []string{"(SETQ A 1)", "(SETQ B 2)", "C"}},
+		{"split across lines", "(A\n  (B\n C)) (D\n)", []string{"(A (B C))", "(D)"}},
+		{"no final newline", "A B", []string{"A", "B"}},
+		{"quote at end of line", "'\n(A B) `\n,\nC", []string{"(QUOTE (A B))", "(QUASIQUOTE (UNQUOTE C))"}},
+		{"string across lines", "(A \"b\n(c\") D", []string{`(A "b\n(c")`, "D"}},
+		{"comments", "; (\n(A #| ) \n |# B) #;\n(C)\nD", []string{"(A B)", "D"}},
+		{"unclosed list", "A (B\n", []string{"A", "error: 1:3: Left paren without matching right paren"}},
+		{"too many right parens", "(A)) B\nC", []string{"(A)", "error: 1:4: Right paren in unexpected location", "C"}},
+		{"bad dot", "(. A) B\n(C)", []string{"error: 1:2: Dot in unexpected location", "(C)"}},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			out := readAll(d.input)
+			if strings.Join(out, "|") != strings.Join(d.expected, "|") {
+				t.Errorf("Expected %q, got %q", d.expected, out)
+			}
+		})
+	}
+}
+
+func TestReaderPositions(t *testing.T) {
+	r := New("f.lisp", strings.NewReader("A\n\n(B\n  C) (D)"))
+	var spans []string
+	for {
+		expr, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, ok := expr.(*types.SExpr); ok {
+			spans = append(spans, s.Span.String())
+		}
+	}
+	expected := "f.lisp:3:1-f.lisp:4:4|f.lisp:4:6-f.lisp:4:8"
+	if strings.Join(spans, "|") != expected {
+		t.Errorf("Expected %s, got %s", expected, strings.Join(spans, "|"))
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("broken")
+}
+
+func TestReaderError(t *testing.T) {
+	r := New("", io.MultiReader(strings.NewReader("(A)\n"), errReader{}))
+	expr, err := r.Read()
+	if err != nil || expr.String() != "(A)" {
+		t.Fatalf("Expected (A), got %v and %v", expr, err)
+	}
+	if _, err = r.Read(); err == nil || err.Error() != "broken" {
+		t.Errorf("Expected broken, got %v", err)
+	}
+	if _, err = r.Read(); err != io.EOF {
+		t.Errorf("Expected io.EOF after an error, got %v", err)
+	}
+}
+
+func TestReaderLongForm(t *testing.T) {
+	//each line of a form is only scanned once, so a form with many lines doesn't take quadratic time
+	const n = 50000
+	var sb strings.Builder
+	sb.WriteString("(LIST\n")
+	for i := 0; i < n; i++ {
+		sb.WriteString("  \"a string\" ; with a comment\n")
+	}
+	sb.WriteString(") B\n")
+	r := New("", strings.NewReader(sb.String()))
+	expr, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := expr.(*types.SExpr); !ok || s.Span.End.Line != n+2 {
+		t.Errorf("Expected a list that ends on line %d, got %v", n+2, expr)
+	}
+	if expr, err = r.Read(); err != nil || expr.String() != "B" {
+		t.Errorf("Expected B, got %v and %v", expr, err)
+	}
+}
@@ -1,44 +1,399 @@
 package scanner
 
-import "github.com/jonbodner/my_lisp/types"
+import (
+	"strconv"
+	"strings"
 
+	"github.com/jonbodner/my_lisp/types"
+)
+
+// Scan splits s into tokens. It also returns how many more left parens than right parens were found.
+// Braces, which surround map literals, brackets and #(, which start vector literals, #{, which starts
+// set literals, and #S(, which starts struct literals, count as parens.
+// Token positions start at line 1, column 1, with no file name.
+//
+// Comments are skipped: a ; comments out the rest of the line, #| ... |# comments out everything
+// between them (block comments can be nested), and #; comments out the next complete expression.
+// Parens inside of comments don't affect the depth. A block comment that isn't closed, or a #; that
+// isn't followed by an expression, counts as one more left paren, so code that reads a line at a time
+// knows to read more before parsing. Since a comment can span lines, that code should use a Scanner,
+// which remembers where the earlier lines left off, rather than scanning one line at a time.
 func Scan(s string) ([]types.Token, int) {
-	var out []types.Token
-	var curTokenTxt []rune
-	buildCurToken := func() {
-		if len(curTokenTxt) > 0 {
-			if len(curTokenTxt) == 1 && curTokenTxt[0] == '.' {
-				out = append(out, types.DOT)
-			} else {
-				out = append(out, types.NAME(curTokenTxt))
-			}
-			curTokenTxt = make([]rune, 0)
+	return ScanFrom(types.Pos{Line: 1, Col: 1}, s)
+}
+
+// ScanFrom works like Scan, but the first rune of s is at start.
+func ScanFrom(start types.Pos, s string) ([]types.Token, int) {
+	sc := NewScanner(start)
+	sc.Scan(s)
+	return sc.Tokens()
+}
+
+// Scanner scans text that is read a piece at a time, like the lines of a file. It keeps the tokens that it has
+// found, and remembers when a piece ends inside of a string or a block comment, so each piece is only scanned once.
+// A piece must end at the end of a line or at the end of the input, so that names and comments that start
+// with ; don't continue into the next piece.
+type Scanner struct {
+	// pos is the position of the next rune
+	pos types.Pos
+	// tokens are the tokens found so far, including datum comments
+	tokens []types.Token
+	depth  int
+
+	curTokenTxt []rune
+	curTokenPos types.Pos
+
+	// commentNesting is how many block comments are open
+	commentNesting int
+	// inString is true when a string literal has been started and not finished
+	inString bool
+	str      partialString
+}
+
+// partialString is a string literal that the Scanner is in the middle of
+type partialString struct {
+	pos    types.Pos
+	text   []rune
+	sb     strings.Builder
+	errMsg string
+}
+
+// NewScanner returns a Scanner whose first rune is at start
+func NewScanner(start types.Pos) *Scanner {
+	return &Scanner{pos: start}
+}
+
+func (s *Scanner) buildCurToken() {
+	if len(s.curTokenTxt) > 0 {
+		if len(s.curTokenTxt) == 1 && s.curTokenTxt[0] == '.' {
+			s.tokens = append(s.tokens, types.Dot{Pos: s.curTokenPos})
+		} else {
+			s.tokens = append(s.tokens, types.NAME{Text: string(s.curTokenTxt), Pos: s.curTokenPos})
 		}
+		s.curTokenTxt = make([]rune, 0)
 	}
-	update := func(t types.Token) {
-		buildCurToken()
-		out = append(out, t)
+}
+
+func (s *Scanner) update(t types.Token) {
+	s.buildCurToken()
+	s.tokens = append(s.tokens, t)
+}
+
+func (s *Scanner) addRune(c rune, pos types.Pos) {
+	if len(s.curTokenTxt) == 0 {
+		s.curTokenPos = pos
 	}
+	s.curTokenTxt = append(s.curTokenTxt, c)
+}
 
-	depth := 0
-	for _, c := range s {
+// Scan adds the tokens in text, which follows the text that has already been scanned
+func (s *Scanner) Scan(text string) {
+	runes := []rune(text)
+	i := 0
+	//finish the string or block comment that the last piece ended in
+	switch {
+	case s.inString:
+		i = s.scanString(runes, 0)
+	case s.commentNesting > 0:
+		i = s.scanBlockComment(runes, 0)
+	default:
+		i = -1
+	}
+	if i >= 0 {
+		s.pos = advance(s.pos, runes[:i+1])
+	}
+	//s.pos is the position of runes[i]
+	for i++; i < len(runes); i++ {
+		c := runes[i]
 		switch c {
 		case '(':
-			update(types.LPAREN)
-			depth++
+			s.update(types.LParen{Pos: s.pos})
+			s.depth++
 		case ')':
-			update(types.RPAREN)
-			depth--
-		case '.':
-			curTokenTxt = append(curTokenTxt, c)
+			s.update(types.RParen{Pos: s.pos})
+			s.depth--
+		case '{':
+			s.update(types.LBrace{Pos: s.pos})
+			s.depth++
+		case '}':
+			s.update(types.RBrace{Pos: s.pos})
+			s.depth--
+		case '[':
+			s.update(types.LBracket{Pos: s.pos})
+			s.depth++
+		case ']':
+			s.update(types.RBracket{Pos: s.pos})
+			s.depth--
 		case '\n', '\r', '\t', ' ':
-			buildCurToken()
+			s.buildCurToken()
+		case '"':
+			s.buildCurToken()
+			s.inString = true
+			s.str = partialString{pos: s.pos, text: []rune{'"'}}
+			end := s.scanString(runes, i+1)
+			s.pos = advance(s.pos, runes[i:end])
+			i = end
 		case '\'':
-			update(types.QUOTE)
+			s.update(types.Quote{Pos: s.pos})
+		case '`':
+			s.update(types.Backquote{Pos: s.pos})
+		case ',':
+			if i+1 < len(runes) && runes[i+1] == '@' {
+				s.update(types.CommaAt{Pos: s.pos})
+				s.pos.Col++
+				i++
+			} else {
+				s.update(types.Comma{Pos: s.pos})
+			}
+		case ';':
+			s.buildCurToken()
+			end := i
+			for end+1 < len(runes) && runes[end+1] != '\n' {
+				end++
+			}
+			s.pos = advance(s.pos, runes[i:end])
+			i = end
+		case '#':
+			switch {
+			case i+1 < len(runes) && runes[i+1] == '|':
+				s.buildCurToken()
+				end := s.scanBlockComment(runes, i)
+				s.pos = advance(s.pos, runes[i:end])
+				i = end
+			case i+1 < len(runes) && runes[i+1] == ';':
+				s.update(datumComment{Pos: s.pos})
+				s.pos.Col++
+				i++
+			case i+1 < len(runes) && runes[i+1] == '(':
+				s.update(types.HashParen{Pos: s.pos})
+				s.depth++
+				s.pos.Col++
+				i++
+			case i+1 < len(runes) && runes[i+1] == '{':
+				s.update(types.HashBrace{Pos: s.pos})
+				s.depth++
+				s.pos.Col++
+				i++
+			case i+2 < len(runes) && runes[i+1] == 'S' && runes[i+2] == '(':
+				s.update(types.HashS{Pos: s.pos})
+				s.depth++
+				s.pos.Col += 2
+				i += 2
+			default:
+				s.addRune(c, s.pos)
+			}
 		default:
-			curTokenTxt = append(curTokenTxt, c)
+			s.addRune(c, s.pos)
 		}
+		s.pos = advance(s.pos, runes[i:i+1])
+	}
+	s.buildCurToken()
+}
+
+// Tokens returns the tokens that have been scanned, without the datum comments and the expressions that they
+// comment out, and the depth, in the same way as Scan. A string that isn't closed yet is an Invalid token.
+func (s *Scanner) Tokens() ([]types.Token, int) {
+	tokens := s.tokens
+	if s.inString {
+		tokens = append(tokens[:len(tokens):len(tokens)], types.Invalid{Text: string(s.str.text), Msg: unclosedString, Pos: s.str.pos})
+	}
+	out, pending := removeDatumComments(tokens)
+	depth := s.depth
+	if s.commentNesting > 0 || pending {
+		depth++
 	}
-	buildCurToken()
 	return out, depth
 }
+
+// NeedsMore works like the NeedsMore function on the tokens and depth that Tokens returns.
+// It only has to look at the tokens when the text so far isn't inside of a list, string or block comment.
+func (s *Scanner) NeedsMore() bool {
+	if s.depth > 0 || s.inString || s.commentNesting > 0 {
+		return true
+	}
+	return NeedsMore(s.Tokens())
+}
+
+// scanBlockComment reads the block comment that the #| at runes[start] opens, or that the last piece ended in
+// if start is 0 and s is already in a comment. Block comments can be nested.
+// It returns the index of the # that closes the comment, or of the last rune if the comment isn't closed yet.
+func (s *Scanner) scanBlockComment(runes []rune, start int) int {
+	for i := start; i+1 < len(runes); i++ {
+		switch {
+		case runes[i] == '#' && runes[i+1] == '|':
+			s.commentNesting++
+			i++
+		case runes[i] == '|' && runes[i+1] == '#':
+			s.commentNesting--
+			i++
+			if s.commentNesting == 0 {
+				return i
+			}
+		}
+	}
+	return len(runes) - 1
+}
+
+// datumComment marks a #;. It is only used inside of the scanner; removeDatumComments takes it
+// and the expression after it out of the tokens.
+type datumComment struct{ types.Pos }
+
+func (d datumComment) TokenForm() string { return "#;" }
+func (d datumComment) String() string {
+	return "DATUM_COMMENT"
+}
+
+// removeDatumComments returns tokens without the datum comments and the expressions that they comment out.
+// It also returns true if a datum comment ran out of tokens before its expression was complete.
+func removeDatumComments(tokens []types.Token) ([]types.Token, bool) {
+	var out []types.Token
+	pending := false
+	for i := 0; i < len(tokens); {
+		if _, ok := tokens[i].(datumComment); !ok {
+			out = append(out, tokens[i])
+			i++
+			continue
+		}
+		n, ok := datumLength(tokens[i+1:])
+		if !ok {
+			pending = true
+		}
+		i += 1 + n
+	}
+	return out, pending
+}
+
+// datumLength returns how many tokens at the start of tokens make up one complete expression.
+// Datum comments count as part of the expression that follows them, so #; #; A B is two comments
+// followed by nothing. It returns false if tokens ends before the expression is complete.
+func datumLength(tokens []types.Token) (int, bool) {
+	if len(tokens) == 0 {
+		return 0, false
+	}
+	switch tokens[0].(type) {
+	case datumComment:
+		//skip the commented out expression, then the one that this #; belongs to
+		n, ok := datumLength(tokens[1:])
+		if !ok {
+			return len(tokens), false
+		}
+		m, ok := datumLength(tokens[1+n:])
+		return 1 + n + m, ok
+	case types.Quote, types.Backquote, types.Comma, types.CommaAt:
+		n, ok := datumLength(tokens[1:])
+		return 1 + n, ok
+	case types.LParen, types.LBrace, types.LBracket, types.HashParen, types.HashBrace, types.HashS:
+		depth := 0
+		for i, t := range tokens {
+			switch t.(type) {
+			case types.LParen, types.LBrace, types.LBracket, types.HashParen, types.HashBrace, types.HashS:
+				depth++
+			case types.RParen, types.RBrace, types.RBracket:
+				depth--
+				if depth == 0 {
+					return i + 1, true
+				}
+			}
+		}
+		return len(tokens), false
+	case types.RParen, types.RBrace, types.RBracket:
+		//nothing to comment out before the end of the list
+		return 0, true
+	}
+	return 1, true
+}
+
+// NeedsMore reports whether the text that Scan turned into tokens and depth ends in the middle of an
+// expression: inside of a list, string or comment, or right after a quote.
+// Code that reads a line at a time uses it to decide when it has read enough to parse.
+func NeedsMore(tokens []types.Token, depth int) bool {
+	if depth > 0 {
+		return true
+	}
+	if len(tokens) == 0 {
+		return false
+	}
+	switch t := tokens[len(tokens)-1].(type) {
+	case types.Quote, types.Backquote, types.Comma, types.CommaAt:
+		return true
+	case types.Invalid:
+		return t.Msg == unclosedString
+	}
+	return false
+}
+
+// advance returns the position after the runes that start at pos
+func advance(pos types.Pos, runes []rune) types.Pos {
+	for _, r := range runes {
+		if r == '\n' {
+			pos.Line++
+			pos.Col = 1
+		} else {
+			pos.Col++
+		}
+	}
+	return pos
+}
+
+// scanString reads the rest of the string literal that s is in, starting at runes[start].
+// It returns the index of the closing double quote, and adds the token for the string.
+// If the string isn't closed yet, it returns the index of the last rune.
+// Parens inside of a string literal are part of the string, so they don't affect the depth.
+func (s *Scanner) scanString(runes []rune, start int) int {
+	st := &s.str
+	sb := &st.sb
+	for i := start; i < len(runes); i++ {
+		c := runes[i]
+		if c == '"' {
+			st.text = append(st.text, runes[start:i+1]...)
+			s.inString = false
+			if st.errMsg != "" {
+				s.tokens = append(s.tokens, types.Invalid{Text: string(st.text), Msg: st.errMsg, Pos: st.pos})
+			} else {
+				s.tokens = append(s.tokens, types.STRING{Value: sb.String(), Pos: st.pos})
+			}
+			return i
+		}
+		if c != '\\' || i+1 == len(runes) {
+			sb.WriteRune(c)
+			continue
+		}
+		i++
+		switch runes[i] {
+		case 'n':
+			sb.WriteRune('\n')
+		case 't':
+			sb.WriteRune('\t')
+		case 'r':
+			sb.WriteRune('\r')
+		case '"':
+			sb.WriteRune('"')
+		case '\\':
+			sb.WriteRune('\\')
+		case 'u':
+			// \u{XXXX}, with one to six hex digits
+			end := i + 1
+			for end < len(runes) && runes[end] != '}' && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) || runes[i+1] != '{' || runes[end] != '}' {
+				st.errMsg = "Invalid unicode escape in string"
+				i = end - 1
+				continue
+			}
+			r, err := strconv.ParseUint(string(runes[i+2:end]), 16, 32)
+			if err != nil || r > 0x10FFFF {
+				st.errMsg = "Invalid unicode escape in string"
+			} else {
+				sb.WriteRune(rune(r))
+			}
+			i = end
+		default:
+			st.errMsg = "Unknown escape sequence in string"
+		}
+	}
+	st.text = append(st.text, runes[start:]...)
+	return len(runes) - 1
+}
+
+const unclosedString = "String without closing double quote"
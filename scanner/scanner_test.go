@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/jonbodner/my_lisp/types"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -20,7 +21,7 @@ func TestScannerAtom(t *testing.T) {
 
 	tokens, depth := Scan(atom)
 
-	testingHelper(t, []reflect.Type{reflect.TypeOf(types.NAME(""))}, 0, tokens, depth)
+	testingHelper(t, []reflect.Type{reflect.TypeOf(types.NAME{})}, 0, tokens, depth)
 }
 
 func TestScannerList(t *testing.T) {
@@ -31,9 +32,9 @@ func TestScannerList(t *testing.T) {
 	testingHelper(t,
 		[]reflect.Type{
 			reflect.TypeOf(types.LPAREN),
-			reflect.TypeOf(types.NAME("")),
-			reflect.TypeOf(types.NAME("")),
-			reflect.TypeOf(types.NAME("")),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{}),
 			reflect.TypeOf(types.RPAREN)},
 		0, tokens, depth)
 }
@@ -46,21 +47,170 @@ func TestScannerAllTokens(t *testing.T) {
 	testingHelper(t,
 		[]reflect.Type{
 			reflect.TypeOf(types.LPAREN),
-			reflect.TypeOf(types.NAME("")),
-			reflect.TypeOf(types.NAME("")),
-			reflect.TypeOf(types.NAME("")),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{}),
 			reflect.TypeOf(types.DOT),
 			reflect.TypeOf(types.QUOTE),
 			reflect.TypeOf(types.LPAREN),
-			reflect.TypeOf(types.NAME("")),
-			reflect.TypeOf(types.NAME("")),
-			reflect.TypeOf(types.NAME("")),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{}),
 			reflect.TypeOf(types.RPAREN),
 			reflect.TypeOf(types.RPAREN),
 			reflect.TypeOf(types.LPAREN)},
 		1, tokens, depth)
 }
 
+func TestScannerQuasiquote(t *testing.T) {
+	atom := "`(A ,B ,@C)"
+
+	tokens, depth := Scan(atom)
+
+	testingHelper(t,
+		[]reflect.Type{
+			reflect.TypeOf(types.BACKQUOTE),
+			reflect.TypeOf(types.LPAREN),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.COMMA),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.COMMA_AT),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.RPAREN)},
+		0, tokens, depth)
+}
+
+func TestScannerBraces(t *testing.T) {
+	atom := "{A (1 2) B {"
+
+	tokens, depth := Scan(atom)
+
+	testingHelper(t,
+		[]reflect.Type{
+			reflect.TypeOf(types.LBRACE),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.LPAREN),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.RPAREN),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.LBRACE)},
+		2, tokens, depth)
+
+	tokens, depth = Scan("{A #;{B C} D}")
+	testingHelper(t,
+		[]reflect.Type{
+			reflect.TypeOf(types.LBRACE),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.RBRACE)},
+		0, tokens, depth)
+}
+
+func TestScannerBrackets(t *testing.T) {
+	atom := "[A #(B)] ["
+
+	tokens, depth := Scan(atom)
+
+	testingHelper(t,
+		[]reflect.Type{
+			reflect.TypeOf(types.LBRACKET),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.HASH_PAREN),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.RPAREN),
+			reflect.TypeOf(types.RBRACKET),
+			reflect.TypeOf(types.LBRACKET)},
+		1, tokens, depth)
+	if tokens[3].Position() != (types.Pos{Line: 1, Col: 6}) {
+		t.Errorf("Expected B at 1:6, got %s", tokens[3].Position())
+	}
+
+	tokens, depth = Scan("A #;#(B [C]) D")
+	testingHelper(t,
+		[]reflect.Type{
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{})},
+		0, tokens, depth)
+}
+
+func TestScannerSet(t *testing.T) {
+	tokens, depth := Scan("#{A #{B}}")
+
+	testingHelper(t,
+		[]reflect.Type{
+			reflect.TypeOf(types.HASH_BRACE),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.HASH_BRACE),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.RBRACE),
+			reflect.TypeOf(types.RBRACE)},
+		0, tokens, depth)
+}
+
+func TestScannerStruct(t *testing.T) {
+	tokens, depth := Scan("#S(P :X 1) #SX")
+
+	testingHelper(t,
+		[]reflect.Type{
+			reflect.TypeOf(types.HASH_S),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.RPAREN),
+			reflect.TypeOf(types.NAME{})},
+		0, tokens, depth)
+	if tokens[1].Position() != (types.Pos{Line: 1, Col: 4}) {
+		t.Errorf("Expected P at 1:4, got %s", tokens[1].Position())
+	}
+}
+
+func TestScannerString(t *testing.T) {
+	atom := `(A "b (c" D)`
+
+	tokens, depth := Scan(atom)
+
+	testingHelper(t,
+		[]reflect.Type{
+			reflect.TypeOf(types.LPAREN),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.STRING{}),
+			reflect.TypeOf(types.NAME{}),
+			reflect.TypeOf(types.RPAREN)},
+		0, tokens, depth)
+	if tokens[2] != (types.STRING{Value: "b (c", Pos: types.Pos{Line: 1, Col: 4}}) {
+		t.Errorf("Expected string b (c, got %s", tokens[2])
+	}
+}
+
+func TestScannerStringEscapes(t *testing.T) {
+	start := types.Pos{Line: 1, Col: 1}
+	data := []struct {
+		name     string
+		input    string
+		expected types.Token
+	}{
+		{"newline", `"a\nb"`, types.STRING{Value: "a\nb", Pos: start}},
+		{"quote", `"say \"hi\""`, types.STRING{Value: `say "hi"`, Pos: start}},
+		{"backslash", `"a\\b"`, types.STRING{Value: `a\b`, Pos: start}},
+		{"unicode", `"\u{48}\u{1F600}"`, types.STRING{Value: "H\U0001F600", Pos: start}},
+		{"unknown escape", `"a\qb"`, types.Invalid{Text: `"a\qb"`, Msg: "Unknown escape sequence in string", Pos: start}},
+		{"bad unicode", `"\u{zz}"`, types.Invalid{Text: `"\u{zz}"`, Msg: "Invalid unicode escape in string", Pos: start}},
+		{"unterminated", `"abc (`, types.Invalid{Text: `"abc (`, Msg: "String without closing double quote", Pos: start}},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			tokens, depth := Scan(d.input)
+			if len(tokens) != 1 || depth != 0 {
+				t.Fatalf("Expected a single token and depth 0, got %v and %d", tokens, depth)
+			}
+			if tokens[0] != d.expected {
+				t.Errorf("Expected %#v, got %#v", d.expected, tokens[0])
+			}
+		})
+	}
+}
+
 func testingHelper(t *testing.T, expectedTokens []reflect.Type, expectedDepth int, tokens []types.Token, depth int) {
 	fmt.Println(tokens, depth)
 
@@ -77,3 +227,107 @@ func testingHelper(t *testing.T, expectedTokens []reflect.Type, expectedDepth in
 		}
 	}
 }
+
+func TestScannerPositions(t *testing.T) {
+	tokens, depth := ScanFrom(types.Pos{File: "test.lisp", Line: 3, Col: 1}, "(A ,@B\n  \"x\ny\" 'C) . D")
+	expected := []types.Token{
+		types.LParen{Pos: types.Pos{File: "test.lisp", Line: 3, Col: 1}},
+		types.NAME{Text: "A", Pos: types.Pos{File: "test.lisp", Line: 3, Col: 2}},
+		types.CommaAt{Pos: types.Pos{File: "test.lisp", Line: 3, Col: 4}},
+		types.NAME{Text: "B", Pos: types.Pos{File: "test.lisp", Line: 3, Col: 6}},
+		types.STRING{Value: "x\ny", Pos: types.Pos{File: "test.lisp", Line: 4, Col: 3}},
+		types.Quote{Pos: types.Pos{File: "test.lisp", Line: 5, Col: 4}},
+		types.NAME{Text: "C", Pos: types.Pos{File: "test.lisp", Line: 5, Col: 5}},
+		types.RParen{Pos: types.Pos{File: "test.lisp", Line: 5, Col: 6}},
+		types.Dot{Pos: types.Pos{File: "test.lisp", Line: 5, Col: 8}},
+		types.NAME{Text: "D", Pos: types.Pos{File: "test.lisp", Line: 5, Col: 10}},
+	}
+	if depth != 0 || len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens and depth 0, got %v and %d", len(expected), tokens, depth)
+	}
+	for i := range expected {
+		if tokens[i] != expected[i] {
+			t.Errorf("token %d: expected %#v, got %#v", i, expected[i], tokens[i])
+		}
+	}
+	if s := tokens[4].Position().String(); s != "test.lisp:4:3" {
+		t.Errorf("Expected test.lisp:4:3, got %s", s)
+	}
+}
+
+func TestScannerComments(t *testing.T) {
+	data := []struct {
+		input    string
+		expected string
+		depth    int
+	}{
+		{"(A ; (B\nC)", "[LPAREN A C RPAREN]", 0},
+		{"; )))", "[]", 0},
+		{"A;B", "[A]", 0},
+		{`"a;b" C`, `["a;b" C]`, 0},
+		{"(A #| ( B |# C)", "[LPAREN A C RPAREN]", 0},
+		{"(A #| #| ) |# ) |# C)", "[LPAREN A C RPAREN]", 0},
+		{"(A #| (\n(", "[LPAREN A]", 2},
+		{"A#|B|#C", "[A C]", 0},
+		{"(A #;(B (C)) D)", "[LPAREN A D RPAREN]", 0},
+		{"(A #;'B D)", "[LPAREN A D RPAREN]", 0},
+		{"(A #;#;B C D)", "[LPAREN A D RPAREN]", 0},
+		{"(A #;)", "[LPAREN A RPAREN]", 0},
+		{"#;(A B) C", "[C]", 0},
+		{"#;", "[]", 1},
+		{"#;(A\nB", "[]", 2},
+		{"#A", "[#A]", 0},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			tokens, depth := Scan(d.input)
+			if s := fmt.Sprint(tokens); s != d.expected {
+				t.Errorf("Expected %s, got %s", d.expected, s)
+			}
+			if depth != d.depth {
+				t.Errorf("Expected depth %d, got %d", d.depth, depth)
+			}
+		})
+	}
+}
+
+func TestScannerCommentPositions(t *testing.T) {
+	tokens, _ := Scan("; one\n#| two\nthree |# A #;B\n  C")
+	expected := []types.Token{
+		types.NAME{Text: "A", Pos: types.Pos{Line: 3, Col: 10}},
+		types.NAME{Text: "C", Pos: types.Pos{Line: 4, Col: 3}},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, tokens)
+	}
+}
+
+func TestScannerLines(t *testing.T) {
+	//scanning a line at a time must find the same tokens, positions and depth as scanning all of the text at once
+	data := []string{
+		"(A\n  (B\n C)) (D\n)",
+		"(A \"b\n(c\\n\n\" D)\n",
+		"\"a\\u{41\n}\" B",
+		"(A #| ( \n #| x |# \n ) |# B\n C)",
+		"#| open\n(",
+		"#;\n(A\nB) C",
+		"(A \"unclosed\n(B",
+		"'\n,@\nA",
+	}
+	for _, input := range data {
+		t.Run(input, func(t *testing.T) {
+			expected, expectedDepth := Scan(input)
+			sc := NewScanner(types.Pos{Line: 1, Col: 1})
+			for _, line := range strings.SplitAfter(input, "\n") {
+				sc.Scan(line)
+			}
+			tokens, depth := sc.Tokens()
+			if !reflect.DeepEqual(tokens, expected) || depth != expectedDepth {
+				t.Errorf("Expected %#v and %d, got %#v and %d", expected, expectedDepth, tokens, depth)
+			}
+			if sc.NeedsMore() != NeedsMore(expected, expectedDepth) {
+				t.Errorf("Expected NeedsMore to be %v", NeedsMore(expected, expectedDepth))
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package types
+
+import (
+	"hash/fnv"
+	"math/bits"
+)
+
+// hamt is a persistent hash array mapped trie, which backs Map and Set. Each version is immutable, so changing it
+// makes a new version that shares every node that didn't change with the old one. A change copies at most
+// one node per level, and there are at most 7 levels, so it takes O(log32 n) time.
+//
+// Entries are found by their hash key (see hashKey). Each level of the trie uses the next 5 bits of the hash of the
+// hash key to pick a slot. When two hash keys have the same 32-bit hash, they go in a collision node at the bottom.
+//
+// A transient version owns the nodes that it creates, and changes them in place instead of copying them.
+// Nodes are owned by the editToken of the transient that made them; persistent nodes have no owner.
+type hamt struct {
+	root  *hamtNode
+	count int
+}
+
+// editToken marks the nodes that belong to one transient
+type editToken struct{}
+
+const (
+	hamtBits  = 5
+	hamtWidth = 1 << hamtBits
+	hamtMask  = hamtWidth - 1
+)
+
+type hamtEntry struct {
+	hk    string
+	hash  uint32
+	key   Expr
+	value Expr
+}
+
+// hamtSlot is either a child node or an entry
+type hamtSlot struct {
+	node  *hamtNode
+	entry hamtEntry
+}
+
+type hamtNode struct {
+	edit *editToken
+	// bitmap has a bit set for each of the 32 possible slots that is used. slots only has the used ones, in order.
+	bitmap uint32
+	// a collision node has no bitmap. Its slots are entries whose hash keys all have the same hash.
+	collision bool
+	slots     []hamtSlot
+}
+
+func hashOf(hk string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(hk))
+	return h.Sum32()
+}
+
+func newHamtEntry(hk string, key, value Expr) hamtEntry {
+	return hamtEntry{hk: hk, hash: hashOf(hk), key: key, value: value}
+}
+
+func (h hamt) get(hk string) (hamtEntry, bool) {
+	n := h.root
+	hash := hashOf(hk)
+	for shift := uint(0); n != nil; shift += hamtBits {
+		if n.collision {
+			for _, s := range n.slots {
+				if s.entry.hk == hk {
+					return s.entry, true
+				}
+			}
+			return hamtEntry{}, false
+		}
+		bit := uint32(1) << ((hash >> shift) & hamtMask)
+		if n.bitmap&bit == 0 {
+			return hamtEntry{}, false
+		}
+		s := n.slots[bits.OnesCount32(n.bitmap&(bit-1))]
+		if s.node == nil {
+			return s.entry, s.entry.hk == hk
+		}
+		n = s.node
+	}
+	return hamtEntry{}, false
+}
+
+// assoc returns a version of h with e in it, replacing any entry with the same hash key.
+// If edit isn't nil, nodes that it owns are changed in place.
+func (h hamt) assoc(edit *editToken, e hamtEntry) hamt {
+	root := h.root
+	if root == nil {
+		root = &hamtNode{edit: edit}
+	}
+	newRoot, added := root.assoc(edit, 0, e)
+	if added {
+		h.count++
+	}
+	h.root = newRoot
+	return h
+}
+
+// dissoc returns a version of h without the entry for hk, and false if there wasn't one
+func (h hamt) dissoc(edit *editToken, hk string) (hamt, bool) {
+	if h.root == nil {
+		return h, false
+	}
+	newRoot, removed := h.root.dissoc(edit, 0, hashOf(hk), hk)
+	if !removed {
+		return h, false
+	}
+	return hamt{root: newRoot, count: h.count - 1}, true
+}
+
+// each calls f with each entry in h, in no particular order
+func (h hamt) each(f func(hamtEntry)) {
+	if h.root != nil {
+		h.root.each(f)
+	}
+}
+
+func (n *hamtNode) each(f func(hamtEntry)) {
+	for _, s := range n.slots {
+		if s.node != nil {
+			s.node.each(f)
+		} else {
+			f(s.entry)
+		}
+	}
+}
+
+// editable returns n if it's owned by edit, and otherwise a copy of n that is
+func (n *hamtNode) editable(edit *editToken) *hamtNode {
+	if edit != nil && n.edit == edit {
+		return n
+	}
+	slots := make([]hamtSlot, len(n.slots), len(n.slots)+1)
+	copy(slots, n.slots)
+	return &hamtNode{edit: edit, bitmap: n.bitmap, collision: n.collision, slots: slots}
+}
+
+func (n *hamtNode) assoc(edit *editToken, shift uint, e hamtEntry) (*hamtNode, bool) {
+	if n.collision {
+		for i, s := range n.slots {
+			if s.entry.hk == e.hk {
+				out := n.editable(edit)
+				out.slots[i].entry = e
+				return out, false
+			}
+		}
+		out := n.editable(edit)
+		out.slots = append(out.slots, hamtSlot{entry: e})
+		return out, true
+	}
+	bit := uint32(1) << ((e.hash >> shift) & hamtMask)
+	idx := bits.OnesCount32(n.bitmap & (bit - 1))
+	if n.bitmap&bit == 0 {
+		out := n.editable(edit)
+		out.slots = append(out.slots, hamtSlot{})
+		copy(out.slots[idx+1:], out.slots[idx:])
+		out.slots[idx] = hamtSlot{entry: e}
+		out.bitmap |= bit
+		return out, true
+	}
+	s := n.slots[idx]
+	if s.node != nil {
+		child, added := s.node.assoc(edit, shift+hamtBits, e)
+		if child == s.node {
+			return n, added
+		}
+		out := n.editable(edit)
+		out.slots[idx].node = child
+		return out, added
+	}
+	out := n.editable(edit)
+	if s.entry.hk == e.hk {
+		out.slots[idx].entry = e
+		return out, false
+	}
+	out.slots[idx] = hamtSlot{node: mergeEntries(edit, shift+hamtBits, s.entry, e)}
+	return out, true
+}
+
+// mergeEntries returns a node at shift that holds two entries whose hashes were the same up to shift
+func mergeEntries(edit *editToken, shift uint, e1, e2 hamtEntry) *hamtNode {
+	if shift >= 32 {
+		return &hamtNode{edit: edit, collision: true, slots: []hamtSlot{{entry: e1}, {entry: e2}}}
+	}
+	f1, f2 := (e1.hash>>shift)&hamtMask, (e2.hash>>shift)&hamtMask
+	if f1 == f2 {
+		return &hamtNode{edit: edit, bitmap: 1 << f1, slots: []hamtSlot{{node: mergeEntries(edit, shift+hamtBits, e1, e2)}}}
+	}
+	if f1 > f2 {
+		e1, e2 = e2, e1
+	}
+	return &hamtNode{edit: edit, bitmap: 1<<f1 | 1<<f2, slots: []hamtSlot{{entry: e1}, {entry: e2}}}
+}
+
+// dissoc returns n without the entry for hk, or nil if that leaves n empty
+func (n *hamtNode) dissoc(edit *editToken, shift uint, hash uint32, hk string) (*hamtNode, bool) {
+	if n.collision {
+		for i, s := range n.slots {
+			if s.entry.hk == hk {
+				return n.removeSlot(edit, i, 0), true
+			}
+		}
+		return n, false
+	}
+	bit := uint32(1) << ((hash >> shift) & hamtMask)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	idx := bits.OnesCount32(n.bitmap & (bit - 1))
+	s := n.slots[idx]
+	if s.node == nil {
+		if s.entry.hk != hk {
+			return n, false
+		}
+		return n.removeSlot(edit, idx, bit), true
+	}
+	child, removed := s.node.dissoc(edit, shift+hamtBits, hash, hk)
+	if !removed {
+		return n, false
+	}
+	if child == nil {
+		return n.removeSlot(edit, idx, bit), true
+	}
+	out := n.editable(edit)
+	//a child that is down to one entry is replaced by the entry, so the trie doesn't keep empty levels
+	if len(child.slots) == 1 && child.slots[0].node == nil {
+		out.slots[idx] = child.slots[0]
+	} else {
+		out.slots[idx].node = child
+	}
+	return out, true
+}
+
+// removeSlot returns n without the slot at idx, whose bit in the bitmap is bit, or nil if that leaves n empty
+func (n *hamtNode) removeSlot(edit *editToken, idx int, bit uint32) *hamtNode {
+	if len(n.slots) == 1 {
+		return nil
+	}
+	out := n.editable(edit)
+	copy(out.slots[idx:], out.slots[idx+1:])
+	out.slots[len(out.slots)-1] = hamtSlot{}
+	out.slots = out.slots[:len(out.slots)-1]
+	out.bitmap &^= bit
+	return out
+}
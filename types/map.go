@@ -0,0 +1,354 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Map is a hash map. Keys are compared by value, the way EQ compares them: numbers by their value
+// (so 1/2 and 2/4 are the same key, but 1 and 1.0 aren't), symbols and strings by their text, and lists by their elements.
+// Maps evaluate to themselves. They are safe to use from multiple goroutines.
+//
+// A Map holds a version of a persistent hash map. Assoc and Dissoc return new Maps without changing the old one,
+// sharing most of the old one's structure. Put and Delete change which version the Map holds, so they change the Map,
+// but not the Maps that were made from it by Assoc, Dissoc or Copy.
+type Map struct {
+	mu sync.RWMutex
+	h  hamt
+}
+
+// MapEntry is a key in a Map and its value
+type MapEntry struct {
+	Key   Expr
+	Value Expr
+}
+
+func NewMap() *Map {
+	return &Map{}
+}
+
+func (m *Map) isExpr() {}
+
+// String writes the map as a literal, {KEY VALUE ...}, with the keys in order
+func (m *Map) String() string {
+	return entriesString("{", m.Entries(), "}")
+}
+
+func entriesString(start string, entries []MapEntry, end string) string {
+	var sb strings.Builder
+	sb.WriteString(start)
+	for i, e := range entries {
+		if i > 0 {
+			sb.WriteRune(' ')
+		}
+		sb.WriteString(e.Key.String())
+		sb.WriteRune(' ')
+		sb.WriteString(e.Value.String())
+	}
+	sb.WriteString(end)
+	return sb.String()
+}
+
+func (m *Map) version() hamt {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.h
+}
+
+// Get returns the value for k, and false if k isn't in m
+func (m *Map) Get(k Expr) (Expr, bool) {
+	return getEntry(m.version(), k)
+}
+
+func getEntry(h hamt, k Expr) (Expr, bool) {
+	hk, ok := hashKey(k)
+	if !ok {
+		return nil, false
+	}
+	e, ok := h.get(hk)
+	return e.value, ok
+}
+
+// Put sets the value for k to v. It returns an error if k can't be a key.
+// Only numbers, symbols, strings and lists of them can be keys.
+func (m *Map) Put(k, v Expr) error {
+	e, err := mapEntry(k, v)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.h = m.h.assoc(nil, e)
+	m.mu.Unlock()
+	return nil
+}
+
+func mapEntry(k, v Expr) (hamtEntry, error) {
+	hk, ok := hashKey(k)
+	if !ok {
+		return hamtEntry{}, fmt.Errorf("%s can't be used as a map key", k)
+	}
+	return newHamtEntry(hk, k, v), nil
+}
+
+// Delete removes k from m. It returns false if k wasn't in m.
+func (m *Map) Delete(k Expr) bool {
+	hk, ok := hashKey(k)
+	if !ok {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.h, ok = m.h.dissoc(nil, hk)
+	return ok
+}
+
+// Assoc returns a new Map with the value for k set to v. m isn't changed.
+func (m *Map) Assoc(k, v Expr) (*Map, error) {
+	e, err := mapEntry(k, v)
+	if err != nil {
+		return nil, err
+	}
+	return &Map{h: m.version().assoc(nil, e)}, nil
+}
+
+// Dissoc returns a new Map without k. m isn't changed.
+func (m *Map) Dissoc(k Expr) *Map {
+	h := m.version()
+	if hk, ok := hashKey(k); ok {
+		h, _ = h.dissoc(nil, hk)
+	}
+	return &Map{h: h}
+}
+
+// Len returns the number of keys in m
+func (m *Map) Len() int {
+	return m.version().count
+}
+
+// Entries returns the keys and values in m, in the order of their keys.
+// Numbers come first, then strings, then symbols, then lists.
+func (m *Map) Entries() []MapEntry {
+	return sortedEntries(m.version())
+}
+
+func sortedEntries(h hamt) []MapEntry {
+	out := make([]MapEntry, 0, h.count)
+	h.each(func(e hamtEntry) {
+		out = append(out, MapEntry{Key: e.key, Value: e.value})
+	})
+	sort.Slice(out, func(i, j int) bool { return CompareKeys(out[i].Key, out[j].Key) < 0 })
+	return out
+}
+
+// Copy returns a new Map with the same keys and values as m. It takes constant time.
+func (m *Map) Copy() *Map {
+	return &Map{h: m.version()}
+}
+
+// Transient returns a TransientMap that starts with the keys and values in m
+func (m *Map) Transient() *TransientMap {
+	return &TransientMap{h: m.version(), edit: &editToken{}}
+}
+
+// TransientMap builds a Map. Unlike a Map, it changes its nodes in place, so making a lot of changes is faster.
+// Once Persistent is called, it can't be used again.
+type TransientMap struct {
+	mu   sync.Mutex
+	h    hamt
+	edit *editToken
+}
+
+func (t *TransientMap) isExpr() {}
+func (t *TransientMap) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.edit == nil {
+		return "#<TRANSIENT>"
+	}
+	return entriesString("#<TRANSIENT {", sortedEntries(t.h), "}>")
+}
+
+// Put sets the value for k to v. It returns an error if k can't be a key, or if t has been made persistent.
+func (t *TransientMap) Put(k, v Expr) error {
+	e, err := mapEntry(k, v)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.edit == nil {
+		return ErrTransientUsed
+	}
+	t.h = t.h.assoc(t.edit, e)
+	return nil
+}
+
+// Delete removes k. It returns an error if t has been made persistent.
+func (t *TransientMap) Delete(k Expr) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.edit == nil {
+		return ErrTransientUsed
+	}
+	if hk, ok := hashKey(k); ok {
+		t.h, _ = t.h.dissoc(t.edit, hk)
+	}
+	return nil
+}
+
+// Persistent returns a Map with the keys and values in t. After it is called, t can't be used.
+func (t *TransientMap) Persistent() (*Map, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.edit == nil {
+		return nil, ErrTransientUsed
+	}
+	t.edit = nil
+	return &Map{h: t.h}, nil
+}
+
+// ErrTransientUsed is returned when a transient is used after it has been made persistent
+var ErrTransientUsed = errors.New("transient used after it was made persistent")
+
+// hashKey returns a string that is the same for two keys exactly when they are the same key,
+// and false if e can't be a key.
+func hashKey(e Expr) (string, bool) {
+	var sb strings.Builder
+	if !writeHashKey(&sb, e) {
+		return "", false
+	}
+	return sb.String(), true
+}
+
+func writeHashKey(sb *strings.Builder, e Expr) bool {
+	switch e := e.(type) {
+	case Integer:
+		sb.WriteString("i" + e.String())
+	case Ratio:
+		sb.WriteString("r" + e.String())
+	case Float:
+		//0.0 and -0.0 are the same number
+		if e == 0 {
+			e = 0
+		}
+		sb.WriteString("f" + strconv.FormatUint(math.Float64bits(float64(e)), 16))
+	case String:
+		sb.WriteString("s" + QuoteString(string(e)))
+	case Atom:
+		//symbols made with STRING->SYMBOL can contain any characters, so their length is written first
+		sb.WriteString("a" + strconv.Itoa(len(e)) + ":" + string(e))
+	case Nil:
+		sb.WriteString("n")
+	case *SExpr:
+		sb.WriteRune('(')
+		var cur Expr = e
+		for {
+			c, ok := cur.(*SExpr)
+			if !ok {
+				sb.WriteString(" . ")
+				if !writeHashKey(sb, cur) {
+					return false
+				}
+				break
+			}
+			if c.Left == NIL && c.Right == NIL {
+				break
+			}
+			if !writeHashKey(sb, c.Left) {
+				return false
+			}
+			sb.WriteRune(' ')
+			if c.Right == NIL {
+				break
+			}
+			cur = c.Right
+		}
+		sb.WriteRune(')')
+	default:
+		return false
+	}
+	return true
+}
+
+// CompareKeys orders the keys of a Map. It returns a negative number if a comes before b, 0 if they are
+// the same key, and a positive number if a comes after b.
+// Numbers come first in numeric order, then strings, then symbols, then lists, which are compared element by element.
+func CompareKeys(a, b Expr) int {
+	ra, rb := keyRank(a), keyRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case Number:
+		return compareNumberKeys(a, b.(Number))
+	case String:
+		return strings.Compare(string(a), string(b.(String)))
+	case Atom:
+		return strings.Compare(string(a), string(b.(Atom)))
+	case *SExpr:
+		b := b.(*SExpr)
+		aEmpty, bEmpty := a.Left == NIL && a.Right == NIL, b.Left == NIL && b.Right == NIL
+		switch {
+		case aEmpty && bEmpty:
+			return 0
+		case aEmpty:
+			return -1
+		case bEmpty:
+			return 1
+		}
+		if c := CompareKeys(a.Left, b.Left); c != 0 {
+			return c
+		}
+		return CompareKeys(a.Right, b.Right)
+	}
+	return 0
+}
+
+func keyRank(e Expr) int {
+	switch e.(type) {
+	case Nil:
+		return 0
+	case Number:
+		return 1
+	case String:
+		return 2
+	case Atom:
+		return 3
+	case *SExpr:
+		return 4
+	}
+	return 5
+}
+
+// compareNumberKeys compares exact numbers exactly. If either is a Float, they are compared as floats,
+// and an exact number comes before a Float with the same value.
+func compareNumberKeys(a, b Number) int {
+	fa, aFloat := a.(Float)
+	fb, bFloat := b.(Float)
+	if !aFloat && !bFloat {
+		return a.(interface{ Rat() *big.Rat }).Rat().Cmp(b.(interface{ Rat() *big.Rat }).Rat())
+	}
+	if !aFloat {
+		fa = Float(a.(interface{ Float64() float64 }).Float64())
+	}
+	if !bFloat {
+		fb = Float(b.(interface{ Float64() float64 }).Float64())
+	}
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	case aFloat == bFloat:
+		return 0
+	case bFloat:
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,187 @@
+package types
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Number is an Integer, a Ratio or a Float. Numbers evaluate to themselves.
+type Number interface {
+	Expr
+	isNumber()
+}
+
+// Integer is an exact integer of any size.
+// Values that fit in an int64 are stored directly; bigger ones use a big.Int.
+type Integer struct {
+	small int64
+	big   *big.Int
+}
+
+// NewInteger returns the Integer for i
+func NewInteger(i int64) Integer {
+	return Integer{small: i}
+}
+
+// NewBigInteger returns the Integer for i. It doesn't hold on to i.
+func NewBigInteger(i *big.Int) Integer {
+	if i.IsInt64() {
+		return Integer{small: i.Int64()}
+	}
+	return Integer{big: new(big.Int).Set(i)}
+}
+
+func (i Integer) isExpr()   {}
+func (i Integer) isNumber() {}
+func (i Integer) String() string {
+	if i.big != nil {
+		return i.big.String()
+	}
+	return strconv.FormatInt(i.small, 10)
+}
+
+// Int64 returns the value of i and true if it fits in an int64
+func (i Integer) Int64() (int64, bool) {
+	return i.small, i.big == nil
+}
+
+// BigInt returns the value of i as a new big.Int
+func (i Integer) BigInt() *big.Int {
+	if i.big != nil {
+		return new(big.Int).Set(i.big)
+	}
+	return big.NewInt(i.small)
+}
+
+// Rat returns the value of i as a new big.Rat
+func (i Integer) Rat() *big.Rat {
+	return new(big.Rat).SetInt(i.BigInt())
+}
+
+// Float64 returns the nearest float64 to i
+func (i Integer) Float64() float64 {
+	if i.big != nil {
+		f, _ := new(big.Float).SetInt(i.big).Float64()
+		return f
+	}
+	return float64(i.small)
+}
+
+// Sign returns -1, 0 or 1 depending on whether i is negative, zero or positive
+func (i Integer) Sign() int {
+	if i.big != nil {
+		return i.big.Sign()
+	}
+	switch {
+	case i.small < 0:
+		return -1
+	case i.small > 0:
+		return 1
+	}
+	return 0
+}
+
+// Ratio is an exact fraction whose denominator is not 1.
+type Ratio struct {
+	r *big.Rat
+}
+
+// NewRatio returns the exact Number for r. If r is a whole number, that's an Integer, otherwise a Ratio.
+// It doesn't hold on to r.
+func NewRatio(r *big.Rat) Number {
+	if r.IsInt() {
+		return NewBigInteger(r.Num())
+	}
+	return Ratio{r: new(big.Rat).Set(r)}
+}
+
+func (r Ratio) isExpr()   {}
+func (r Ratio) isNumber() {}
+func (r Ratio) String() string {
+	return r.r.RatString()
+}
+
+// Rat returns the value of r as a new big.Rat
+func (r Ratio) Rat() *big.Rat {
+	return new(big.Rat).Set(r.r)
+}
+
+// Float64 returns the nearest float64 to r
+func (r Ratio) Float64() float64 {
+	f, _ := r.r.Float64()
+	return f
+}
+
+// Sign returns -1 or 1 depending on whether r is negative or positive
+func (r Ratio) Sign() int {
+	return r.r.Sign()
+}
+
+// Float is an inexact floating-point number.
+type Float float64
+
+func (f Float) isExpr()   {}
+func (f Float) isNumber() {}
+
+// String always includes a decimal point or an exponent, so that a Float reads back in as a Float.
+// Infinities and NaN are written as +inf.0, -inf.0 and +nan.0.
+func (f Float) String() string {
+	switch {
+	case math.IsInf(float64(f), 1):
+		return "+inf.0"
+	case math.IsInf(float64(f), -1):
+		return "-inf.0"
+	case math.IsNaN(float64(f)):
+		return "+nan.0"
+	}
+	s := strconv.FormatFloat(float64(f), 'g', -1, 64)
+	if strings.ContainsAny(s, ".e") {
+		return s
+	}
+	return s + ".0"
+}
+
+var (
+	integerPattern = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	ratioPattern   = regexp.MustCompile(`^[+-]?[0-9]+/[0-9]+$`)
+	floatPattern   = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+)
+
+// ParseNumber returns the Number written as s, and false if s isn't a number.
+// Integers are written as 123, ratios as 1/3, and floats as 1.5, .5 or 1e10.
+// A ratio with a denominator of 0 isn't a number. The special floats are written +inf.0, -inf.0 and +nan.0.
+func ParseNumber(s string) (Number, bool) {
+	switch s {
+	case "+inf.0":
+		return Float(math.Inf(1)), true
+	case "-inf.0":
+		return Float(math.Inf(-1)), true
+	case "+nan.0":
+		return Float(math.NaN()), true
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return NewInteger(i), true
+	}
+	switch {
+	case integerPattern.MatchString(s):
+		b, _ := new(big.Int).SetString(s, 10)
+		return NewBigInteger(b), true
+	case ratioPattern.MatchString(s):
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, false
+		}
+		return NewRatio(r), true
+	case floatPattern.MatchString(s):
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return nil, false
+		}
+		return Float(f), true
+	}
+	return nil, false
+}
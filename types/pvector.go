@@ -0,0 +1,170 @@
+package types
+
+// pvector is a persistent vector, which backs Vector. It's a trie with 32 elements in each leaf and 32 children
+// in each branch, plus a tail of up to 32 elements that haven't been put in the trie yet. Each version is immutable,
+// so changing an element or adding one to the end makes a new version that shares every node that didn't change
+// with the old one, in O(log32 n) time. Like hamt, a transient version changes the nodes that it owns in place.
+// The zero value is an empty vector.
+type pvector struct {
+	count int
+	// shift is the number of bits of an index that are used below the root
+	shift uint
+	root  *pvNode
+	tail  []Expr
+	// tailEdit owns tail, if a transient made it
+	tailEdit *editToken
+}
+
+// pvNode is a branch, which has children, or a leaf, which has values
+type pvNode struct {
+	edit     *editToken
+	children []*pvNode
+	values   []Expr
+}
+
+var emptyPVNode = &pvNode{children: make([]*pvNode, hamtWidth)}
+
+// tailOffset is the index of the first element in the tail
+func (v pvector) tailOffset() int {
+	if v.count < hamtWidth {
+		return 0
+	}
+	return ((v.count - 1) >> hamtBits) << hamtBits
+}
+
+func (v pvector) get(i int) Expr {
+	if i >= v.tailOffset() {
+		return v.tail[i-v.tailOffset()]
+	}
+	n := v.root
+	for level := v.shift; level > 0; level -= hamtBits {
+		n = n.children[(i>>level)&hamtMask]
+	}
+	return n.values[i&hamtMask]
+}
+
+func (n *pvNode) editable(edit *editToken) *pvNode {
+	if edit != nil && n.edit == edit {
+		return n
+	}
+	out := &pvNode{edit: edit}
+	if n.children != nil {
+		out.children = make([]*pvNode, hamtWidth)
+		copy(out.children, n.children)
+	} else {
+		out.values = make([]Expr, hamtWidth)
+		copy(out.values, n.values)
+	}
+	return out
+}
+
+// set returns a version of v with the element at i changed to e. i must be less than the length of v.
+func (v pvector) set(edit *editToken, i int, e Expr) pvector {
+	if i >= v.tailOffset() {
+		v.tail = v.editableTail(edit)
+		v.tail[i-v.tailOffset()] = e
+		return v
+	}
+	v.root = v.root.set(edit, v.shift, i, e)
+	return v
+}
+
+func (n *pvNode) set(edit *editToken, level uint, i int, e Expr) *pvNode {
+	out := n.editable(edit)
+	if level == 0 {
+		out.values[i&hamtMask] = e
+		return out
+	}
+	sub := (i >> level) & hamtMask
+	out.children[sub] = n.children[sub].set(edit, level-hamtBits, i, e)
+	return out
+}
+
+// editableTail returns v.tail if edit owns it, and otherwise a copy of it with room for a full tail
+func (v *pvector) editableTail(edit *editToken) []Expr {
+	if edit != nil && v.tailEdit == edit {
+		return v.tail
+	}
+	out := make([]Expr, len(v.tail), hamtWidth)
+	copy(out, v.tail)
+	v.tailEdit = edit
+	return out
+}
+
+// conj returns a version of v with e added to the end
+func (v pvector) conj(edit *editToken, e Expr) pvector {
+	if len(v.tail) < hamtWidth {
+		v.tail = append(v.editableTail(edit), e)
+		v.count++
+		return v
+	}
+	//the tail is full, so it goes into the trie
+	if v.root == nil {
+		v.root, v.shift = emptyPVNode, hamtBits
+	}
+	tailNode := &pvNode{edit: edit, values: v.tail}
+	if v.tailEdit != edit || edit == nil {
+		tailNode.values = make([]Expr, hamtWidth)
+		copy(tailNode.values, v.tail)
+	}
+	if (v.count >> hamtBits) > (1 << v.shift) {
+		//the trie is full, so it gets a new root
+		root := &pvNode{edit: edit, children: make([]*pvNode, hamtWidth)}
+		root.children[0] = v.root
+		root.children[1] = newPath(edit, v.shift, tailNode)
+		v.root = root
+		v.shift += hamtBits
+	} else {
+		v.root = v.pushTail(edit, v.shift, v.root, tailNode)
+	}
+	v.tail = make([]Expr, 1, hamtWidth)
+	v.tail[0] = e
+	v.tailEdit = edit
+	v.count++
+	return v
+}
+
+func (v pvector) pushTail(edit *editToken, level uint, parent *pvNode, tailNode *pvNode) *pvNode {
+	sub := ((v.count - 1) >> level) & hamtMask
+	out := parent.editable(edit)
+	if level == hamtBits {
+		out.children[sub] = tailNode
+	} else if child := parent.children[sub]; child != nil {
+		out.children[sub] = v.pushTail(edit, level-hamtBits, child, tailNode)
+	} else {
+		out.children[sub] = newPath(edit, level-hamtBits, tailNode)
+	}
+	return out
+}
+
+// newPath returns the branches from level down to n
+func newPath(edit *editToken, level uint, n *pvNode) *pvNode {
+	if level == 0 {
+		return n
+	}
+	out := &pvNode{edit: edit, children: make([]*pvNode, hamtWidth)}
+	out.children[0] = newPath(edit, level-hamtBits, n)
+	return out
+}
+
+// elems returns the elements of v in a new slice
+func (v pvector) elems() []Expr {
+	out := make([]Expr, 0, v.count)
+	var walk func(n *pvNode, level uint)
+	walk = func(n *pvNode, level uint) {
+		if level == 0 {
+			out = append(out, n.values...)
+			return
+		}
+		for _, c := range n.children {
+			if c == nil || len(out) >= v.tailOffset() {
+				return
+			}
+			walk(c, level-hamtBits)
+		}
+	}
+	if v.count > len(v.tail) {
+		walk(v.root, v.shift)
+	}
+	return append(out, v.tail...)
+}
@@ -0,0 +1,130 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Set is a collection of distinct values. Members are compared the same way as the keys of a Map,
+// so 1/2 and 2/4 are the same member. Sets evaluate to themselves. They are safe to use from multiple goroutines.
+//
+// Like a Map, a Set holds a version of a persistent hash map. Conj and Disj return new Sets without changing the old one.
+type Set struct {
+	mu sync.RWMutex
+	h  hamt
+}
+
+func NewSet() *Set {
+	return &Set{}
+}
+
+func (s *Set) isExpr() {}
+
+// String writes the set as a literal, #{MEMBER ...}, with the members in the same order as the keys of a Map
+func (s *Set) String() string {
+	var sb strings.Builder
+	sb.WriteString("#{")
+	for i, e := range s.Members() {
+		if i > 0 {
+			sb.WriteRune(' ')
+		}
+		sb.WriteString(e.String())
+	}
+	sb.WriteRune('}')
+	return sb.String()
+}
+
+func (s *Set) version() hamt {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.h
+}
+
+func setEntry(e Expr) (hamtEntry, error) {
+	hk, ok := hashKey(e)
+	if !ok {
+		return hamtEntry{}, fmt.Errorf("%s can't be a member of a set", e)
+	}
+	return newHamtEntry(hk, e, e), nil
+}
+
+// Add puts e in s. It returns an error if e can't be a member; only values that can be Map keys can be members.
+func (s *Set) Add(e Expr) error {
+	entry, err := setEntry(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.h.get(entry.hk); !ok {
+		s.h = s.h.assoc(nil, entry)
+	}
+	return nil
+}
+
+// Remove takes e out of s. It returns false if e wasn't in s.
+func (s *Set) Remove(e Expr) bool {
+	hk, ok := hashKey(e)
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.h, ok = s.h.dissoc(nil, hk)
+	return ok
+}
+
+// Conj returns a new Set with e in it. s isn't changed.
+func (s *Set) Conj(e Expr) (*Set, error) {
+	entry, err := setEntry(e)
+	if err != nil {
+		return nil, err
+	}
+	h := s.version()
+	if _, ok := h.get(entry.hk); !ok {
+		h = h.assoc(nil, entry)
+	}
+	return &Set{h: h}, nil
+}
+
+// Disj returns a new Set without e. s isn't changed.
+func (s *Set) Disj(e Expr) *Set {
+	h := s.version()
+	if hk, ok := hashKey(e); ok {
+		h, _ = h.dissoc(nil, hk)
+	}
+	return &Set{h: h}
+}
+
+// Contains reports whether e is in s
+func (s *Set) Contains(e Expr) bool {
+	hk, ok := hashKey(e)
+	if !ok {
+		return false
+	}
+	_, ok = s.version().get(hk)
+	return ok
+}
+
+// Len returns the number of members in s
+func (s *Set) Len() int {
+	return s.version().count
+}
+
+// Members returns the members of s, in the same order as the keys of a Map
+func (s *Set) Members() []Expr {
+	h := s.version()
+	out := make([]Expr, 0, h.count)
+	h.each(func(e hamtEntry) {
+		out = append(out, e.key)
+	})
+	sort.Slice(out, func(i, j int) bool { return CompareKeys(out[i], out[j]) < 0 })
+	return out
+}
+
+// Copy returns a new Set with the same members as s. It takes constant time.
+func (s *Set) Copy() *Set {
+	return &Set{h: s.version()}
+}
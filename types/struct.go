@@ -0,0 +1,85 @@
+package types
+
+import (
+	"strings"
+	"sync"
+)
+
+// Struct is an instance of a record type defined by DEFSTRUCT. Name is the name of the type, and each field
+// has a name and a value. Structs evaluate to themselves. They are safe to use from multiple goroutines.
+type Struct struct {
+	Name   Atom
+	mu     sync.RWMutex
+	fields []Atom
+	values []Expr
+}
+
+// NewStruct returns a Struct of type name. fields are the names of the fields, without a leading colon,
+// and values are their values, in the same order. It holds on to fields and values.
+func NewStruct(name Atom, fields []Atom, values []Expr) *Struct {
+	return &Struct{Name: name, fields: fields, values: values}
+}
+
+func (s *Struct) isExpr() {}
+
+// String writes the struct as a literal, #S(NAME :FIELD VALUE ...)
+func (s *Struct) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var sb strings.Builder
+	sb.WriteString("#S(")
+	sb.WriteString(string(s.Name))
+	for i, f := range s.fields {
+		sb.WriteString(" :")
+		sb.WriteString(string(f))
+		sb.WriteRune(' ')
+		sb.WriteString(s.values[i].String())
+	}
+	sb.WriteRune(')')
+	return sb.String()
+}
+
+// Fields returns the names of the fields of s, in order
+func (s *Struct) Fields() []Atom {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Atom, len(s.fields))
+	copy(out, s.fields)
+	return out
+}
+
+// Get returns the value of field, and false if s doesn't have that field
+func (s *Struct) Get(field Atom) (Expr, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, f := range s.fields {
+		if f == field {
+			return s.values[i], true
+		}
+	}
+	return nil, false
+}
+
+// Set changes the value of field to e. It returns false if s doesn't have that field.
+func (s *Struct) Set(field Atom, e Expr) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.fields {
+		if f == field {
+			s.values[i] = e
+			return true
+		}
+	}
+	return false
+}
+
+// Copy returns a new Struct with the same type, fields and values as s
+func (s *Struct) Copy() *Struct {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fields := make([]Atom, len(s.fields))
+	copy(fields, s.fields)
+	values := make([]Expr, len(s.values))
+	copy(values, s.values)
+	return NewStruct(s.Name, fields, values)
+}
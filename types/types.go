@@ -2,9 +2,10 @@ package types
 
 import (
 	"fmt"
+	"sort"
 	"strings"
-
-	"github.com/jonbodner/my_lisp/global"
+	"sync"
+	"unicode"
 )
 
 //expressions
@@ -14,6 +15,10 @@ import (
 type SExpr struct {
 	Left  Expr
 	Right Expr
+	// Span is where the list starting at this cell was read from, and LeftSpan is where Left was read from.
+	// They are only set for lists that come from the parser.
+	Span     *Span
+	LeftSpan *Span
 }
 
 type Expr interface {
@@ -31,14 +36,13 @@ func (s *SExpr) String() string {
 outer:
 	for cur := s.Right; cur != NIL; {
 		out += " "
-		switch c := cur.(type) {
-		case Atom:
-			out += ". " + c.String()
+		c, ok := cur.(*SExpr)
+		if !ok {
+			out += ". " + cur.String()
 			break outer
-		case *SExpr:
-			out += c.Left.String()
-			cur = c.Right
 		}
+		out += c.Left.String()
+		cur = c.Right
 	}
 	out += ")"
 	return out
@@ -82,8 +86,50 @@ func (a Atom) String() string {
 	return string(a)
 }
 
+// IsKeyword reports whether a is a keyword, like :NAME. Keywords evaluate to themselves.
+func (a Atom) IsKeyword() bool {
+	return len(a) > 1 && a[0] == ':'
+}
+
 var T Atom = "T"
 
+// String is a string literal. Strings evaluate to themselves.
+type String string
+
+func (s String) isExpr() {}
+func (s String) String() string {
+	return QuoteString(string(s))
+}
+
+// QuoteString returns s wrapped in double quotes, with the characters that
+// can't appear directly in a string literal replaced by escape sequences.
+func QuoteString(s string) string {
+	var sb strings.Builder
+	sb.WriteRune('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if unicode.IsPrint(r) {
+				sb.WriteRune(r)
+			} else {
+				fmt.Fprintf(&sb, `\u{%x}`, r)
+			}
+		}
+	}
+	sb.WriteRune('"')
+	return sb.String()
+}
+
 type Nil struct{}
 
 var NIL Nil
@@ -99,26 +145,58 @@ type Env interface {
 	Delete(a Atom)
 }
 
-type GlobalEnv map[Atom]Expr
+// GlobalEnv is the top-level environment.
+// It is safe to use from multiple goroutines.
+type GlobalEnv struct {
+	mu   sync.RWMutex
+	vals map[Atom]Expr
+}
 
-func (ge GlobalEnv) Get(a Atom) (Expr, bool) {
-	global.Log("checking global env for ", a)
-	e, ok := ge[a]
-	global.Log(e, ok)
+func NewGlobalEnv() *GlobalEnv {
+	return &GlobalEnv{vals: map[Atom]Expr{}}
+}
+
+func (ge *GlobalEnv) Get(a Atom) (Expr, bool) {
+	ge.mu.RLock()
+	e, ok := ge.vals[a]
+	ge.mu.RUnlock()
 	return e, ok
 }
 
-func (ge GlobalEnv) Put(a Atom, e Expr) {
-	ge[a] = e
+func (ge *GlobalEnv) Put(a Atom, e Expr) {
+	ge.mu.Lock()
+	ge.vals[a] = e
+	ge.mu.Unlock()
+}
+
+func (ge *GlobalEnv) Delete(a Atom) {
+	ge.mu.Lock()
+	delete(ge.vals, a)
+	ge.mu.Unlock()
 }
 
-func (ge GlobalEnv) Delete(a Atom) {
-	delete(ge, a)
+// Merge copies all of the symbols in other into ge, replacing any that are already defined
+func (ge *GlobalEnv) Merge(other *GlobalEnv) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	ge.mu.Lock()
+	defer ge.mu.Unlock()
+	for k, v := range other.vals {
+		ge.vals[k] = v
+	}
 }
 
-func (ge GlobalEnv) String() string {
+func (ge *GlobalEnv) String() string {
+	ge.mu.RLock()
+	defer ge.mu.RUnlock()
+	keys := make([]Atom, 0, len(ge.vals))
+	for k := range ge.vals {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
 	out := ""
-	for k, v := range ge {
+	for _, k := range keys {
+		v := ge.vals[k]
 		if _, ok := v.(Atom); ok {
 			out += fmt.Sprintf("(SETQ %s '%s)\n", k, v)
 		} else {
@@ -128,68 +206,220 @@ func (ge GlobalEnv) String() string {
 	return out
 }
 
+// LocalEnv is the environment for a LET or a LAMBDA call.
+// It is safe to use from multiple goroutines.
 type LocalEnv struct {
-	Vals   map[Atom]Expr
+	mu     sync.RWMutex
+	vals   map[Atom]Expr
 	Parent Env
 }
 
-func (le LocalEnv) Get(a Atom) (Expr, bool) {
-	global.Log("checking local env for ", a)
-	e, ok := le.Vals[a]
+func NewLocalEnv(parent Env) *LocalEnv {
+	return &LocalEnv{vals: map[Atom]Expr{}, Parent: parent}
+}
+
+func (le *LocalEnv) Get(a Atom) (Expr, bool) {
+	le.mu.RLock()
+	e, ok := le.vals[a]
+	le.mu.RUnlock()
 	if ok {
-		global.Log("found ", e, "at my level")
 		return e, ok
 	}
-	global.Log("not in me, going to parent")
 	return le.Parent.Get(a)
 }
 
+// Define assigns an Expr to an atom in this environment, even if the atom is defined in a parent scope.
+// It's used to bind parameters and LET variables.
+func (le *LocalEnv) Define(a Atom, e Expr) {
+	le.mu.Lock()
+	le.vals[a] = e
+	le.mu.Unlock()
+}
+
 // Put assigns an Expr to an atom in the local environment.
 // for now, no shadowing of declarations from outer scopes
 // since there's no way to modify the value of a value in an outer scope
 // (LABEL and SETQ are both create and assign)
-func (le LocalEnv) Put(a Atom, e Expr) {
+func (le *LocalEnv) Put(a Atom, e Expr) {
 	//case 1: already defined locally
-	if _, ok := le.Vals[a]; ok {
-		le.Vals[a] = e
+	le.mu.Lock()
+	if _, ok := le.vals[a]; ok {
+		le.vals[a] = e
+		le.mu.Unlock()
 		return
 	}
+	le.mu.Unlock()
 	// case 2: defined somewhere in a parent scope
 	if _, ok := le.Parent.Get(a); ok {
 		le.Parent.Put(a, e)
 		return
 	}
 	// case 3: never defined
-	le.Vals[a] = e
+	le.Define(a, e)
 }
 
-func (le LocalEnv) Delete(a Atom) {
-	global.Log("checking local env for ", a)
-	_, ok := le.Vals[a]
+func (le *LocalEnv) Delete(a Atom) {
+	le.mu.Lock()
+	_, ok := le.vals[a]
 	if ok {
-		delete(le.Vals, a)
-		global.Log("deleted ", a, "at my level")
+		delete(le.vals, a)
+		le.mu.Unlock()
 		return
 	}
-	global.Log("not in me, going to parent")
+	le.mu.Unlock()
 	le.Parent.Delete(a)
 }
 
+// Lambda is a function created by LAMBDA.
+// Params are the required parameters, which are symbols or patterns that pull apart lists, Optional the &OPTIONAL ones, Rest the &REST parameter (empty if there isn't one),
+// and Keys the &KEY parameters, which are passed as :NAME VALUE pairs.
 type Lambda struct {
 	ParentEnv Env
-	Params    []Atom
+	Params    []Expr
+	Optional  []Param
+	Rest      Atom
+	Keys      []Param
 	Body      Expr
 }
 
+// Param is an &OPTIONAL or &KEY parameter. Default is evaluated to get the value of the parameter when no value
+// is passed for it; if Default is nil, the value is NIL.
+type Param struct {
+	Name    Atom
+	Default Expr
+}
+
+func (p Param) String() string {
+	if p.Default == nil {
+		return string(p.Name)
+	}
+	return "(" + string(p.Name) + " " + p.Default.String() + ")"
+}
+
+// ParamString returns the parameter list of l, the way it would be written in a LAMBDA
+func (l Lambda) ParamString() string {
+	var sparams []string
+	for _, v := range l.Params {
+		sparams = append(sparams, v.String())
+	}
+	if len(l.Optional) > 0 {
+		sparams = append(sparams, "&OPTIONAL")
+		for _, v := range l.Optional {
+			sparams = append(sparams, v.String())
+		}
+	}
+	if l.Rest != "" {
+		sparams = append(sparams, "&REST", string(l.Rest))
+	}
+	if len(l.Keys) > 0 {
+		sparams = append(sparams, "&KEY")
+		for _, v := range l.Keys {
+			sparams = append(sparams, v.String())
+		}
+	}
+	return "(" + strings.Join(sparams, " ") + ")"
+}
+
 func (l Lambda) isExpr() {}
 func (l Lambda) String() string {
-	sparams := make([]string, len(l.Params))
-	for k, v := range l.Params {
-		sparams[k] = string(v)
+	return "(LAMBDA " + l.ParamString() + " " + l.Body.String() + " )"
+}
+
+// Macro is a user-defined macro created by DEFMACRO.
+// It has the same shape as a Lambda, but it is called with its
+// parameters unevaluated and its result is evaluated in place of the call.
+type Macro Lambda
+
+func (m Macro) isExpr() {}
+func (m Macro) String() string {
+	return "(MACRO " + Lambda(m).ParamString() + " " + m.Body.String() + " )"
+}
+
+// Channel is a Go channel that carries expressions between goroutines
+type Channel struct {
+	Ch chan Expr
+}
+
+func (c Channel) isExpr() {}
+func (c Channel) String() string {
+	return fmt.Sprintf("#<CHANNEL %p>", c.Ch)
+}
+
+// GoValue wraps a Go value that has no Lisp equivalent, like a struct, a pointer, a map or a function.
+// GoValues evaluate to themselves.
+type GoValue struct {
+	Value interface{}
+}
+
+func (g GoValue) isExpr() {}
+func (g GoValue) String() string {
+	return fmt.Sprintf("#<GO %T %v>", g.Value, g.Value)
+}
+
+// Error is a Lisp error. Kind is a symbol that says what sort of error it is, so that handlers can pick
+// the errors they catch, Message describes what went wrong, and Payload is any other value that goes with it.
+// Errors evaluate to themselves. An Error is also a Go error, so an Error that isn't caught is returned to Go code.
+type Error struct {
+	Kind    Atom
+	Message string
+	Payload Expr
+	// Backtrace describes the function calls that were running when the error was signaled, innermost first.
+	// It's filled in when the error is caught.
+	Backtrace []string
+}
+
+func (e *Error) isExpr() {}
+func (e *Error) String() string {
+	out := "#<ERROR " + string(e.Kind) + " " + QuoteString(e.Message)
+	if e.Payload != nil && e.Payload != NIL && e.Payload != EMPTY {
+		out += " " + e.Payload.String()
 	}
-	pstr := strings.Join(sparams, " ")
+	return out + ">"
+}
+
+// Error returns the message. For errors whose kind isn't ERROR, the message starts with the kind.
+func (e *Error) Error() string {
+	if e.Kind == "ERROR" {
+		return e.Message
+	}
+	return string(e.Kind) + ": " + e.Message
+}
 
-	return "(LAMBDA (" + pstr + ") " + l.Body.String() + " )"
+// Pos is a position in source code. Lines and columns start at 1, and columns count runes, not bytes.
+// The zero Pos is an unknown position.
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+// IsValid reports if p is a known position
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns file:line:col, or line:col if there's no file name
+func (p Pos) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+// Position returns p. Every token embeds a Pos, so this is how a token's position is found.
+func (p Pos) Position() Pos {
+	return p
+}
+
+// Span is the part of the source code that an expression was read from.
+// Start is the position of its first token, and End is the position of its last token.
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+func (s Span) String() string {
+	return s.Start.String() + "-" + s.End.String()
 }
 
 //tokens
@@ -197,9 +427,10 @@ func (l Lambda) String() string {
 type Token interface {
 	fmt.Stringer
 	TokenForm() string
+	Position() Pos
 }
 
-type LParen struct{}
+type LParen struct{ Pos }
 
 var LPAREN LParen
 
@@ -208,7 +439,7 @@ func (l LParen) String() string {
 	return "LPAREN"
 }
 
-type RParen struct{}
+type RParen struct{ Pos }
 
 var RPAREN RParen
 
@@ -217,7 +448,73 @@ func (r RParen) String() string {
 	return "RPAREN"
 }
 
-type Dot struct{}
+type LBrace struct{ Pos }
+
+var LBRACE LBrace
+
+func (l LBrace) TokenForm() string { return "{" }
+func (l LBrace) String() string {
+	return "LBRACE"
+}
+
+type RBrace struct{ Pos }
+
+var RBRACE RBrace
+
+func (r RBrace) TokenForm() string { return "}" }
+func (r RBrace) String() string {
+	return "RBRACE"
+}
+
+type LBracket struct{ Pos }
+
+var LBRACKET LBracket
+
+func (l LBracket) TokenForm() string { return "[" }
+func (l LBracket) String() string {
+	return "LBRACKET"
+}
+
+type RBracket struct{ Pos }
+
+var RBRACKET RBracket
+
+func (r RBracket) TokenForm() string { return "]" }
+func (r RBracket) String() string {
+	return "RBRACKET"
+}
+
+// HashParen starts a vector literal written as #( ... ), which is closed by an RParen
+type HashParen struct{ Pos }
+
+var HASH_PAREN HashParen
+
+func (h HashParen) TokenForm() string { return "#(" }
+func (h HashParen) String() string {
+	return "HASH_PAREN"
+}
+
+// HashBrace starts a set literal written as #{ ... }, which is closed by an RBrace
+type HashBrace struct{ Pos }
+
+var HASH_BRACE HashBrace
+
+func (h HashBrace) TokenForm() string { return "#{" }
+func (h HashBrace) String() string {
+	return "HASH_BRACE"
+}
+
+// HashS starts a struct literal written as #S(NAME :FIELD VALUE ...), which is closed by an RParen
+type HashS struct{ Pos }
+
+var HASH_S HashS
+
+func (h HashS) TokenForm() string { return "#S(" }
+func (h HashS) String() string {
+	return "HASH_S"
+}
+
+type Dot struct{ Pos }
 
 var DOT Dot
 
@@ -226,7 +523,7 @@ func (d Dot) String() string {
 	return "DOT"
 }
 
-type Quote struct{}
+type Quote struct{ Pos }
 
 var QUOTE Quote
 
@@ -235,7 +532,57 @@ func (q Quote) String() string {
 	return "QUOTE"
 }
 
-type NAME string
+type Backquote struct{ Pos }
+
+var BACKQUOTE Backquote
+
+func (b Backquote) TokenForm() string { return "`" }
+func (b Backquote) String() string {
+	return "BACKQUOTE"
+}
+
+type Comma struct{ Pos }
+
+var COMMA Comma
+
+func (c Comma) TokenForm() string { return "," }
+func (c Comma) String() string {
+	return "COMMA"
+}
+
+type CommaAt struct{ Pos }
+
+var COMMA_AT CommaAt
+
+func (c CommaAt) TokenForm() string { return ",@" }
+func (c CommaAt) String() string {
+	return "COMMA_AT"
+}
+
+// NAME is a symbol or a number
+type NAME struct {
+	Text string
+	Pos
+}
+
+func (n NAME) String() string    { return n.Text }
+func (n NAME) TokenForm() string { return n.Text }
+
+// STRING is a double-quoted string literal, with its escape sequences already processed
+type STRING struct {
+	Value string
+	Pos
+}
+
+func (s STRING) String() string    { return QuoteString(s.Value) }
+func (s STRING) TokenForm() string { return QuoteString(s.Value) }
+
+// Invalid is text that the scanner couldn't turn into a token, along with the reason why
+type Invalid struct {
+	Text string
+	Msg  string
+	Pos
+}
 
-func (n NAME) String() string    { return string(n) }
-func (n NAME) TokenForm() string { return string(n) }
+func (i Invalid) String() string    { return "INVALID(" + i.Text + ")" }
+func (i Invalid) TokenForm() string { return i.Text }
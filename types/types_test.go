@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"math/big"
 	"testing"
 )
 
@@ -42,3 +43,397 @@ func TestSExpr(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestString(t *testing.T) {
+	data := []struct {
+		in       String
+		expected string
+	}{
+		{"", `""`},
+		{"hello world", `"hello world"`},
+		{"a\"b", `"a\"b"`},
+		{"a\\b", `"a\\b"`},
+		{"line\nbreak\ttab", `"line\nbreak\ttab"`},
+		{"bell\a", `"bell\u{7}"`},
+		{"(parens)", `"(parens)"`},
+	}
+	for _, d := range data {
+		if d.in.String() != d.expected {
+			t.Errorf("Expected %s, got %s", d.expected, d.in.String())
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	data := []struct {
+		in       string
+		expected string
+		isNumber bool
+	}{
+		{"12", "12", true},
+		{"-12", "-12", true},
+		{"+12", "12", true},
+		{"123456789012345678901234567890", "123456789012345678901234567890", true},
+		{"1/2", "1/2", true},
+		{"-6/4", "-3/2", true},
+		{"4/2", "2", true},
+		{"1/0", "", false},
+		{"1.5", "1.5", true},
+		{"1.", "1.0", true},
+		{".5", "0.5", true},
+		{"-2.0", "-2.0", true},
+		{"1e3", "1000.0", true},
+		{"1.5E-3", "0.0015", true},
+		{"1e400", "+inf.0", true},
+		{"+inf.0", "+inf.0", true},
+		{"-inf.0", "-inf.0", true},
+		{"+nan.0", "+nan.0", true},
+		{"inf.0", "", false},
+		{"+", "", false},
+		{"-", "", false},
+		{"A", "", false},
+		{"1A", "", false},
+		{"Inf", "", false},
+		{"NaN", "", false},
+		{"0x10", "", false},
+		{"1_000", "", false},
+		{"1/2/3", "", false},
+	}
+	for _, d := range data {
+		n, ok := ParseNumber(d.in)
+		if ok != d.isNumber {
+			t.Errorf("%s: expected number %v, got %v", d.in, d.isNumber, ok)
+			continue
+		}
+		if ok && n.String() != d.expected {
+			t.Errorf("%s: expected %s, got %s", d.in, d.expected, n)
+		}
+	}
+}
+
+func TestNumberTypes(t *testing.T) {
+	if _, ok := NewBigInteger(big.NewInt(5)).Int64(); !ok {
+		t.Error("small big.Int should be stored as an int64")
+	}
+	if _, ok := NewRatio(big.NewRat(4, 2)).(Integer); !ok {
+		t.Error("whole ratio should be an Integer")
+	}
+	n, _ := ParseNumber("3/4")
+	if _, ok := n.(Ratio); !ok {
+		t.Errorf("expected a Ratio, got %T", n)
+	}
+	n, _ = ParseNumber("3.0")
+	if _, ok := n.(Float); !ok {
+		t.Errorf("expected a Float, got %T", n)
+	}
+	dotted := &SExpr{Left: Atom("A"), Right: NewInteger(5)}
+	if dotted.String() != "(A . 5)" {
+		t.Errorf("expected (A . 5), got %s", dotted)
+	}
+}
+
+func TestMap(t *testing.T) {
+	m := NewMap()
+	half, _ := ParseNumber("1/2")
+	twoFourths, _ := ParseNumber("2/4")
+	list := &SExpr{Left: Atom("A"), Right: &SExpr{Left: String("b"), Right: NIL}}
+	sameList := &SExpr{Left: Atom("A"), Right: &SExpr{Left: String("b"), Right: NIL}}
+	for _, k := range []Expr{half, list, Atom("A"), Float(1), NewInteger(1), String("A"), EMPTY} {
+		if err := m.Put(k, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Put(NewMap(), NIL); err == nil {
+		t.Error("a map shouldn't be a key")
+	}
+	if v, ok := m.Get(twoFourths); !ok || v != half {
+		t.Errorf("2/4 should find 1/2, got %v", v)
+	}
+	if v, ok := m.Get(sameList); !ok || v != list {
+		t.Errorf("lists should be found by their elements, got %v", v)
+	}
+	if _, ok := m.Get(Float(0)); ok {
+		t.Error("0.0 shouldn't be found")
+	}
+	expected := `{1/2 1/2 1 1 1.0 1.0 "A" "A" A A () () (A "b") (A "b")}`
+	if m.String() != expected {
+		t.Errorf("expected %s, got %s", expected, m)
+	}
+	if !m.Delete(Atom("A")) || m.Delete(Atom("A")) || m.Len() != 6 {
+		t.Error("A should be deleted once")
+	}
+	c := m.Copy()
+	c.Put(Atom("B"), NIL)
+	if m.Len() != 6 || c.Len() != 7 {
+		t.Error("changing a copy shouldn't change the original")
+	}
+	dotted := &SExpr{Left: Atom("A"), Right: Atom("B")}
+	if err := m.Put(dotted, NIL); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Get(&SExpr{Left: Atom("A"), Right: &SExpr{Left: Atom("B"), Right: NIL}}); ok {
+		t.Error("(A . B) and (A B) should be different keys")
+	}
+}
+
+func TestVector(t *testing.T) {
+	v := NewVector([]Expr{Atom("A"), NewInteger(1), EMPTY})
+	if v.String() != "[A 1 ()]" {
+		t.Errorf("expected [A 1 ()], got %s", v)
+	}
+	if e, ok := v.Get(1); !ok || e != NewInteger(1) {
+		t.Errorf("expected 1, got %v", e)
+	}
+	if _, ok := v.Get(3); ok {
+		t.Error("index 3 should be out of bounds")
+	}
+	c := v.Copy()
+	if !c.Set(0, Atom("B")) || c.Set(-1, NIL) {
+		t.Error("only index 0 should be set")
+	}
+	elems := v.Elems()
+	elems[1] = NIL
+	if v.String() != "[A 1 ()]" || c.String() != "[B 1 ()]" || v.Len() != 3 {
+		t.Errorf("changing a copy shouldn't change the original, got %s and %s", v, c)
+	}
+}
+
+func TestSet(t *testing.T) {
+	s := NewSet()
+	half, _ := ParseNumber("1/2")
+	twoFourths, _ := ParseNumber("2/4")
+	for _, e := range []Expr{Atom("B"), half, twoFourths, Float(0.5), Atom("B")} {
+		if err := s.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Add(NewSet()); err == nil {
+		t.Error("a set shouldn't be a member")
+	}
+	if s.Len() != 3 || s.String() != "#{1/2 0.5 B}" {
+		t.Errorf("expected #{1/2 0.5 B}, got %s", s)
+	}
+	if !s.Contains(twoFourths) || s.Contains(Atom("C")) {
+		t.Error("2/4 should be a member and C shouldn't")
+	}
+	c := s.Copy()
+	if !c.Remove(twoFourths) || c.Remove(half) {
+		t.Error("1/2 should be removed once")
+	}
+	if s.Len() != 3 || c.Len() != 2 {
+		t.Error("changing a copy shouldn't change the original")
+	}
+}
+
+func TestStruct(t *testing.T) {
+	s := NewStruct("POINT", []Atom{"X", "Y"}, []Expr{NewInteger(1), EMPTY})
+	if s.String() != "#S(POINT :X 1 :Y ())" {
+		t.Errorf("expected #S(POINT :X 1 :Y ()), got %s", s)
+	}
+	if v, ok := s.Get("X"); !ok || v != NewInteger(1) {
+		t.Errorf("expected 1, got %v", v)
+	}
+	if _, ok := s.Get("Z"); ok {
+		t.Error("Z shouldn't be a field")
+	}
+	c := s.Copy()
+	if !c.Set("Y", Atom("A")) || c.Set("Z", NIL) {
+		t.Error("only Y should be set")
+	}
+	if s.String() != "#S(POINT :X 1 :Y ())" || c.String() != "#S(POINT :X 1 :Y A)" {
+		t.Errorf("changing a copy shouldn't change the original, got %s and %s", s, c)
+	}
+}
+
+func TestPersistentMap(t *testing.T) {
+	const n = 10000
+	m := NewMap()
+	versions := []*Map{m}
+	for i := 0; i < n; i++ {
+		next, err := versions[len(versions)-1].Assoc(NewInteger(int64(i)), NewInteger(int64(i*2)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, next)
+	}
+	if m.Len() != 0 {
+		t.Errorf("Assoc shouldn't change the original, got %d entries", m.Len())
+	}
+	full := versions[n]
+	for i := 0; i <= n; i += 1000 {
+		if versions[i].Len() != i {
+			t.Errorf("version %d should have %d entries, got %d", i, i, versions[i].Len())
+		}
+	}
+	for i := 0; i < n; i++ {
+		if v, ok := full.Get(NewInteger(int64(i))); !ok || v != NewInteger(int64(i*2)) {
+			t.Fatalf("expected %d for %d, got %v", i*2, i, v)
+		}
+	}
+	cur := full
+	for i := 0; i < n; i += 2 {
+		cur = cur.Dissoc(NewInteger(int64(i)))
+	}
+	if cur.Len() != n/2 || full.Len() != n {
+		t.Errorf("expected %d and %d entries, got %d and %d", n/2, n, cur.Len(), full.Len())
+	}
+	for i := 0; i < n; i++ {
+		if _, ok := cur.Get(NewInteger(int64(i))); ok != (i%2 == 1) {
+			t.Fatalf("%d should be in the map: %v", i, !ok)
+		}
+	}
+	if same := full.Dissoc(Atom("MISSING")); same.Len() != n {
+		t.Errorf("removing a missing key shouldn't change the map, got %d entries", same.Len())
+	}
+}
+
+func TestHamtCollisions(t *testing.T) {
+	//find integers whose hash keys have the same 32-bit hash, so they go in a collision node
+	seen := map[uint32]int64{}
+	var colliding []Expr
+	for i := int64(0); len(colliding) == 0; i++ {
+		hk, _ := hashKey(NewInteger(i))
+		if j, ok := seen[hashOf(hk)]; ok {
+			colliding = []Expr{NewInteger(j), NewInteger(i)}
+		}
+		seen[hashOf(hk)] = i
+	}
+	m := NewMap()
+	for _, k := range append(colliding, Atom("X")) {
+		if err := m.Put(k, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.Len() != 3 {
+		t.Errorf("expected 3 entries, got %d", m.Len())
+	}
+	for _, k := range colliding {
+		if v, ok := m.Get(k); !ok || v != k {
+			t.Errorf("expected %s, got %v", k, v)
+		}
+	}
+	m2, err := m.Assoc(colliding[0], NIL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := m2.Get(colliding[0]); v != NIL || m2.Len() != 3 {
+		t.Errorf("expected NIL and 3 entries, got %v and %d", v, m2.Len())
+	}
+	m2 = m2.Dissoc(colliding[0])
+	if _, ok := m2.Get(colliding[0]); ok || m2.Len() != 2 {
+		t.Errorf("%s should have been removed", colliding[0])
+	}
+	if v, ok := m2.Get(colliding[1]); !ok || v != colliding[1] {
+		t.Errorf("expected %s, got %v", colliding[1], v)
+	}
+	if v, _ := m.Get(colliding[0]); v != colliding[0] || m.Len() != 3 {
+		t.Errorf("Assoc and Dissoc shouldn't change the original, got %s", m)
+	}
+}
+
+func TestPersistentVector(t *testing.T) {
+	//past the tail, the first level of the trie, the second level, and the third
+	for _, n := range []int{0, 1, 32, 33, 1024, 1057, 32768 + 33, 40000} {
+		elems := make([]Expr, n)
+		for i := range elems {
+			elems[i] = NewInteger(int64(i))
+		}
+		built := NewVector(elems)
+		conjed := NewVector(nil)
+		for _, e := range elems {
+			conjed = conjed.Conj(e)
+		}
+		for _, v := range []*Vector{built, conjed} {
+			if v.Len() != n {
+				t.Fatalf("expected %d elements, got %d", n, v.Len())
+			}
+			for i := 0; i < n; i++ {
+				if e, _ := v.Get(i); e != NewInteger(int64(i)) {
+					t.Fatalf("expected %d at %d of %d, got %v", i, i, n, e)
+				}
+			}
+			if out := v.Elems(); len(out) != n || (n > 0 && out[n-1] != NewInteger(int64(n-1))) {
+				t.Fatalf("expected %d elements from Elems, got %d", n, len(out))
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		changed := built
+		for _, i := range []int{0, n / 2, n - 1} {
+			var ok bool
+			if changed, ok = changed.Assoc(i, Atom("X")); !ok {
+				t.Fatalf("couldn't set %d of %d", i, n)
+			}
+		}
+		for _, i := range []int{0, n / 2, n - 1} {
+			if e, _ := changed.Get(i); e != Atom("X") {
+				t.Errorf("expected X at %d of %d, got %v", i, n, e)
+			}
+			if e, _ := built.Get(i); e != NewInteger(int64(i)) {
+				t.Errorf("Assoc shouldn't change the original, got %v at %d of %d", e, i, n)
+			}
+		}
+		if _, ok := built.Assoc(n+1, NIL); ok {
+			t.Errorf("%d should be out of bounds for %d", n+1, n)
+		}
+	}
+}
+
+func TestTransients(t *testing.T) {
+	v := NewVector([]Expr{Atom("A")})
+	tv := v.Transient()
+	for i := 0; i < 100; i++ {
+		if err := tv.Conj(NewInteger(int64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ok, err := tv.Set(0, Atom("B")); !ok || err != nil {
+		t.Fatalf("couldn't set 0: %v", err)
+	}
+	pv, err := tv.Persistent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pv.Len() != 101 || v.Len() != 1 {
+		t.Errorf("expected 101 and 1 elements, got %d and %d", pv.Len(), v.Len())
+	}
+	if e, _ := pv.Get(0); e != Atom("B") {
+		t.Errorf("expected B, got %v", e)
+	}
+	if err := tv.Conj(NIL); err != ErrTransientUsed {
+		t.Errorf("expected ErrTransientUsed, got %v", err)
+	}
+	//changing a vector made from the transient shouldn't change the transient's result
+	pv2 := pv.Conj(Atom("C"))
+	if pv.Len() != 101 || pv2.Len() != 102 {
+		t.Errorf("expected 101 and 102 elements, got %d and %d", pv.Len(), pv2.Len())
+	}
+
+	m := NewMap()
+	if err := m.Put(Atom("A"), NIL); err != nil {
+		t.Fatal(err)
+	}
+	tm := m.Transient()
+	for i := 0; i < 1000; i++ {
+		if err := tm.Put(NewInteger(int64(i)), NewInteger(int64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tm.Delete(Atom("A")); err != nil {
+		t.Fatal(err)
+	}
+	pm, err := tm.Persistent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pm.Len() != 1000 || m.Len() != 1 {
+		t.Errorf("expected 1000 and 1 entries, got %d and %d", pm.Len(), m.Len())
+	}
+	if _, err := tm.Persistent(); err != ErrTransientUsed {
+		t.Errorf("expected ErrTransientUsed, got %v", err)
+	}
+	if tm.String() != "#<TRANSIENT>" {
+		t.Errorf("expected #<TRANSIENT>, got %s", tm)
+	}
+}
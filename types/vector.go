@@ -0,0 +1,177 @@
+package types
+
+import (
+	"strings"
+	"sync"
+)
+
+// Vector is a sequence of expressions, with access to each element in O(log32 n) time, which is close to constant.
+// Vectors evaluate to themselves. They are safe to use from multiple goroutines.
+//
+// A Vector holds a version of a persistent vector. Conj and Assoc return new Vectors without changing the old one,
+// sharing most of the old one's structure. Set changes which version the Vector holds, so it changes the Vector,
+// but not the Vectors that were made from it by Conj, Assoc or Copy.
+type Vector struct {
+	mu sync.RWMutex
+	v  pvector
+}
+
+// NewVector returns a Vector with the elements in elems
+func NewVector(elems []Expr) *Vector {
+	//the nodes are built in place, like a transient's, and then nothing else can change them
+	edit := &editToken{}
+	var pv pvector
+	for _, e := range elems {
+		pv = pv.conj(edit, e)
+	}
+	return &Vector{v: pv}
+}
+
+func (v *Vector) isExpr() {}
+
+// String writes the vector as a literal, [ELEM ...]
+func (v *Vector) String() string {
+	return elemsString("[", v.Elems(), "]")
+}
+
+func elemsString(start string, elems []Expr, end string) string {
+	var sb strings.Builder
+	sb.WriteString(start)
+	for i, e := range elems {
+		if i > 0 {
+			sb.WriteRune(' ')
+		}
+		sb.WriteString(e.String())
+	}
+	sb.WriteString(end)
+	return sb.String()
+}
+
+func (v *Vector) version() pvector {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.v
+}
+
+// Len returns the number of elements in v
+func (v *Vector) Len() int {
+	return v.version().count
+}
+
+// Get returns the element at i, and false if i is out of bounds
+func (v *Vector) Get(i int) (Expr, bool) {
+	pv := v.version()
+	if i < 0 || i >= pv.count {
+		return nil, false
+	}
+	return pv.get(i), true
+}
+
+// Set changes the element at i to e. It returns false if i is out of bounds.
+func (v *Vector) Set(i int, e Expr) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if i < 0 || i >= v.v.count {
+		return false
+	}
+	v.v = v.v.set(nil, i, e)
+	return true
+}
+
+// Assoc returns a new Vector with the element at i changed to e. If i is the length of v, e is added to the end.
+// It returns false if i is out of bounds. v isn't changed.
+func (v *Vector) Assoc(i int, e Expr) (*Vector, bool) {
+	pv := v.version()
+	switch {
+	case i < 0 || i > pv.count:
+		return nil, false
+	case i == pv.count:
+		return &Vector{v: pv.conj(nil, e)}, true
+	}
+	return &Vector{v: pv.set(nil, i, e)}, true
+}
+
+// Conj returns a new Vector with e added to the end. v isn't changed.
+func (v *Vector) Conj(e Expr) *Vector {
+	return &Vector{v: v.version().conj(nil, e)}
+}
+
+// Elems returns a copy of the elements in v
+func (v *Vector) Elems() []Expr {
+	return v.version().elems()
+}
+
+// Copy returns a new Vector with the same elements as v. It takes constant time.
+func (v *Vector) Copy() *Vector {
+	return &Vector{v: v.version()}
+}
+
+// Transient returns a TransientVector that starts with the elements in v
+func (v *Vector) Transient() *TransientVector {
+	return &TransientVector{v: v.version(), edit: &editToken{}}
+}
+
+// TransientVector builds a Vector. Unlike a Vector, it changes its nodes in place, so making a lot of changes is faster.
+// Once Persistent is called, it can't be used again.
+type TransientVector struct {
+	mu   sync.Mutex
+	v    pvector
+	edit *editToken
+}
+
+func (t *TransientVector) isExpr() {}
+func (t *TransientVector) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.edit == nil {
+		return "#<TRANSIENT>"
+	}
+	return elemsString("#<TRANSIENT [", t.v.elems(), "]>")
+}
+
+// Len returns the number of elements in t
+func (t *TransientVector) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.v.count
+}
+
+// Conj adds e to the end of t. It returns an error if t has been made persistent.
+func (t *TransientVector) Conj(e Expr) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.edit == nil {
+		return ErrTransientUsed
+	}
+	t.v = t.v.conj(t.edit, e)
+	return nil
+}
+
+// Set changes the element at i to e. If i is the length of t, e is added to the end.
+// It returns false if i is out of bounds, and an error if t has been made persistent.
+func (t *TransientVector) Set(i int, e Expr) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case t.edit == nil:
+		return false, ErrTransientUsed
+	case i < 0 || i > t.v.count:
+		return false, nil
+	case i == t.v.count:
+		t.v = t.v.conj(t.edit, e)
+	default:
+		t.v = t.v.set(t.edit, i, e)
+	}
+	return true, nil
+}
+
+// Persistent returns a Vector with the elements in t. After it is called, t can't be used.
+func (t *TransientVector) Persistent() (*Vector, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.edit == nil {
+		return nil, ErrTransientUsed
+	}
+	t.edit = nil
+	return &Vector{v: t.v}, nil
+}